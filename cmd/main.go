@@ -36,59 +36,84 @@ func main() {
 	}
 	defer db.Close()
 
-	// Configurar RabbitMQ
-	rabbitMQ, err := messaging.NewRabbitMQService(&cfg.RabbitMQ)
-	if err != nil {
-		log.Printf("Warning: Failed to connect to RabbitMQ: %v", err)
-		// La aplicación puede funcionar sin RabbitMQ, pero con funcionalidad limitada
-	}
-	defer func() {
-		if rabbitMQ != nil {
-			rabbitMQ.Close()
-		}
-	}()
+	// Configurar RabbitMQ. La conexión se establece en segundo plano y se reintenta con
+	// backoff; la aplicación funciona sin RabbitMQ, pero con funcionalidad limitada
+	rabbitMQ := messaging.NewRabbitMQService(&cfg.RabbitMQ)
 
 	// Crear repositorios
 	stockRepo := repository.NewStockRepository(db.PG, db.Redis)
 	eventRepo := repository.NewStockEventRepository(db.PG)
+	reservationRepo := repository.NewReservationRepository(db.PG)
+	outboxRepo := repository.NewOutboxRepository(db.PG)
+	processedMessageRepo := repository.NewProcessedMessageRepository(db.PG)
+	snapshotRepo := repository.NewStockSnapshotRepository(db.PG)
+	reconciliationRepo := repository.NewStockReconciliationRepository(db.PG)
+	partitionRepo := repository.NewStockEventPartitionRepository(db.PG)
+	countRepo := repository.NewStockCountRepository(db.PG)
+	idempotencyKeyRepo := repository.NewIdempotencyKeyRepository(db.PG)
+	txManager := repository.NewTxManager(db.PG)
 
-	// Crear publisher para low stock
-	var lowStockPublisher messaging.MessagePublisher
-	if rabbitMQ != nil {
-		lowStockPublisher, err = messaging.NewLowStockPublisher(rabbitMQ.GetConnection())
-		if err != nil {
-			log.Printf("Warning: Failed to create low stock publisher: %v", err)
-		}
+	// Crear publishers (escriben en el outbox; el OutboxRelay los entrega a RabbitMQ)
+	publishers := service.Publishers{
+		LowStock:           messaging.NewLowStockPublisher(outboxRepo),
+		OrderShortage:      messaging.NewInsufficientStockPublisher(outboxRepo),
+		ReservationExpired: messaging.NewReservationExpiredPublisher(outboxRepo),
 	}
 
 	// Crear servicios
-	stockService := service.NewStockService(stockRepo, eventRepo, lowStockPublisher)
+	stockService := service.NewStockService(stockRepo, eventRepo, reservationRepo, txManager, publishers, cfg.Reservation.TTL)
+	historyService := service.NewStockHistoryService(stockRepo, eventRepo, snapshotRepo, partitionRepo, txManager)
+	archiveService := service.NewStockEventArchiveService(partitionRepo, historyService, txManager, cfg.EventArchive.Dir)
+	reconciliationService := service.NewStockReconciliationService(stockService, stockRepo, reconciliationRepo, txManager)
+	countService := service.NewStockCountService(stockService, stockRepo, countRepo, txManager)
+	importService := service.NewStockImportService(stockService, stockRepo, txManager)
+	batchService := service.NewStockBatchService(stockService, stockRepo, txManager)
+	messageLedger := service.NewMessageLedger(processedMessageRepo, txManager)
+	idempotencyService := service.NewIdempotencyService(idempotencyKeyRepo, txManager, cfg.Idempotency.TTL)
 	authService := service.NewAuthService(db.Redis, cfg.Auth.ServiceURL)
 
 	// Crear handlers
 	addArticleHandler := handlers.NewAddArticleHandler(stockService)
 	getArticleHandler := handlers.NewGetArticleHandler(stockService)
+	updateArticleHandler := handlers.NewUpdateArticleHandler(stockService)
+	updateArticleLifecycleHandler := handlers.NewUpdateArticleLifecycleHandler(stockService)
 	getAllArticlesHandler := handlers.NewGetAllArticlesHandler(stockService)
 	getArticleEventsHandler := handlers.NewGetArticleEventsHandler(stockService)
+	listStockEventsHandler := handlers.NewListStockEventsHandler(stockService)
+	getOrderEventsHandler := handlers.NewGetOrderEventsHandler(stockService)
+	getStockAsOfHandler := handlers.NewGetStockAsOfHandler(historyService)
 	replenishHandler := handlers.NewReplenishStockHandler(stockService)
 	deductHandler := handlers.NewDeductStockHandler(stockService)
+	batchStockHandler := handlers.NewBatchStockHandler(batchService)
 	reserveHandler := handlers.NewReserveStockHandler(stockService)
+	reserveOrderHandler := handlers.NewReserveOrderHandler(stockService)
 	cancelHandler := handlers.NewCancelReservationHandler(stockService)
 	confirmHandler := handlers.NewConfirmReservationHandler(stockService)
 	lowStockHandler := handlers.NewLowStockHandler(stockService)
 
+	// Administración de mensajes aparcados (solo disponible con RabbitMQ conectado)
+	deadLetterManager := messaging.NewDeadLetterManager(rabbitMQ, messaging.ConsumerQueues)
+	listDeadLettersHandler := handlers.NewListDeadLettersHandler(deadLetterManager)
+	replayDeadLetterHandler := handlers.NewReplayDeadLetterHandler(deadLetterManager)
+	discardDeadLetterHandler := handlers.NewDiscardDeadLetterHandler(deadLetterManager)
+
+	// Reportes de conciliación entre stocks y el historial de eventos
+	getReconciliationReportHandler := handlers.NewGetReconciliationReportHandler(reconciliationService)
+
+	// Recuentos físicos por ubicación
+	openStockCountHandler := handlers.NewOpenStockCountHandler(countService)
+	getStockCountHandler := handlers.NewGetStockCountHandler(countService)
+	submitStockCountHandler := handlers.NewSubmitStockCountHandler(countService)
+	postStockCountHandler := handlers.NewPostStockCountHandler(countService)
+	cancelStockCountHandler := handlers.NewCancelStockCountHandler(countService)
+
+	// Importación y exportación masiva de artículos
+	importStocksHandler := handlers.NewImportStocksHandler(importService)
+	exportStocksHandler := handlers.NewExportStocksHandler(importService)
+
 	// Configurar Fiber
 	app := fiber.New(fiber.Config{
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			code := fiber.StatusInternalServerError
-			if e, ok := err.(*fiber.Error); ok {
-				code = e.Code
-			}
-			return c.Status(code).JSON(fiber.Map{
-				"error": err.Error(),
-				"code":  code,
-			})
-		},
+		ErrorHandler: handlers.ErrorHandler,
 		ReadTimeout:  time.Second * 30,
 		WriteTimeout: time.Second * 30,
 	})
@@ -96,99 +121,137 @@ func main() {
 	// Middlewares
 	app.Use(recover.New())
 	app.Use(requestid.New())
+	app.Use(middleware.AttributionMiddleware())
 	app.Use(logger.New(logger.Config{
 		Format: "[${time}] ${status} - ${method} ${path} - ${latency}\n",
 	}))
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,X-Correlation-ID,X-Causation-ID,Idempotency-Key,If-Match,If-None-Match",
+		ExposeHeaders: "Idempotent-Replayed,ETag",
 	}))
 
 	// Health check
 	app.Get("/health", func(c *fiber.Ctx) error {
+		status := "healthy"
+		rabbitMQStatus := rabbitMQ.Status()
+		if rabbitMQStatus.State != messaging.ConnectionStateConnected {
+			status = "degraded"
+		}
+
 		return c.JSON(fiber.Map{
-			"status":    "healthy",
+			"status":    status,
 			"service":   "stock-service",
 			"version":   "1.0.0",
 			"timestamp": time.Now().Unix(),
+			"rabbitmq":  rabbitMQStatus,
 		})
 	})
 
+	// Las rutas que modifican stock aceptan el header Idempotency-Key para reintentos seguros
+	idempotent := middleware.IdempotencyMiddleware(idempotencyService)
+	idempotentOutcome := middleware.IdempotencyOutcomeMiddleware(idempotencyService)
+	// Las rutas que modifican un único artículo aceptan If-Match con el ETag de su versión
+	ifMatch := middleware.IfMatchMiddleware()
+
 	// API routes
 	api := app.Group("/api")
 	v1 := api.Group("/stock")
 
 	// Article management routes
-	v1.Post("/articles", middleware.AuthMiddleware(authService), addArticleHandler.Handle)
+	v1.Post("/articles", middleware.AuthMiddleware(authService), idempotent, addArticleHandler.Handle)
 	v1.Get("/articles", middleware.AuthMiddleware(authService), getAllArticlesHandler.Handle)
 	v1.Get("/articles/:articleId", middleware.AuthMiddleware(authService), getArticleHandler.Handle)
+	v1.Patch("/articles/:articleId", middleware.AuthMiddleware(authService), idempotent, ifMatch, updateArticleHandler.Handle)
+	v1.Put("/articles/:articleId/lifecycle", middleware.AuthMiddleware(authService), idempotent, ifMatch, updateArticleLifecycleHandler.Handle)
 	v1.Get("/articles/:articleId/events", middleware.AuthMiddleware(authService), getArticleEventsHandler.Handle)
+	v1.Get("/articles/:articleId/as-of", middleware.AuthMiddleware(authService), getStockAsOfHandler.Handle)
+	v1.Get("/as-of", middleware.AuthMiddleware(authService), getStockAsOfHandler.HandleBulk)
 
-	// Stock operations routes
-	v1.Put("/replenish", replenishHandler.Handle)
+	// Event history routes
+	v1.Get("/events", middleware.AuthMiddleware(authService), listStockEventsHandler.Handle)
+	v1.Get("/orders/:orderId/events", middleware.AuthMiddleware(authService), getOrderEventsHandler.Handle)
 
-	v1.Put("/deduct", deductHandler.Handle)
+	// Stock operations routes. El token es opcional: si se envía, los movimientos se
+	// atribuyen al usuario o a la credencial de servicio
+	optionalAuth := middleware.OptionalAuthMiddleware(authService)
+
+	v1.Put("/replenish", optionalAuth, idempotent, ifMatch, replenishHandler.Handle)
+
+	v1.Put("/deduct", optionalAuth, idempotent, ifMatch, deductHandler.Handle)
 
 	// Reservation routes
-	v1.Put("/reserve", reserveHandler.Handle)
+	v1.Put("/reserve", optionalAuth, idempotent, ifMatch, reserveHandler.Handle)
+
+	v1.Post("/orders/:orderId/reserve", optionalAuth, idempotent, reserveOrderHandler.Handle)
 
-	v1.Put("/cancel-reservation", cancelHandler.Handle)
+	v1.Put("/cancel-reservation", optionalAuth, idempotent, ifMatch, cancelHandler.Handle)
 
-	v1.Put("/confirm-reservation", confirmHandler.Handle)
+	v1.Put("/confirm-reservation", optionalAuth, idempotent, ifMatch, confirmHandler.Handle)
+
+	// El lote aplica muchos movimientos a la vez: requiere token
+	v1.Post("/batch", middleware.AuthMiddleware(authService), idempotent, batchStockHandler.Handle)
+
+	// Cycle count routes
+	v1.Post("/counts", middleware.AuthMiddleware(authService), idempotent, openStockCountHandler.Handle)
+	v1.Get("/counts/:countId", middleware.AuthMiddleware(authService), getStockCountHandler.Handle)
+	v1.Put("/counts/:countId/lines", middleware.AuthMiddleware(authService), idempotent, submitStockCountHandler.Handle)
+	v1.Post("/counts/:countId/post", middleware.AuthMiddleware(authService), idempotent, postStockCountHandler.Handle)
+	v1.Post("/counts/:countId/cancel", middleware.AuthMiddleware(authService), idempotent, cancelStockCountHandler.Handle)
+
+	// Bulk import and export routes
+	v1.Post("/import", middleware.AuthMiddleware(authService), idempotentOutcome, importStocksHandler.Handle)
+	v1.Get("/export", middleware.AuthMiddleware(authService), exportStocksHandler.Handle)
 
 	// Low stock and alerts routes
 	v1.Get("/low-stock", lowStockHandler.Handle)
 
-	// Configurar consumidores de RabbitMQ
-	if rabbitMQ != nil {
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
-
-		// Insufficient Stock Publisher
-		insufficientStockPublisher, err := messaging.NewInsufficientStockPublisher(rabbitMQ.GetConnection())
-		if err != nil {
-			log.Printf("Warning: Failed to create insufficient stock publisher: %v", err)
-		} else {
-			defer insufficientStockPublisher.Close()
-		}
+	// Dead letter admin routes
+	v1.Get("/admin/dead-letters", middleware.AuthMiddleware(authService), listDeadLettersHandler.Handle)
+	v1.Post("/admin/dead-letters/:queue/:messageId/replay", middleware.AuthMiddleware(authService), replayDeadLetterHandler.Handle)
+	v1.Delete("/admin/dead-letters/:queue/:messageId", middleware.AuthMiddleware(authService), discardDeadLetterHandler.Handle)
 
-		// Order Placed Consumer
-		orderPlacedConsumer, err := messaging.NewOrderPlacedConsumer(stockService, rabbitMQ.GetConnection(), insufficientStockPublisher)
-		if err != nil {
-			log.Printf("Warning: Failed to create order placed consumer: %v", err)
-		} else {
-			if err := orderPlacedConsumer.StartConsuming(ctx); err != nil {
-				log.Printf("Warning: Failed to start order placed consumer: %v", err)
-			} else {
-				defer orderPlacedConsumer.Close()
-			}
-		}
+	// Reconciliation admin routes
+	v1.Get("/admin/reconciliation", middleware.AuthMiddleware(authService), getReconciliationReportHandler.Handle)
 
-		// Order Confirmed Consumer
-		orderConfirmedConsumer, err := messaging.NewOrderConfirmedConsumer(stockService, rabbitMQ.GetConnection())
-		if err != nil {
-			log.Printf("Warning: Failed to create order confirmed consumer: %v", err)
-		} else {
-			if err := orderConfirmedConsumer.StartConsuming(ctx); err != nil {
-				log.Printf("Warning: Failed to start order confirmed consumer: %v", err)
-			} else {
-				defer orderConfirmedConsumer.Close()
-			}
-		}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-		// Order Canceled Consumer
-		orderCanceledConsumer, err := messaging.NewOrderCanceledConsumer(stockService, rabbitMQ.GetConnection())
-		if err != nil {
-			log.Printf("Warning: Failed to create order canceled consumer: %v", err)
-		} else {
-			if err := orderCanceledConsumer.StartConsuming(ctx); err != nil {
-				log.Printf("Warning: Failed to start order canceled consumer: %v", err)
-			} else {
-				defer orderCanceledConsumer.Close()
-			}
-		}
-	}
+	// Purgado de claves de idempotencia vencidas
+	idempotencyKeyPurger := service.NewIdempotencyKeyPurger(idempotencyService, cfg.Idempotency.PurgeInterval)
+	idempotencyKeyPurger.Start(ctx)
+
+	// Barrido de reservas vencidas
+	reservationSweeper := service.NewReservationSweeper(stockService, cfg.Reservation.SweepInterval, cfg.Reservation.SweepBatchSize)
+	reservationSweeper.Start(ctx)
+
+	// Snapshots periódicas del stock para acotar las consultas históricas
+	stockSnapshotter := service.NewStockSnapshotter(historyService, cfg.Snapshot.Interval, cfg.Snapshot.SettleDelay)
+	stockSnapshotter.Start(ctx)
+
+	// Conciliación periódica de stocks contra el historial de eventos
+	stockReconciler := service.NewStockReconciler(reconciliationService, cfg.Reconciliation.Interval, cfg.Reconciliation.Repair)
+	stockReconciler.Start(ctx)
+
+	// Particiones mensuales de stock_events y archivado de las que superan la retención
+	stockEventArchiver := service.NewStockEventArchiver(archiveService, cfg.EventArchive.MaintenanceInterval,
+		cfg.EventArchive.PartitionsAhead, cfg.EventArchive.RetentionMonths)
+	stockEventArchiver.Start(ctx)
+
+	// Outbox Relay: publica los mensajes pendientes cuando hay conexión con RabbitMQ
+	outboxRelay := messaging.NewOutboxRelay(outboxRepo, rabbitMQ, cfg.Outbox.RelayInterval, cfg.Outbox.BatchSize, cfg.Outbox.Retention)
+	outboxRelay.Start(ctx)
+	defer outboxRelay.Close()
+
+	// Configurar consumidores de RabbitMQ; se reinician en cada reconexión
+	rabbitMQ.Register(
+		messaging.NewOrderPlacedConsumer(stockService, messageLedger, cfg.Retry),
+		messaging.NewOrderConfirmedConsumer(stockService, messageLedger, cfg.Retry),
+		messaging.NewOrderCanceledConsumer(stockService, messageLedger, cfg.Retry),
+	)
+	rabbitMQ.Start(ctx)
+	defer rabbitMQ.Close()
 
 	// Configurar graceful shutdown
 	c := make(chan os.Signal, 1)
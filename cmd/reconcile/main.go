@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/MatiasTelo/stockgo/internal/config"
+	"github.com/MatiasTelo/stockgo/internal/database"
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/MatiasTelo/stockgo/internal/service"
+)
+
+// reconcileCommandActor identifica al comando como actor de los eventos ADJUST
+const reconcileCommandActor = "reconcile-command"
+
+// Compara la tabla stocks con el historial de eventos; sale con código 2 si quedan discrepancias.
+// Uso: go run ./cmd/reconcile [-repair] [-json]
+func main() {
+	repair := flag.Bool("repair", false, "write ADJUST events so that each article's events match its stock")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	// Cargar configuración
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	db, err := database.NewDatabase(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	stockRepo := repository.NewStockRepository(db.PG, db.Redis)
+	txManager := repository.NewTxManager(db.PG)
+	stockService := service.NewStockService(
+		stockRepo,
+		repository.NewStockEventRepository(db.PG),
+		repository.NewReservationRepository(db.PG),
+		txManager,
+		service.Publishers{},
+		cfg.Reservation.TTL,
+	)
+	reconciliationService := service.NewStockReconciliationService(
+		stockService, stockRepo, repository.NewStockReconciliationRepository(db.PG), txManager)
+
+	ctx := models.ContextWithAttribution(context.Background(), models.Attribution{
+		ActorType: models.ActorTypeSystem,
+		ActorID:   reconcileCommandActor,
+	})
+
+	report, err := reconciliationService.Reconcile(ctx, *repair)
+	if err != nil {
+		log.Fatal("Reconciliation failed: ", err)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatal("Failed to encode report:", err)
+		}
+	} else {
+		printReport(report)
+	}
+
+	if report.Run.Discrepancies > report.Run.Repaired {
+		db.Close()
+		os.Exit(2)
+	}
+}
+
+func printReport(report *models.ReconciliationReport) {
+	run := report.Run
+	fmt.Printf("Reconciliation run %s: %d article(s) checked, %d discrepancy(ies), %d repaired\n",
+		run.ID, run.ArticlesChecked, run.Discrepancies, run.Repaired)
+
+	if len(report.Discrepancies) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARTICLE\tSTOCK QTY\tLEDGER QTY\tQTY DRIFT\tSTOCK RSV\tLEDGER RSV\tRSV DRIFT\tREPAIRED")
+	for _, d := range report.Discrepancies {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%+d\t%s\t%d\t%+d\t%t\n",
+			d.ArticleID, formatBalance(d.StockQuantity), d.LedgerQuantity, d.QuantityDrift,
+			formatBalance(d.StockReserved), d.LedgerReserved, d.ReservedDrift, d.Repaired)
+	}
+	w.Flush()
+}
+
+// formatBalance muestra "-" para los artículos sin fila en stocks
+func formatBalance(balance *int) string {
+	if balance == nil {
+		return "-"
+	}
+	return fmt.Sprint(*balance)
+}
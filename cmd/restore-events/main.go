@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/MatiasTelo/stockgo/internal/config"
+	"github.com/MatiasTelo/stockgo/internal/database"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/MatiasTelo/stockgo/internal/service"
+)
+
+// Carga un archivo de eventos archivado en una tabla independiente para consultarlo.
+// Uso: go run ./cmd/restore-events -file archive/stock_events/stock_events_2024_01.ndjson.gz [-table nombre]
+func main() {
+	path := flag.String("file", "", "archive file to restore (.ndjson.gz)")
+	table := flag.String("table", "", "table to restore into (default stock_events_restored_YYYY_MM)")
+	flag.Parse()
+
+	if *path == "" {
+		log.Fatal("Usage: go run ./cmd/restore-events -file <archive.ndjson.gz> [-table <name>]")
+	}
+
+	// Cargar configuración
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	db, err := database.NewDatabase(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database:", err)
+	}
+	defer db.Close()
+
+	partitionRepo := repository.NewStockEventPartitionRepository(db.PG)
+	archiveService := service.NewStockEventArchiveService(partitionRepo, nil, repository.NewTxManager(db.PG), cfg.EventArchive.Dir)
+
+	restoredTable, restored, err := archiveService.RestoreArchive(context.Background(), *path, *table)
+	if err != nil {
+		log.Fatal("Restore failed: ", err)
+	}
+
+	fmt.Printf("Restored %d event(s) from %s into table %s\n", restored, *path, restoredTable)
+}
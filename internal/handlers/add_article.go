@@ -52,20 +52,13 @@ func (h *AddArticleHandler) Handle(c *fiber.Ctx) error {
 		})
 	}
 
-	stock, err := h.stockService.CreateStock(c.Context(), &req)
+	stock, err := h.stockService.CreateStock(c.UserContext(), &req)
 	if err != nil {
-		if err.Error() == "article with ID "+req.ArticleID+" already exists" {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
-
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to create stock",
-			"details": err.Error(),
-		})
+		return respondError(c, err, "Failed to create stock")
 	}
 
+	setStockETag(c, stock)
+
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Article added successfully",
 		"data":    stock,
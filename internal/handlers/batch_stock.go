@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BatchStockHandler struct {
+	batchService *service.StockBatchService
+}
+
+func NewBatchStockHandler(batchService *service.StockBatchService) *BatchStockHandler {
+	return &BatchStockHandler{
+		batchService: batchService,
+	}
+}
+
+// POST /api/stock/batch
+// Requiere autenticación mediante token Bearer
+func (h *BatchStockHandler) Handle(c *fiber.Ctx) error {
+	var req models.StockBatchRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	result, err := h.batchService.Execute(c.UserContext(), &req)
+	if err != nil {
+		var failed *models.ErrBatchOperationFailed
+		if !errors.As(err, &failed) {
+			return respondError(c, err, "Failed to execute batch")
+		}
+
+		// El lote se deshizo: se responde con el error de la operación que falló
+		status, body, ok := describeError(failed.Err)
+		if !ok {
+			return respondError(c, err, "Failed to execute batch")
+		}
+		body["operation_index"] = failed.Index
+		body["data"] = batchResultResponse(failed.Result)
+		return c.Status(status).JSON(body)
+	}
+
+	message := "Batch applied successfully"
+	if result.Failed > 0 {
+		message = "Batch applied with failed operations"
+	}
+
+	return c.JSON(fiber.Map{
+		"message": message,
+		"data":    batchResultResponse(result),
+	})
+}
+
+// batchResultResponse arma el resultado del lote con el error de cada operación fallida en
+// el mismo formato que las respuestas de error de los endpoints individuales
+func batchResultResponse(result *models.StockBatchResult) fiber.Map {
+	operations := make([]fiber.Map, 0, len(result.Operations))
+	for _, op := range result.Operations {
+		entry := fiber.Map{
+			"index":      op.Index,
+			"type":       op.Type,
+			"article_id": op.ArticleID,
+			"status":     op.Status,
+		}
+		if op.OrderID != "" {
+			entry["order_id"] = op.OrderID
+		}
+		if op.Stock != nil {
+			entry["stock"] = op.Stock
+		}
+		if op.Err != nil {
+			_, body, ok := describeError(op.Err)
+			if !ok {
+				body = errorBody(ErrorCodeInternal, "Operation failed", op.Err)
+			}
+			entry["error"] = body
+		}
+		operations = append(operations, entry)
+	}
+
+	return fiber.Map{
+		"batch_id":   result.BatchID,
+		"mode":       result.Mode,
+		"applied":    result.Applied,
+		"failed":     result.Failed,
+		"operations": operations,
+	}
+}
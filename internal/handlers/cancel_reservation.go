@@ -46,32 +46,14 @@ func (h *CancelReservationHandler) Handle(c *fiber.Ctx) error {
 	}
 
 	// Cancelar la reserva usando el nuevo método que busca por order_id
-	err := h.stockService.CancelReservationByOrderID(c.Context(), req.OrderID, req.ArticleID, req.Reason)
+	err := h.stockService.CancelReservationByOrderID(c.UserContext(), req.OrderID, req.ArticleID, req.Reason)
 	if err != nil {
-		if err.Error() == "no active reservation found for this order and article" {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "No active reservation found for the specified order_id and article_id",
-			})
-		}
-		if err.Error() == "reservation has already been cancelled" {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error": "Reservation has already been cancelled",
-			})
-		}
-		if err.Error() == "reservation has already been confirmed" {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error": "Cannot cancel a confirmed reservation",
-			})
-		}
-
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to cancel reservation",
-			"details": err.Error(),
-		})
+		return respondError(c, err, "Failed to cancel reservation")
 	}
 
 	// Get updated stock info to return
-	stock, _ := h.stockService.GetStock(c.Context(), req.ArticleID)
+	stock, _ := h.stockService.GetCurrentStock(c.UserContext(), req.ArticleID)
+	setStockETag(c, stock)
 
 	return c.JSON(fiber.Map{
 		"message": "Reservation cancelled successfully",
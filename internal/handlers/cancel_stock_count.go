@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type CancelStockCountHandler struct {
+	countService *service.StockCountService
+}
+
+func NewCancelStockCountHandler(countService *service.StockCountService) *CancelStockCountHandler {
+	return &CancelStockCountHandler{
+		countService: countService,
+	}
+}
+
+// POST /api/stock/counts/:countId/cancel
+// Requiere autenticación mediante token Bearer
+func (h *CancelStockCountHandler) Handle(c *fiber.Ctx) error {
+	countID, err := uuid.Parse(c.Params("countId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "count_id must be a valid UUID",
+		})
+	}
+
+	session, err := h.countService.CancelCount(c.UserContext(), countID)
+	if err != nil {
+		return respondError(c, err, "Failed to cancel stock count")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Stock count cancelled successfully",
+		"data":    session,
+	})
+}
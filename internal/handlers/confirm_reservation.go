@@ -46,32 +46,14 @@ func (h *ConfirmReservationHandler) Handle(c *fiber.Ctx) error {
 	}
 
 	// Confirmar la reserva usando el servicio
-	err := h.stockService.ConfirmReservationByOrderID(c.Context(), req.OrderID, req.ArticleID, req.Reason)
+	err := h.stockService.ConfirmReservationByOrderID(c.UserContext(), req.OrderID, req.ArticleID, req.Reason)
 	if err != nil {
-		if err.Error() == "no active reservation found for this order and article" {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "No active reservation found for the specified order_id and article_id",
-			})
-		}
-		if err.Error() == "reservation has already been cancelled" {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error": "Cannot confirm a cancelled reservation",
-			})
-		}
-		if err.Error() == "reservation has already been confirmed" {
-			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
-				"error": "Reservation has already been confirmed",
-			})
-		}
-
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to confirm reservation",
-			"details": err.Error(),
-		})
+		return respondError(c, err, "Failed to confirm reservation")
 	}
 
 	// Get updated stock info to return
-	stock, _ := h.stockService.GetStock(c.Context(), req.ArticleID)
+	stock, _ := h.stockService.GetCurrentStock(c.UserContext(), req.ArticleID)
+	setStockETag(c, stock)
 
 	return c.JSON(fiber.Map{
 		"message": "Reservation confirmed successfully",
@@ -50,27 +50,13 @@ func (h *DeductStockHandler) Handle(c *fiber.Ctx) error {
 		req.Reason = "Manual stock deduction"
 	}
 
-	stock, err := h.stockService.DeductStock(c.Context(), req.ArticleID, req.Quantity, req.Reason)
+	stock, err := h.stockService.DeductStock(c.UserContext(), req.ArticleID, req.Quantity, req.Reason)
 	if err != nil {
-		if err.Error() == "article not found: stock not found for article_id: "+req.ArticleID {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Article not found",
-			})
-		}
-
-		// Check if it's an insufficient stock error
-		if len(err.Error()) > 19 && err.Error()[:19] == "insufficient stock:" {
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": err.Error(),
-			})
-		}
-
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to deduct stock",
-			"details": err.Error(),
-		})
+		return respondError(c, err, "Failed to deduct stock")
 	}
 
+	setStockETag(c, stock)
+
 	return c.JSON(fiber.Map{
 		"message": "Stock deducted successfully",
 		"data":    stock,
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/MatiasTelo/stockgo/internal/messaging"
+	"github.com/gofiber/fiber/v2"
+)
+
+type DiscardDeadLetterHandler struct {
+	deadLetterManager *messaging.DeadLetterManager
+}
+
+func NewDiscardDeadLetterHandler(deadLetterManager *messaging.DeadLetterManager) *DiscardDeadLetterHandler {
+	return &DiscardDeadLetterHandler{
+		deadLetterManager: deadLetterManager,
+	}
+}
+
+// DELETE /api/stock/admin/dead-letters/:queue/:messageId
+// Descarta definitivamente un mensaje aparcado. Requiere autenticación mediante token Bearer
+func (h *DiscardDeadLetterHandler) Handle(c *fiber.Ctx) error {
+	queue := c.Params("queue")
+	if !h.deadLetterManager.IsManagedQueue(queue) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Unknown queue",
+			"queues": h.deadLetterManager.Queues(),
+		})
+	}
+
+	messageID := c.Params("messageId")
+	if messageID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "message_id is required",
+		})
+	}
+
+	parked, err := h.deadLetterManager.Discard(c.UserContext(), queue, messageID)
+	if err != nil {
+		if errors.Is(err, messaging.ErrParkedMessageNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Parked message not found",
+			})
+		}
+		if errors.Is(err, messaging.ErrNotConnected) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "RabbitMQ is not available",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to discard parked message",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Message discarded successfully",
+		"data":    parked,
+	})
+}
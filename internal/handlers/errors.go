@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Códigos de error legibles por máquinas incluidos en el campo "code" de las respuestas
+const (
+	ErrorCodeArticleNotFound     = "ARTICLE_NOT_FOUND"
+	ErrorCodeArticleExists       = "ARTICLE_ALREADY_EXISTS"
+	ErrorCodeInsufficientStock   = "INSUFFICIENT_STOCK"
+	ErrorCodeDuplicateReserve    = "DUPLICATE_RESERVATION"
+	ErrorCodeReservationNotFound = "RESERVATION_NOT_FOUND"
+	ErrorCodeReservationClosed   = "RESERVATION_CLOSED"
+	ErrorCodeReservedMismatch    = "INSUFFICIENT_RESERVED_STOCK"
+	ErrorCodeInvalidOrder        = "INVALID_ORDER"
+	ErrorCodeInvalidCursor       = "INVALID_CURSOR"
+	ErrorCodeReconciliationRun   = "RECONCILIATION_RUN_NOT_FOUND"
+	ErrorCodeHistoryArchived     = "HISTORY_ARCHIVED"
+	ErrorCodeInvalidSettings     = "INVALID_STOCK_SETTINGS"
+	ErrorCodeInvalidStatus       = "INVALID_STOCK_STATUS"
+	ErrorCodeArticleUnavailable  = "ARTICLE_UNAVAILABLE"
+	ErrorCodeStockCountNotFound  = "STOCK_COUNT_NOT_FOUND"
+	ErrorCodeStockCountOpen      = "STOCK_COUNT_ALREADY_OPEN"
+	ErrorCodeStockCountClosed    = "STOCK_COUNT_CLOSED"
+	ErrorCodeInvalidStockCount   = "INVALID_STOCK_COUNT"
+	ErrorCodeCountReservation    = "COUNT_RESERVATION_CONFLICT"
+	ErrorCodeInvalidImport       = "INVALID_IMPORT"
+	ErrorCodeImportRowsInvalid   = "IMPORT_ROWS_INVALID"
+	ErrorCodeInvalidBatch        = "INVALID_BATCH"
+	ErrorCodeIdempotencyReused   = "IDEMPOTENCY_KEY_REUSED"
+	ErrorCodeIdempotencyBusy     = "IDEMPOTENCY_KEY_IN_PROGRESS"
+	ErrorCodeVersionMismatch     = "VERSION_MISMATCH"
+	ErrorCodeInternal            = "INTERNAL_ERROR"
+)
+
+// ErrorHandler es el manejador de errores de Fiber: traduce cualquier error retornado por
+// un handler o middleware con el mismo criterio que respondError
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	return respondError(c, err, "Internal server error")
+}
+
+// respondError traduce un error de dominio a su código HTTP y código de error. Los errores
+// no reconocidos se responden con 500 y el mensaje de la operación que falló.
+func respondError(c *fiber.Ctx, err error, fallback string) error {
+	status, body, ok := describeError(err)
+	if !ok {
+		status, body = fiber.StatusInternalServerError, errorBody(ErrorCodeInternal, fallback, err)
+	}
+	return c.Status(status).JSON(body)
+}
+
+// describeError retorna el código HTTP y el cuerpo de respuesta de un error de dominio; ok es
+// false si el error no es uno de los reconocidos
+func describeError(err error) (status int, body fiber.Map, ok bool) {
+	var insufficientStock *models.ErrInsufficientStock
+	var reservationClosed *models.ErrReservationClosed
+	var articleUnavailable *models.ErrArticleUnavailable
+	var countReservation *models.ErrCountReservationConflict
+	var versionMismatch *models.ErrVersionMismatch
+	var fiberErr *fiber.Error
+
+	switch {
+	case errors.As(err, &insufficientStock):
+		return fiber.StatusBadRequest, fiber.Map{
+			"error":      "Insufficient stock",
+			"code":       ErrorCodeInsufficientStock,
+			"details":    err.Error(),
+			"article_id": insufficientStock.ArticleID,
+			"available":  insufficientStock.Available,
+			"requested":  insufficientStock.Requested,
+		}, true
+
+	case errors.As(err, &reservationClosed):
+		return fiber.StatusConflict, fiber.Map{
+			"error":   "Reservation is no longer active",
+			"code":    ErrorCodeReservationClosed,
+			"details": err.Error(),
+			"status":  reservationClosed.Status,
+		}, true
+
+	case errors.As(err, &articleUnavailable):
+		return fiber.StatusConflict, fiber.Map{
+			"error":      "Article does not accept this operation in its current status",
+			"code":       ErrorCodeArticleUnavailable,
+			"details":    err.Error(),
+			"article_id": articleUnavailable.ArticleID,
+			"status":     articleUnavailable.Status,
+		}, true
+
+	case errors.As(err, &countReservation):
+		return fiber.StatusConflict, fiber.Map{
+			"error":       "Counted quantity is below reserved stock",
+			"code":        ErrorCodeCountReservation,
+			"details":     err.Error(),
+			"article_ids": countReservation.ArticleIDs,
+		}, true
+
+	case errors.As(err, &versionMismatch):
+		// Con If-Match, un artículo inexistente no tiene versión ni ETag que informar
+		if versionMismatch.CurrentVersion == 0 {
+			return fiber.StatusPreconditionFailed, fiber.Map{
+				"error":      "Article does not exist",
+				"code":       ErrorCodeVersionMismatch,
+				"details":    err.Error(),
+				"article_id": versionMismatch.ArticleID,
+			}, true
+		}
+		return fiber.StatusPreconditionFailed, fiber.Map{
+			"error":           "Article has changed since the version in If-Match",
+			"code":            ErrorCodeVersionMismatch,
+			"details":         err.Error(),
+			"article_id":      versionMismatch.ArticleID,
+			"current_version": versionMismatch.CurrentVersion,
+			"etag":            models.StockETag(versionMismatch.CurrentVersion),
+		}, true
+
+	case errors.Is(err, models.ErrArticleNotFound):
+		return fiber.StatusNotFound, errorBody(ErrorCodeArticleNotFound, "Article not found", err), true
+
+	case errors.Is(err, models.ErrArticleAlreadyExists):
+		return fiber.StatusConflict, errorBody(ErrorCodeArticleExists, "Article already exists", err), true
+
+	case errors.Is(err, models.ErrDuplicateReservation):
+		return fiber.StatusConflict, errorBody(ErrorCodeDuplicateReserve, "Order already has an active reservation for this article", err), true
+
+	case errors.Is(err, models.ErrReservationNotFound):
+		return fiber.StatusNotFound, errorBody(ErrorCodeReservationNotFound, "No active reservation found for the specified order_id and article_id", nil), true
+
+	case errors.Is(err, models.ErrInsufficientReservedStock):
+		return fiber.StatusConflict, errorBody(ErrorCodeReservedMismatch, "Reserved stock does not cover the reservation", err), true
+
+	case errors.Is(err, models.ErrInvalidOrder):
+		return fiber.StatusBadRequest, errorBody(ErrorCodeInvalidOrder, "Invalid order", err), true
+
+	case errors.Is(err, models.ErrInvalidStockSettings):
+		return fiber.StatusBadRequest, errorBody(ErrorCodeInvalidSettings, "Invalid stock settings", err), true
+
+	case errors.Is(err, models.ErrInvalidStockStatus):
+		return fiber.StatusBadRequest, errorBody(ErrorCodeInvalidStatus, "Invalid stock status", err), true
+
+	case errors.Is(err, models.ErrStockCountNotFound):
+		return fiber.StatusNotFound, errorBody(ErrorCodeStockCountNotFound, "Stock count not found", nil), true
+
+	case errors.Is(err, models.ErrStockCountAlreadyOpen):
+		return fiber.StatusConflict, errorBody(ErrorCodeStockCountOpen, "Location already has an open stock count", err), true
+
+	case errors.Is(err, models.ErrStockCountClosed):
+		return fiber.StatusConflict, errorBody(ErrorCodeStockCountClosed, "Stock count is no longer open", err), true
+
+	case errors.Is(err, models.ErrInvalidStockCount):
+		return fiber.StatusBadRequest, errorBody(ErrorCodeInvalidStockCount, "Invalid stock count", err), true
+
+	case errors.Is(err, models.ErrInvalidImport):
+		return fiber.StatusBadRequest, errorBody(ErrorCodeInvalidImport, "Invalid import file", err), true
+
+	case errors.Is(err, models.ErrInvalidBatch):
+		return fiber.StatusBadRequest, errorBody(ErrorCodeInvalidBatch, "Invalid batch", err), true
+
+	case errors.Is(err, models.ErrIdempotencyKeyReused):
+		return fiber.StatusUnprocessableEntity, errorBody(ErrorCodeIdempotencyReused, "Idempotency-Key was already used with a different request", err), true
+
+	case errors.Is(err, models.ErrIdempotencyKeyInProgress):
+		return fiber.StatusConflict, errorBody(ErrorCodeIdempotencyBusy, "A request with this Idempotency-Key is still in progress", err), true
+
+	case errors.Is(err, models.ErrInvalidCursor):
+		return fiber.StatusBadRequest, errorBody(ErrorCodeInvalidCursor, "Invalid cursor", err), true
+
+	case errors.Is(err, models.ErrReconciliationRunNotFound):
+		return fiber.StatusNotFound, errorBody(ErrorCodeReconciliationRun, "Reconciliation run not found", nil), true
+
+	case errors.Is(err, models.ErrHistoryArchived):
+		return fiber.StatusGone, errorBody(ErrorCodeHistoryArchived, "Stock history for that time is archived", err), true
+
+	case errors.As(err, &fiberErr):
+		code := strings.ToUpper(strings.ReplaceAll(utils.StatusMessage(fiberErr.Code), " ", "_"))
+		return fiberErr.Code, fiber.Map{
+			"error": fiberErr.Message,
+			"code":  code,
+		}, true
+	}
+
+	return 0, nil, false
+}
+
+// errorBody arma el cuerpo de una respuesta de error con el formato común
+func errorBody(code, message string, err error) fiber.Map {
+	body := fiber.Map{
+		"error": message,
+		"code":  code,
+	}
+	if err != nil {
+		body["details"] = err.Error()
+	}
+	return body
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"bufio"
+	"log"
+	"strings"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ExportStocksHandler struct {
+	importService *service.StockImportService
+}
+
+func NewExportStocksHandler(importService *service.StockImportService) *ExportStocksHandler {
+	return &ExportStocksHandler{
+		importService: importService,
+	}
+}
+
+// GET /api/stock/export?format=csv|ndjson
+// Requiere autenticación mediante token Bearer
+func (h *ExportStocksHandler) Handle(c *fiber.Ctx) error {
+	format := models.StockFileFormat(strings.ToLower(c.Query("format", string(models.StockFileFormatCSV))))
+	if !format.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or ndjson",
+		})
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == models.StockFileFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	c.Set(fiber.HeaderContentType, contentType)
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="stocks.`+string(format)+`"`)
+
+	// El contexto de Fiber se recicla al terminar el handler; el stream solo usa el contexto
+	// de la request
+	ctx := c.UserContext()
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		exported, err := h.importService.Export(ctx, format, w)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			// El estado ya fue enviado: la exportación queda truncada
+			log.Printf("ExportStocks: Error exporting stocks after %d row(s): %v", exported, err)
+		}
+	})
+
+	return nil
+}
@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
 	"github.com/MatiasTelo/stockgo/internal/service"
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,21 +21,115 @@ func NewGetAllArticlesHandler(stockService *service.StockService) *GetAllArticle
 }
 
 // GET /api/stock/articles
+// Filtros opcionales: location, low_stock, min_available, max_available, status (separados
+// por coma), updated_since (RFC3339); orden con sort y order (asc|desc); cursor y limit
 // Requiere autenticación mediante token Bearer
 func (h *GetAllArticlesHandler) Handle(c *fiber.Ctx) error {
 	// El token ya fue validado por el middleware AuthMiddleware
 	// y está disponible en c.Locals("token")
 
-	stocks, err := h.stockService.GetAllStocks(c.Context())
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to retrieve stocks",
-			"details": err.Error(),
+	filter, message := parseStockFilter(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"data":  stocks,
-		"count": len(stocks),
-	})
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := models.DecodeStockCursor(cursor)
+		if err != nil {
+			return respondError(c, err, "Invalid cursor")
+		}
+		filter.After = after
+	}
+
+	page, err := h.stockService.ListStocks(c.UserContext(), filter)
+	if err != nil {
+		return respondError(c, err, "Failed to retrieve stocks")
+	}
+
+	return c.JSON(page)
+}
+
+// parseStockFilter interpreta los filtros y el orden del listado de artículos. Retorna un
+// mensaje de error si algún parámetro es inválido; el cursor se valida al decodificarlo.
+func parseStockFilter(c *fiber.Ctx) (models.StockFilter, string) {
+	filter := models.StockFilter{
+		Location: c.Query("location"),
+	}
+
+	if lowStock := c.Query("low_stock"); lowStock != "" {
+		parsed, err := strconv.ParseBool(lowStock)
+		if err != nil {
+			return filter, "low_stock must be true or false"
+		}
+		filter.LowStock = parsed
+	}
+
+	for _, param := range []struct {
+		name   string
+		target **int
+	}{
+		{"min_available", &filter.MinAvailable},
+		{"max_available", &filter.MaxAvailable},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return filter, param.name + " must be an integer"
+		}
+		*param.target = &parsed
+	}
+
+	if statuses := c.Query("status"); statuses != "" {
+		for _, value := range strings.Split(statuses, ",") {
+			status := models.StockStatus(strings.ToUpper(strings.TrimSpace(value)))
+			if !status.IsValid() {
+				return filter, "invalid status: " + value
+			}
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	if updatedSince := c.Query("updated_since"); updatedSince != "" {
+		parsed, err := time.Parse(time.RFC3339, updatedSince)
+		if err != nil {
+			return filter, "updated_since must be an RFC3339 timestamp"
+		}
+		filter.UpdatedSince = &parsed
+	}
+
+	// Sin sort se listan los más recientes primero; con sort, el orden por defecto es ascendente
+	filter.Sort = models.StockSortCreatedAt
+	filter.Desc = true
+	if sort := c.Query("sort"); sort != "" {
+		filter.Sort = models.StockSortField(strings.ToLower(sort))
+		filter.Desc = false
+		if !filter.Sort.IsValid() {
+			return filter, "sort must be one of created_at, updated_at, quantity, reserved, available, min_stock, max_stock"
+		}
+	}
+
+	switch strings.ToLower(c.Query("order")) {
+	case "":
+	case "asc":
+		filter.Desc = false
+	case "desc":
+		filter.Desc = true
+	default:
+		return filter, "order must be asc or desc"
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit <= 0 {
+			return filter, "limit must be a positive integer"
+		}
+		filter.Limit = parsedLimit
+	}
+
+	return filter, ""
 }
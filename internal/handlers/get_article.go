@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"github.com/MatiasTelo/stockgo/internal/models"
 	"github.com/MatiasTelo/stockgo/internal/service"
 	"github.com/gofiber/fiber/v2"
 )
@@ -16,7 +17,8 @@ func NewGetArticleHandler(stockService *service.StockService) *GetArticleHandler
 }
 
 // GET /api/stock/articles/:articleId
-// Requiere autenticación mediante token Bearer
+// Lee el artículo sin cache para que el ETag y la comparación con If-None-Match usen la
+// versión vigente. Requiere autenticación mediante token Bearer
 func (h *GetArticleHandler) Handle(c *fiber.Ctx) error {
 	// El token ya fue validado por el middleware AuthMiddleware
 	// y está disponible en c.Locals("token")
@@ -28,14 +30,24 @@ func (h *GetArticleHandler) Handle(c *fiber.Ctx) error {
 		})
 	}
 
-	stock, err := h.stockService.GetStock(c.Context(), articleID)
+	stock, err := h.stockService.GetCurrentStock(c.UserContext(), articleID)
 	if err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "Article not found",
-		})
+		return respondError(c, err, "Failed to get article")
+	}
+
+	setStockETag(c, stock)
+	if c.Get(fiber.HeaderIfNoneMatch) == models.StockETag(stock.Version) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
 	return c.JSON(fiber.Map{
 		"data": stock,
 	})
 }
+
+// setStockETag informa la versión del artículo en el header ETag, para usarla en If-Match
+func setStockETag(c *fiber.Ctx, stock *models.Stock) {
+	if stock != nil {
+		c.Set(fiber.HeaderETag, models.StockETag(stock.Version))
+	}
+}
@@ -1,8 +1,6 @@
 package handlers
 
 import (
-	"strconv"
-
 	"github.com/MatiasTelo/stockgo/internal/service"
 	"github.com/gofiber/fiber/v2"
 )
@@ -18,6 +16,7 @@ func NewGetArticleEventsHandler(stockService *service.StockService) *GetArticleE
 }
 
 // GET /api/stock/articles/:articleId/events
+// Admite los mismos filtros y paginación que GET /api/stock/events
 // Requiere autenticación mediante token Bearer
 func (h *GetArticleEventsHandler) Handle(c *fiber.Ctx) error {
 	// El token ya fue validado por el middleware AuthMiddleware
@@ -30,24 +29,15 @@ func (h *GetArticleEventsHandler) Handle(c *fiber.Ctx) error {
 		})
 	}
 
-	// Parse limit parameter
-	limit := 50 // default
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
-	}
-
-	events, err := h.stockService.GetStockEvents(c.Context(), articleID, limit)
-	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to retrieve stock events",
-			"details": err.Error(),
+	filter, message := parseEventFilter(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"data":  events,
-		"count": len(events),
-	})
+	filter.ArticleID = articleID
+	filter.OrderID = c.Query("order_id")
+
+	return respondEventPage(c, h.stockService, filter)
 }
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type GetOrderEventsHandler struct {
+	stockService *service.StockService
+}
+
+func NewGetOrderEventsHandler(stockService *service.StockService) *GetOrderEventsHandler {
+	return &GetOrderEventsHandler{
+		stockService: stockService,
+	}
+}
+
+// GET /api/stock/orders/:orderId/events
+// Requiere autenticación mediante token Bearer
+func (h *GetOrderEventsHandler) Handle(c *fiber.Ctx) error {
+	orderID := c.Params("orderId")
+	if orderID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "order_id is required",
+		})
+	}
+
+	filter, message := parseEventFilter(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	filter.OrderID = orderID
+	filter.ArticleID = c.Query("article_id")
+
+	return respondEventPage(c, h.stockService, filter)
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type GetReconciliationReportHandler struct {
+	reconciliationService *service.StockReconciliationService
+}
+
+func NewGetReconciliationReportHandler(reconciliationService *service.StockReconciliationService) *GetReconciliationReportHandler {
+	return &GetReconciliationReportHandler{
+		reconciliationService: reconciliationService,
+	}
+}
+
+// GET /api/stock/admin/reconciliation?run_id=...
+// Requiere autenticación mediante token Bearer
+func (h *GetReconciliationReportHandler) Handle(c *fiber.Ctx) error {
+	var runID *uuid.UUID
+	if raw := c.Query("run_id"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "run_id must be a valid UUID",
+			})
+		}
+		runID = &id
+	}
+
+	report, err := h.reconciliationService.GetReport(c.UserContext(), runID)
+	if err != nil {
+		return respondError(c, err, "Failed to get reconciliation report")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": report,
+	})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"strings"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxAsOfArticles limita la cantidad de artículos de una consulta histórica masiva
+const maxAsOfArticles = 500
+
+type GetStockAsOfHandler struct {
+	historyService *service.StockHistoryService
+}
+
+func NewGetStockAsOfHandler(historyService *service.StockHistoryService) *GetStockAsOfHandler {
+	return &GetStockAsOfHandler{
+		historyService: historyService,
+	}
+}
+
+// GET /api/stock/articles/:articleId/as-of?ts=2024-01-31T23:59:59Z
+// Requiere autenticación mediante token Bearer
+func (h *GetStockAsOfHandler) Handle(c *fiber.Ctx) error {
+	articleID := c.Params("articleId")
+	if articleID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "article_id is required",
+		})
+	}
+
+	ts, message := parseAsOfTimestamp(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	stock, err := h.historyService.GetStockAsOf(c.UserContext(), articleID, ts)
+	if err != nil {
+		return respondError(c, err, "Failed to get stock as of timestamp")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": stock,
+	})
+}
+
+// GET /api/stock/as-of?ts=2024-01-31T23:59:59Z&article_ids=A,B,C
+// Requiere autenticación mediante token Bearer
+func (h *GetStockAsOfHandler) HandleBulk(c *fiber.Ctx) error {
+	ts, message := parseAsOfTimestamp(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	var articleIDs []string
+	seen := make(map[string]bool)
+	for _, articleID := range strings.Split(c.Query("article_ids"), ",") {
+		articleID = strings.TrimSpace(articleID)
+		if articleID == "" || seen[articleID] {
+			continue
+		}
+		seen[articleID] = true
+		articleIDs = append(articleIDs, articleID)
+	}
+
+	if len(articleIDs) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "article_ids is required",
+		})
+	}
+	if len(articleIDs) > maxAsOfArticles {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "article_ids cannot contain more than 500 articles",
+		})
+	}
+
+	stocks, missing, err := h.historyService.GetStocksAsOf(c.UserContext(), articleIDs, ts)
+	if err != nil {
+		return respondError(c, err, "Failed to get stock as of timestamp")
+	}
+
+	if missing == nil {
+		missing = []string{}
+	}
+
+	return c.JSON(fiber.Map{
+		"data":      stocks,
+		"count":     len(stocks),
+		"not_found": missing,
+		"as_of":     ts,
+	})
+}
+
+// parseAsOfTimestamp lee el parámetro ts (RFC 3339). Retorna un mensaje de error si falta,
+// no es válido o es posterior al momento actual.
+func parseAsOfTimestamp(c *fiber.Ctx) (time.Time, string) {
+	raw := c.Query("ts")
+	if raw == "" {
+		return time.Time{}, "ts is required"
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, "ts must be an RFC 3339 timestamp"
+	}
+	if ts.After(time.Now()) {
+		return time.Time{}, "ts cannot be in the future"
+	}
+
+	return ts.UTC(), ""
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type GetStockCountHandler struct {
+	countService *service.StockCountService
+}
+
+func NewGetStockCountHandler(countService *service.StockCountService) *GetStockCountHandler {
+	return &GetStockCountHandler{
+		countService: countService,
+	}
+}
+
+// GET /api/stock/counts/:countId
+// Requiere autenticación mediante token Bearer
+func (h *GetStockCountHandler) Handle(c *fiber.Ctx) error {
+	countID, err := uuid.Parse(c.Params("countId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "count_id must be a valid UUID",
+		})
+	}
+
+	report, err := h.countService.GetReport(c.UserContext(), countID)
+	if err != nil {
+		return respondError(c, err, "Failed to get stock count")
+	}
+
+	return c.JSON(fiber.Map{
+		"data": report,
+	})
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ImportStocksHandler struct {
+	importService *service.StockImportService
+}
+
+func NewImportStocksHandler(importService *service.StockImportService) *ImportStocksHandler {
+	return &ImportStocksHandler{
+		importService: importService,
+	}
+}
+
+// POST /api/stock/import?mode=create|upsert&dry_run=true&format=csv|ndjson
+// Requiere autenticación mediante token Bearer
+func (h *ImportStocksHandler) Handle(c *fiber.Ctx) error {
+	format := models.StockFileFormat(strings.ToLower(c.Query("format")))
+	if format == "" {
+		format = stockFileFormatFromContentType(c.Get(fiber.HeaderContentType))
+	}
+	if !format.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "format must be csv or ndjson (use the format parameter or a text/csv or application/x-ndjson Content-Type)",
+		})
+	}
+
+	mode := models.StockImportMode(strings.ToLower(c.Query("mode", string(models.StockImportModeCreate))))
+	if !mode.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "mode must be create or upsert",
+		})
+	}
+
+	dryRun := false
+	if raw := c.Query("dry_run"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "dry_run must be true or false",
+			})
+		}
+		dryRun = value
+	}
+
+	result, err := h.importService.Import(c.UserContext(), bytes.NewReader(c.Body()), format, mode, dryRun)
+	if err != nil {
+		return respondError(c, err, "Failed to import articles")
+	}
+
+	if result.HasErrors() {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "Some rows are invalid, no articles were imported",
+			"code":  ErrorCodeImportRowsInvalid,
+			"data":  result,
+		})
+	}
+
+	message := "Articles imported successfully"
+	if dryRun {
+		message = "Dry run completed, no articles were imported"
+	}
+
+	return c.JSON(fiber.Map{
+		"message": message,
+		"data":    result,
+	})
+}
+
+// stockFileFormatFromContentType deduce el formato del archivo a partir del Content-Type
+func stockFileFormatFromContentType(contentType string) models.StockFileFormat {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	switch strings.TrimSpace(mediaType) {
+	case "text/csv", "application/csv":
+		return models.StockFileFormatCSV
+	case "application/x-ndjson", "application/ndjson", "application/jsonl", "application/x-jsonlines":
+		return models.StockFileFormatNDJSON
+	default:
+		return ""
+	}
+}
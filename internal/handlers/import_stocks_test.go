@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+)
+
+func TestStockFileFormatFromContentType(t *testing.T) {
+	tests := []struct {
+		contentType string
+		want        models.StockFileFormat
+	}{
+		{"text/csv", models.StockFileFormatCSV},
+		{"text/csv; charset=utf-8", models.StockFileFormatCSV},
+		{"Application/CSV", models.StockFileFormatCSV},
+		{"application/x-ndjson", models.StockFileFormatNDJSON},
+		{"application/ndjson ; charset=utf-8", models.StockFileFormatNDJSON},
+		{"application/jsonl", models.StockFileFormatNDJSON},
+		{"application/x-jsonlines", models.StockFileFormatNDJSON},
+		{"application/json", ""},
+		{"multipart/form-data; boundary=x", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.contentType, func(t *testing.T) {
+			if got := stockFileFormatFromContentType(tt.contentType); got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/MatiasTelo/stockgo/internal/messaging"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ListDeadLettersHandler struct {
+	deadLetterManager *messaging.DeadLetterManager
+}
+
+func NewListDeadLettersHandler(deadLetterManager *messaging.DeadLetterManager) *ListDeadLettersHandler {
+	return &ListDeadLettersHandler{
+		deadLetterManager: deadLetterManager,
+	}
+}
+
+// GET /api/stock/admin/dead-letters?queue=order_placed_stock&limit=50
+// Lista los mensajes aparcados en las colas *.dlq. Requiere autenticación mediante token Bearer
+func (h *ListDeadLettersHandler) Handle(c *fiber.Ctx) error {
+	// Parse limit parameter
+	limit := 50 // default
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	queues := h.deadLetterManager.Queues()
+	if queue := c.Query("queue"); queue != "" {
+		if !h.deadLetterManager.IsManagedQueue(queue) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":  "Unknown queue",
+				"queues": queues,
+			})
+		}
+		queues = []string{queue}
+	}
+
+	var parked []*messaging.ParkedMessage
+	for _, queue := range queues {
+		messages, err := h.deadLetterManager.ListParked(queue, limit)
+		if err != nil {
+			if errors.Is(err, messaging.ErrNotConnected) {
+				return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+					"error": "RabbitMQ is not available",
+				})
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "Failed to retrieve parked messages",
+				"details": err.Error(),
+			})
+		}
+		parked = append(parked, messages...)
+	}
+
+	return c.JSON(fiber.Map{
+		"data":  parked,
+		"count": len(parked),
+	})
+}
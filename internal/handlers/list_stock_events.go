@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ListStockEventsHandler struct {
+	stockService *service.StockService
+}
+
+func NewListStockEventsHandler(stockService *service.StockService) *ListStockEventsHandler {
+	return &ListStockEventsHandler{
+		stockService: stockService,
+	}
+}
+
+// GET /api/stock/events
+// Requiere autenticación mediante token Bearer
+func (h *ListStockEventsHandler) Handle(c *fiber.Ctx) error {
+	filter, message := parseEventFilter(c)
+	if message != "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": message,
+		})
+	}
+
+	filter.ArticleID = c.Query("article_id")
+	filter.OrderID = c.Query("order_id")
+
+	return respondEventPage(c, h.stockService, filter)
+}
+
+// parseEventFilter interpreta los filtros comunes de las consultas de eventos. Retorna un
+// mensaje de error si algún parámetro es inválido; el cursor se valida al decodificarlo.
+func parseEventFilter(c *fiber.Ctx) (models.StockEventFilter, string) {
+	filter := models.StockEventFilter{
+		ActorID: c.Query("actor_id"),
+	}
+
+	if eventTypes := c.Query("event_type"); eventTypes != "" {
+		for _, value := range strings.Split(eventTypes, ",") {
+			eventType := models.StockEventType(strings.ToUpper(strings.TrimSpace(value)))
+			if !eventType.IsValid() {
+				return filter, "invalid event_type: " + value
+			}
+			filter.EventTypes = append(filter.EventTypes, eventType)
+		}
+	}
+
+	if actorType := c.Query("actor_type"); actorType != "" {
+		filter.ActorType = models.ActorType(strings.ToUpper(actorType))
+		if !filter.ActorType.IsValid() {
+			return filter, "actor_type must be one of USER, SERVICE, CONSUMER, SYSTEM, ANONYMOUS"
+		}
+	}
+
+	for _, param := range []struct {
+		name   string
+		target **time.Time
+	}{
+		{"from", &filter.From},
+		{"to", &filter.To},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return filter, param.name + " must be an RFC3339 timestamp"
+		}
+		*param.target = &parsed
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+			filter.Limit = parsedLimit
+		}
+	}
+
+	return filter, ""
+}
+
+// respondEventPage decodifica el cursor, ejecuta la consulta y responde con la página
+func respondEventPage(c *fiber.Ctx, stockService *service.StockService, filter models.StockEventFilter) error {
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := models.DecodeEventCursor(cursor)
+		if err != nil {
+			return respondError(c, err, "Invalid cursor")
+		}
+		filter.After = after
+	}
+
+	page, err := stockService.ListStockEvents(c.UserContext(), filter)
+	if err != nil {
+		return respondError(c, err, "Failed to retrieve stock events")
+	}
+
+	return c.JSON(page)
+}
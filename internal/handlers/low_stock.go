@@ -17,7 +17,7 @@ func NewLowStockHandler(stockService *service.StockService) *LowStockHandler {
 
 // GET /api/stock/low-stock
 func (h *LowStockHandler) Handle(c *fiber.Ctx) error {
-	stocks, err := h.stockService.GetLowStocks(c.Context())
+	stocks, err := h.stockService.GetLowStocks(c.UserContext())
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to retrieve low stock items",
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type OpenStockCountHandler struct {
+	countService *service.StockCountService
+}
+
+func NewOpenStockCountHandler(countService *service.StockCountService) *OpenStockCountHandler {
+	return &OpenStockCountHandler{
+		countService: countService,
+	}
+}
+
+// POST /api/stock/counts
+// Requiere autenticación mediante token Bearer
+func (h *OpenStockCountHandler) Handle(c *fiber.Ctx) error {
+	var req models.OpenStockCountRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	if req.Location == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "location is required",
+		})
+	}
+
+	session, err := h.countService.OpenCount(c.UserContext(), &req)
+	if err != nil {
+		return respondError(c, err, "Failed to open stock count")
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": "Stock count opened successfully",
+		"data":    session,
+	})
+}
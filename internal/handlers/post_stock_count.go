@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type PostStockCountHandler struct {
+	countService *service.StockCountService
+}
+
+func NewPostStockCountHandler(countService *service.StockCountService) *PostStockCountHandler {
+	return &PostStockCountHandler{
+		countService: countService,
+	}
+}
+
+// POST /api/stock/counts/:countId/post
+// Requiere autenticación mediante token Bearer
+func (h *PostStockCountHandler) Handle(c *fiber.Ctx) error {
+	countID, err := uuid.Parse(c.Params("countId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "count_id must be a valid UUID",
+		})
+	}
+
+	var req models.PostStockCountRequest
+
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+		}
+	}
+
+	if req.OnReservationConflict != "" && !req.OnReservationConflict.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "on_reservation_conflict must be one of reject, skip",
+		})
+	}
+
+	report, err := h.countService.PostCount(c.UserContext(), countID, req.OnReservationConflict)
+	if err != nil {
+		return respondError(c, err, "Failed to post stock count")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Stock count posted successfully",
+		"data":    report,
+	})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/MatiasTelo/stockgo/internal/messaging"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReplayDeadLetterHandler struct {
+	deadLetterManager *messaging.DeadLetterManager
+}
+
+func NewReplayDeadLetterHandler(deadLetterManager *messaging.DeadLetterManager) *ReplayDeadLetterHandler {
+	return &ReplayDeadLetterHandler{
+		deadLetterManager: deadLetterManager,
+	}
+}
+
+// POST /api/stock/admin/dead-letters/:queue/:messageId/replay
+// Reenvía un mensaje aparcado a su cola original para que vuelva a procesarse. Requiere autenticación mediante token Bearer
+func (h *ReplayDeadLetterHandler) Handle(c *fiber.Ctx) error {
+	queue := c.Params("queue")
+	if !h.deadLetterManager.IsManagedQueue(queue) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Unknown queue",
+			"queues": h.deadLetterManager.Queues(),
+		})
+	}
+
+	messageID := c.Params("messageId")
+	if messageID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "message_id is required",
+		})
+	}
+
+	parked, err := h.deadLetterManager.Replay(c.UserContext(), queue, messageID)
+	if err != nil {
+		if errors.Is(err, messaging.ErrParkedMessageNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Parked message not found",
+			})
+		}
+		if errors.Is(err, messaging.ErrNotConnected) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error": "RabbitMQ is not available",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to replay parked message",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Message replayed successfully",
+		"data":    parked,
+	})
+}
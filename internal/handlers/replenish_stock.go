@@ -50,20 +50,13 @@ func (h *ReplenishStockHandler) Handle(c *fiber.Ctx) error {
 		req.Reason = "Stock replenishment"
 	}
 
-	stock, err := h.stockService.ReplenishStock(c.Context(), req.ArticleID, req.Quantity, req.Reason)
+	stock, err := h.stockService.ReplenishStock(c.UserContext(), req.ArticleID, req.Quantity, req.Reason)
 	if err != nil {
-		if err.Error() == "article not found: stock not found for article_id: "+req.ArticleID {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Article not found",
-			})
-		}
-
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to replenish stock",
-			"details": err.Error(),
-		})
+		return respondError(c, err, "Failed to replenish stock")
 	}
 
+	setStockETag(c, stock)
+
 	return c.JSON(fiber.Map{
 		"message": "Stock replenished successfully",
 		"data":    stock,
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReserveOrderHandler struct {
+	stockService *service.StockService
+}
+
+func NewReserveOrderHandler(stockService *service.StockService) *ReserveOrderHandler {
+	return &ReserveOrderHandler{
+		stockService: stockService,
+	}
+}
+
+// POST /api/stock/orders/:orderId/reserve
+// Reserva las líneas de la orden en una única transacción según la política indicada
+func (h *ReserveOrderHandler) Handle(c *fiber.Ctx) error {
+	orderID := c.Params("orderId")
+	if orderID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "order_id is required",
+		})
+	}
+
+	var req models.ReserveOrderRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	// Validaciones
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "items is required",
+		})
+	}
+
+	if req.Policy != "" && !req.Policy.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "policy must be one of all_or_nothing, reserve_available, skip_missing_lines",
+		})
+	}
+
+	for _, item := range req.Items {
+		if item.ArticleID == "" {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "article_id is required for every item",
+			})
+		}
+		if item.Quantity <= 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "quantity must be greater than 0 for every item",
+			})
+		}
+	}
+
+	result, err := h.stockService.ReserveOrderWithPolicy(c.UserContext(), orderID, req.Items, req.Policy)
+	if err != nil {
+		var insufficientStock *models.ErrInsufficientStock
+		if result != nil && errors.As(err, &insufficientStock) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":                 "Insufficient stock",
+				"code":                  ErrorCodeInsufficientStock,
+				"insufficient_articles": result.InsufficientArticles,
+				"lines":                 result.Lines,
+			})
+		}
+
+		return respondError(c, err, "Failed to reserve order")
+	}
+
+	message := "Order reserved successfully"
+	if result.IsPartial() {
+		message = "Order reserved partially"
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"message": message,
+		"data":    result,
+	})
+}
@@ -46,31 +46,14 @@ func (h *ReserveStockHandler) Handle(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.stockService.ReserveStock(c.Context(), &req)
+	err := h.stockService.ReserveStock(c.UserContext(), &req)
 	if err != nil {
-		if err.Error() == "article not found: stock not found for article_id: "+req.ArticleID {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Article not found",
-			})
-		}
-
-		// Check if it's an insufficient stock error
-		if len(err.Error()) > 22 && err.Error()[:22] == "error reserving stock:" {
-			if len(err.Error()) > 45 && err.Error()[23:42] == "insufficient stock:" {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": err.Error()[23:], // Remove "error reserving stock: " prefix
-				})
-			}
-		}
-		
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to reserve stock",
-			"details": err.Error(),
-		})
+		return respondError(c, err, "Failed to reserve stock")
 	}
 
 	// Get updated stock info to return
-	stock, _ := h.stockService.GetStock(c.Context(), req.ArticleID)
+	stock, _ := h.stockService.GetCurrentStock(c.UserContext(), req.ArticleID)
+	setStockETag(c, stock)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Stock reserved successfully",
@@ -122,31 +105,14 @@ func (h *ReserveStockHandler) HandleByPath(c *fiber.Ctx) error {
 		Quantity:  req.Quantity,
 	}
 
-	err := h.stockService.ReserveStock(c.Context(), reserveReq)
+	err := h.stockService.ReserveStock(c.UserContext(), reserveReq)
 	if err != nil {
-		if err.Error() == "article not found: stock not found for article_id: "+articleID {
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Article not found",
-			})
-		}
-
-		// Check if it's an insufficient stock error
-		if len(err.Error()) > 22 && err.Error()[:22] == "error reserving stock:" {
-			if len(err.Error()) > 45 && err.Error()[23:42] == "insufficient stock:" {
-				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-					"error": err.Error()[23:], // Remove "error reserving stock: " prefix
-				})
-			}
-		}
-		
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Failed to reserve stock",
-			"details": err.Error(),
-		})
+		return respondError(c, err, "Failed to reserve stock")
 	}
 
 	// Get updated stock info to return
-	stock, _ := h.stockService.GetStock(c.Context(), articleID)
+	stock, _ := h.stockService.GetCurrentStock(c.UserContext(), articleID)
+	setStockETag(c, stock)
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "Stock reserved successfully",
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+type SubmitStockCountHandler struct {
+	countService *service.StockCountService
+}
+
+func NewSubmitStockCountHandler(countService *service.StockCountService) *SubmitStockCountHandler {
+	return &SubmitStockCountHandler{
+		countService: countService,
+	}
+}
+
+// PUT /api/stock/counts/:countId/lines
+// Requiere autenticación mediante token Bearer
+func (h *SubmitStockCountHandler) Handle(c *fiber.Ctx) error {
+	countID, err := uuid.Parse(c.Params("countId"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "count_id must be a valid UUID",
+		})
+	}
+
+	var req models.SubmitStockCountRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	if len(req.Items) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "items are required",
+		})
+	}
+
+	report, err := h.countService.SubmitCounts(c.UserContext(), countID, req.Items)
+	if err != nil {
+		return respondError(c, err, "Failed to submit stock count")
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Stock count updated successfully",
+		"data":    report,
+	})
+}
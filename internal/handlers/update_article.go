@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"unicode/utf8"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UpdateArticleHandler struct {
+	stockService *service.StockService
+}
+
+func NewUpdateArticleHandler(stockService *service.StockService) *UpdateArticleHandler {
+	return &UpdateArticleHandler{
+		stockService: stockService,
+	}
+}
+
+// PATCH /api/stock/articles/:articleId
+// Requiere autenticación mediante token Bearer
+func (h *UpdateArticleHandler) Handle(c *fiber.Ctx) error {
+	articleID := c.Params("articleId")
+	if articleID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "article_id is required",
+		})
+	}
+
+	var req models.UpdateStockRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	// Validaciones básicas; la relación entre mínimo y máximo se valida en el servicio
+	// contra los valores actuales del artículo
+	if req.IsEmpty() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "at least one of min_stock, max_stock or location is required",
+		})
+	}
+
+	if req.MinStock != nil && *req.MinStock < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "min_stock cannot be negative",
+		})
+	}
+
+	if req.MaxStock != nil && *req.MaxStock < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "max_stock cannot be negative",
+		})
+	}
+
+	if req.Location != nil && utf8.RuneCountInString(*req.Location) > models.MaxLocationLength {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "location cannot be longer than 255 characters",
+		})
+	}
+
+	stock, err := h.stockService.UpdateStockSettings(c.UserContext(), articleID, &req)
+	if err != nil {
+		return respondError(c, err, "Failed to update article")
+	}
+
+	setStockETag(c, stock)
+
+	return c.JSON(fiber.Map{
+		"message": "Article updated successfully",
+		"data":    stock,
+	})
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UpdateArticleLifecycleHandler struct {
+	stockService *service.StockService
+}
+
+func NewUpdateArticleLifecycleHandler(stockService *service.StockService) *UpdateArticleLifecycleHandler {
+	return &UpdateArticleLifecycleHandler{
+		stockService: stockService,
+	}
+}
+
+// PUT /api/stock/articles/:articleId/lifecycle
+// Requiere autenticación mediante token Bearer
+func (h *UpdateArticleLifecycleHandler) Handle(c *fiber.Ctx) error {
+	articleID := c.Params("articleId")
+	if articleID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "article_id is required",
+		})
+	}
+
+	var req models.UpdateStockStatusRequest
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid request body",
+			"details": err.Error(),
+		})
+	}
+
+	if req.Status == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "status is required",
+		})
+	}
+
+	status := models.StockStatus(strings.ToUpper(string(req.Status)))
+	stock, err := h.stockService.UpdateStockStatus(c.UserContext(), articleID, status, req.Reason)
+	if err != nil {
+		return respondError(c, err, "Failed to update article status")
+	}
+
+	setStockETag(c, stock)
+
+	return c.JSON(fiber.Map{
+		"message": "Article status updated successfully",
+		"data":    stock,
+	})
+}
@@ -0,0 +1,205 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// ErrParkedMessageNotFound indica que el mensaje aparcado no existe en la DLQ
+var ErrParkedMessageNotFound = errors.New("parked message not found")
+
+// ParkedMessage representa un mensaje aparcado en una cola *.dlq
+type ParkedMessage struct {
+	ID            string      `json:"id"`
+	Queue         string      `json:"queue"`
+	Reason        string      `json:"reason,omitempty"`
+	Attempts      int         `json:"attempts"`
+	ParkedAt      string      `json:"parked_at,omitempty"`
+	MessageID     string      `json:"message_id,omitempty"`
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	Body          interface{} `json:"body"`
+}
+
+// DeadLetterManager permite inspeccionar, reenviar o descartar los mensajes de las colas *.dlq
+type DeadLetterManager struct {
+	rabbitMQ *RabbitMQService
+	queues   map[string]bool
+	mu       sync.Mutex
+}
+
+func NewDeadLetterManager(rabbitMQ *RabbitMQService, queues []string) *DeadLetterManager {
+	allowed := make(map[string]bool, len(queues))
+	for _, queue := range queues {
+		allowed[queue] = true
+	}
+
+	return &DeadLetterManager{
+		rabbitMQ: rabbitMQ,
+		queues:   allowed,
+	}
+}
+
+// Queues retorna las colas administradas
+func (m *DeadLetterManager) Queues() []string {
+	queues := make([]string, 0, len(m.queues))
+	for _, queue := range ConsumerQueues {
+		if m.queues[queue] {
+			queues = append(queues, queue)
+		}
+	}
+	return queues
+}
+
+// IsManagedQueue indica si la cola tiene DLQ administrada
+func (m *DeadLetterManager) IsManagedQueue(queue string) bool {
+	return m.queues[queue]
+}
+
+// ListParked retorna hasta limit mensajes aparcados en la DLQ de la cola indicada
+func (m *DeadLetterManager) ListParked(queue string, limit int) ([]*ParkedMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch, err := m.openChannel(queue)
+	if err != nil {
+		return nil, err
+	}
+	// Al cerrar el canal, los mensajes leídos vuelven a la DLQ
+	defer ch.Close()
+
+	parked := []*ParkedMessage{}
+	for len(parked) < limit {
+		msg, ok, err := ch.Get(queue+deadLetterQueueSuffix, false)
+		if err != nil {
+			return nil, fmt.Errorf("error reading dead letter queue: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		parked = append(parked, toParkedMessage(queue, msg))
+	}
+
+	return parked, nil
+}
+
+// Replay reenvía el mensaje aparcado a su cola original con el contador de intentos en cero
+func (m *DeadLetterManager) Replay(ctx context.Context, queue, id string) (*ParkedMessage, error) {
+	return m.take(ctx, queue, id, func(ch *amqp091.Channel, msg amqp091.Delivery) error {
+		headers := copyHeaders(msg.Headers)
+		for _, header := range []string{
+			"x-death", "x-first-death-queue", "x-first-death-reason", "x-first-death-exchange",
+			"x-last-death-queue", "x-last-death-reason", "x-last-death-exchange",
+			retryAttemptsHeader, parkedIDHeader, parkedReasonHeader, parkedAttemptsHeader,
+			parkedAtHeader, originalQueueHeader,
+		} {
+			delete(headers, header)
+		}
+
+		return publishConfirmed(ctx, ch, "", queue, republishing(msg, headers))
+	})
+}
+
+// Discard elimina definitivamente el mensaje aparcado
+func (m *DeadLetterManager) Discard(ctx context.Context, queue, id string) (*ParkedMessage, error) {
+	return m.take(ctx, queue, id, nil)
+}
+
+// take busca el mensaje aparcado, ejecuta fn sobre él y lo quita de la DLQ
+func (m *DeadLetterManager) take(ctx context.Context, queue, id string, fn func(ch *amqp091.Channel, msg amqp091.Delivery) error) (*ParkedMessage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ch, err := m.openChannel(queue)
+	if err != nil {
+		return nil, err
+	}
+	defer ch.Close()
+
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("error enabling publisher confirms: %w", err)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		msg, ok, err := ch.Get(queue+deadLetterQueueSuffix, false)
+		if err != nil {
+			return nil, fmt.Errorf("error reading dead letter queue: %w", err)
+		}
+		if !ok {
+			return nil, ErrParkedMessageNotFound
+		}
+
+		if parkedMessageID(msg) != id {
+			continue
+		}
+
+		if fn != nil {
+			if err := fn(ch, msg); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := msg.Ack(false); err != nil {
+			return nil, fmt.Errorf("error removing parked message: %w", err)
+		}
+
+		return toParkedMessage(queue, msg), nil
+	}
+}
+
+// openChannel valida la cola y abre un canal dedicado para la operación
+func (m *DeadLetterManager) openChannel(queue string) (*amqp091.Channel, error) {
+	if !m.queues[queue] {
+		return nil, fmt.Errorf("unknown queue: %s", queue)
+	}
+
+	conn, err := m.rabbitMQ.Connection()
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("error opening channel: %w", err)
+	}
+
+	return ch, nil
+}
+
+// toParkedMessage convierte un mensaje de la DLQ a su representación para la API
+func toParkedMessage(queue string, msg amqp091.Delivery) *ParkedMessage {
+	parked := &ParkedMessage{
+		ID:            parkedMessageID(msg),
+		Queue:         queue,
+		Attempts:      headerInt(msg.Headers[parkedAttemptsHeader]),
+		MessageID:     msg.MessageId,
+		CorrelationID: msg.CorrelationId,
+	}
+
+	if reason, ok := msg.Headers[parkedReasonHeader].(string); ok {
+		parked.Reason = reason
+	} else if reason, ok := msg.Headers["x-first-death-reason"].(string); ok {
+		parked.Reason = reason
+	}
+
+	if parkedAt, ok := msg.Headers[parkedAtHeader].(string); ok {
+		parked.ParkedAt = parkedAt
+	}
+
+	if json.Valid(msg.Body) {
+		parked.Body = json.RawMessage(msg.Body)
+	} else {
+		parked.Body = string(msg.Body)
+	}
+
+	return parked
+}
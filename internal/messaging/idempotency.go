@@ -0,0 +1,66 @@
+package messaging
+
+import (
+	"context"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Estados por artículo registrados para los mensajes de confirmación y cancelación
+const (
+	articleOutcomeApplied  = "APPLIED"
+	articleOutcomeRejected = "REJECTED"
+)
+
+// ArticleOutcome es el resultado de aplicar un mensaje de orden sobre un artículo
+type ArticleOutcome struct {
+	ArticleID string `json:"articleId"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// OrderMessageResult es el resultado registrado para un mensaje de confirmación o cancelación
+type OrderMessageResult struct {
+	OrderID  string           `json:"orderId"`
+	Articles []ArticleOutcome `json:"articles"`
+}
+
+// messageKey obtiene la clave de idempotencia de un mensaje: el message ID de AMQP, el
+// correlation ID o, en último caso, la primera clave alternativa no vacía
+func messageKey(msg amqp091.Delivery, fallbacks ...string) string {
+	if msg.MessageId != "" {
+		return msg.MessageId
+	}
+	if msg.CorrelationId != "" {
+		return msg.CorrelationId
+	}
+	for _, fallback := range fallbacks {
+		if fallback != "" {
+			return fallback
+		}
+	}
+	return ""
+}
+
+// messageContext agrega al contexto la atribución de los eventos de stock que produzca el mensaje
+func messageContext(ctx context.Context, consumer string, msg amqp091.Delivery, key, correlationID string) context.Context {
+	messageID := msg.MessageId
+	if messageID == "" {
+		messageID = key
+	}
+	if correlationID == "" {
+		correlationID = msg.CorrelationId
+	}
+	if correlationID == "" {
+		correlationID = messageID
+	}
+
+	return models.ContextWithAttribution(ctx, models.Attribution{
+		ActorType:     models.ActorTypeConsumer,
+		ActorID:       consumer,
+		MessageID:     messageID,
+		CorrelationID: correlationID,
+		CausationID:   messageID,
+	})
+}
@@ -2,89 +2,63 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 
-	"github.com/rabbitmq/amqp091-go"
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
 )
 
-// InsufficientStockPublisher maneja la publicación de alertas de stock insuficiente
+// InsufficientStockPublisher maneja la publicación de alertas de stock insuficiente.
+// Las alertas se escriben en el outbox y el OutboxRelay las publica en RabbitMQ.
 type InsufficientStockPublisher struct {
-	connection *amqp091.Connection
-	channel    *amqp091.Channel
+	outboxRepo *repository.OutboxRepository
 }
 
 // InsufficientStockAlert representa el mensaje de stock insuficiente
 type InsufficientStockAlert struct {
-	OrderID    string   `json:"order_id"`
-	ArticleIDs []string `json:"article_ids"`
+	OrderID    string                        `json:"order_id"`
+	ArticleIDs []string                      `json:"article_ids"`
+	Policy     models.ReservationPolicy      `json:"policy,omitempty"`
+	Lines      []models.OrderReservationLine `json:"lines,omitempty"`
 }
 
-func NewInsufficientStockPublisher(conn *amqp091.Connection) (*InsufficientStockPublisher, error) {
-	ch, err := conn.Channel()
-	if err != nil {
-		return nil, err
-	}
-
-	// Declarar exchange
-	err = ch.ExchangeDeclare(
-		"ecommerce", // name
-		"topic",     // type
-		true,        // durable
-		false,       // auto-deleted
-		false,       // internal
-		false,       // no-wait
-		nil,         // arguments
-	)
-	if err != nil {
-		ch.Close()
-		return nil, err
-	}
-
+func NewInsufficientStockPublisher(outboxRepo *repository.OutboxRepository) *InsufficientStockPublisher {
 	return &InsufficientStockPublisher{
-		connection: conn,
-		channel:    ch,
-	}, nil
+		outboxRepo: outboxRepo,
+	}
 }
 
-// PublishInsufficientStock publica un mensaje de stock insuficiente
-func (p *InsufficientStockPublisher) PublishInsufficientStock(ctx context.Context, orderID string, articleIDs []string) error {
-	alert := InsufficientStockAlert{
+// PublishInsufficientStock encola un mensaje de stock insuficiente dentro de la transacción indicada
+func (p *InsufficientStockPublisher) PublishInsufficientStock(ctx context.Context, tx pgx.Tx, orderID string, articleIDs []string) error {
+	return p.publish(ctx, tx, InsufficientStockAlert{
 		OrderID:    orderID,
 		ArticleIDs: articleIDs,
-	}
+	})
+}
 
-	body, err := json.Marshal(alert)
+// PublishOrderShortage encola el resultado de una reserva con faltantes, indicando por
+// artículo la cantidad reservada y la faltante según la política aplicada
+func (p *InsufficientStockPublisher) PublishOrderShortage(ctx context.Context, tx pgx.Tx, result *models.OrderReservationResult) error {
+	return p.publish(ctx, tx, InsufficientStockAlert{
+		OrderID:    result.OrderID,
+		ArticleIDs: result.InsufficientArticles,
+		Policy:     result.Policy,
+		Lines:      result.Lines,
+	})
+}
+
+func (p *InsufficientStockPublisher) publish(ctx context.Context, tx pgx.Tx, alert InsufficientStockAlert) error {
+	message, err := models.NewOutboxMessage(models.OutboxAggregateOrder, alert.OrderID, ecommerceExchange, insufficientStockRoutingKey, alert)
 	if err != nil {
 		return err
 	}
 
-	err = p.channel.PublishWithContext(
-		ctx,
-		"ecommerce",          // exchange
-		"insufficient_stock", // routing key
-		false,                // mandatory
-		false,                // immediate
-		amqp091.Publishing{
-			ContentType:  "application/json",
-			Body:         body,
-			DeliveryMode: amqp091.Persistent,
-		},
-	)
-
-	if err != nil {
-		log.Printf("InsufficientStockPublisher: Failed to publish alert for order %s: %v", orderID, err)
+	if err := p.outboxRepo.WithTx(tx).Enqueue(ctx, message); err != nil {
+		log.Printf("InsufficientStockPublisher: Failed to enqueue alert for order %s: %v", alert.OrderID, err)
 		return err
 	}
 
-	log.Printf("InsufficientStockPublisher: Published insufficient stock alert for order %s with %d articles", orderID, len(articleIDs))
-	return nil
-}
-
-// Close cierra el canal del publisher
-func (p *InsufficientStockPublisher) Close() error {
-	if p.channel != nil {
-		return p.channel.Close()
-	}
+	log.Printf("InsufficientStockPublisher: Enqueued insufficient stock alert for order %s with %d articles", alert.OrderID, len(alert.ArticleIDs))
 	return nil
 }
@@ -3,8 +3,12 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"time"
 
+	"github.com/MatiasTelo/stockgo/internal/config"
+	"github.com/MatiasTelo/stockgo/internal/models"
 	"github.com/MatiasTelo/stockgo/internal/service"
 	"github.com/rabbitmq/amqp091-go"
 )
@@ -12,8 +16,9 @@ import (
 // OrderConfirmedConsumer maneja los eventos de órdenes confirmadas
 type OrderConfirmedConsumer struct {
 	stockService *service.StockService
-	connection   *amqp091.Connection
+	ledger       *service.MessageLedger
 	channel      *amqp091.Channel
+	retry        config.RetryConfig
 }
 
 // OrderConfirmedMessage representa el mensaje de orden confirmada
@@ -25,24 +30,41 @@ type OrderConfirmedMessage struct {
 	ConfirmedAt string              `json:"confirmed_at"`
 }
 
-func NewOrderConfirmedConsumer(stockService *service.StockService, conn *amqp091.Connection) (*OrderConfirmedConsumer, error) {
+func NewOrderConfirmedConsumer(stockService *service.StockService, ledger *service.MessageLedger, retry config.RetryConfig) *OrderConfirmedConsumer {
+	return &OrderConfirmedConsumer{
+		stockService: stockService,
+		ledger:       ledger,
+		retry:        retry,
+	}
+}
+
+// Name identifica al consumer en los logs de RabbitMQService
+func (c *OrderConfirmedConsumer) Name() string {
+	return "OrderConfirmedConsumer"
+}
+
+// Start abre un canal sobre la conexión, declara la topología y comienza a consumir.
+// RabbitMQService lo invoca en cada (re)conexión y cuando el consumer se detiene solo.
+func (c *OrderConfirmedConsumer) Start(ctx context.Context, conn *amqp091.Connection) (<-chan error, error) {
 	ch, err := conn.Channel()
 	if err != nil {
 		return nil, err
 	}
 
-	consumer := &OrderConfirmedConsumer{
-		stockService: stockService,
-		connection:   conn,
-		channel:      ch,
+	// Modo confirm para reprogramar y aparcar mensajes sin perderlos
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
 	}
 
-	if err := consumer.setupQueue(); err != nil {
+	c.channel = ch
+
+	if err := c.setupQueue(); err != nil {
 		ch.Close()
 		return nil, err
 	}
 
-	return consumer, nil
+	return c.StartConsuming(ctx)
 }
 
 func (c *OrderConfirmedConsumer) setupQueue() error {
@@ -60,47 +82,47 @@ func (c *OrderConfirmedConsumer) setupQueue() error {
 		return err
 	}
 
-	// Declarar cola
-	queue, err := c.channel.QueueDeclare(
-		"orders_confirmed_stock", // nombre único para stock service
-		true,                     // durable
-		false,                    // delete when unused
-		false,                    // exclusive
-		false,                    // no-wait
-		nil,                      // arguments
-	)
-	if err != nil {
+	// Declarar cola con su cola de reintentos y su DLQ
+	if err := declareRetryableQueue(c.channel, orderConfirmedQueue); err != nil {
 		return err
 	}
 
 	// Bind cola al exchange (routing key vacío para fanout)
 	return c.channel.QueueBind(
-		queue.Name,         // queue name
-		"",                 // routing key vacío para fanout
-		"orders_confirmed", // exchange
+		orderConfirmedQueue, // queue name
+		"",                  // routing key vacío para fanout
+		"orders_confirmed",  // exchange
 		false,
 		nil,
 	)
 }
 
-// StartConsuming inicia el consumo de mensajes
-func (c *OrderConfirmedConsumer) StartConsuming(ctx context.Context) error {
+// StartConsuming inicia el consumo de mensajes. El canal retornado recibe la causa si el
+// broker cierra el canal o cancela el consumer, y se cierra sin valor al cancelar el contexto.
+func (c *OrderConfirmedConsumer) StartConsuming(ctx context.Context) (<-chan error, error) {
 	msgs, err := c.channel.Consume(
-		"orders_confirmed_stock", // queue
-		"",                       // consumer
-		false,                    // auto-ack
-		false,                    // exclusive
-		false,                    // no-local
-		false,                    // no-wait
-		nil,                      // args
+		orderConfirmedQueue, // queue
+		"",                  // consumer
+		false,               // auto-ack
+		false,               // exclusive
+		false,               // no-local
+		false,               // no-wait
+		nil,                 // args
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	log.Println("OrderConfirmedConsumer: Waiting for orders_confirmed messages...")
 
+	ch := c.channel
+	closed := ch.NotifyClose(make(chan *amqp091.Error, 1))
+	cancelled := ch.NotifyCancel(make(chan string, 1))
+	stopped := make(chan error, 1)
+
 	go func() {
+		defer close(stopped)
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -108,13 +130,15 @@ func (c *OrderConfirmedConsumer) StartConsuming(ctx context.Context) error {
 				return
 			case msg, ok := <-msgs:
 				if !ok {
-					log.Println("OrderConfirmedConsumer: Channel closed")
+					err := consumerStopCause(closed, cancelled)
+					log.Printf("OrderConfirmedConsumer: Stopped consuming: %v", err)
+					stopped <- err
 					return
 				}
 
 				if err := c.handleMessage(ctx, msg); err != nil {
 					log.Printf("OrderConfirmedConsumer: Error processing message: %v", err)
-					msg.Nack(false, true) // requeue on error
+					handleDeliveryFailure(ctx, ch, "OrderConfirmedConsumer", orderConfirmedQueue, c.retry, msg, err, isRetryableError(err))
 				} else {
 					msg.Ack(false)
 				}
@@ -122,30 +146,71 @@ func (c *OrderConfirmedConsumer) StartConsuming(ctx context.Context) error {
 		}
 	}()
 
-	return nil
+	return stopped, nil
 }
 
 func (c *OrderConfirmedConsumer) handleMessage(ctx context.Context, msg amqp091.Delivery) error {
 	var orderMsg OrderConfirmedMessage
 	if err := json.Unmarshal(msg.Body, &orderMsg); err != nil {
-		return err
+		return fmt.Errorf("%w: %v", models.ErrInvalidOrder, err)
+	}
+
+	if orderMsg.OrderID == "" {
+		return fmt.Errorf("%w: missing orderId", models.ErrInvalidOrder)
 	}
 
 	log.Printf("OrderConfirmedConsumer: Processing order confirmed: %s with %d items", orderMsg.OrderID, len(orderMsg.Articles))
 
-	// Confirmar las reservas (descontar stock)
-	for _, item := range orderMsg.Articles {
-		if err := c.stockService.ConfirmReservationByOrderID(ctx, orderMsg.OrderID, item.ArticleID, "Order confirmed via RabbitMQ"); err != nil {
-			log.Printf("OrderConfirmedConsumer: Failed to confirm reservation for article %s in order %s: %v",
-				item.ArticleID, orderMsg.OrderID, err)
-			return err
-		}
+	key := messageKey(msg, orderMsg.OrderID)
+	ctx = messageContext(ctx, orderConfirmedQueue, msg, key, "")
+
+	// Confirmar las reservas (descontar stock). Los artículos cuya reserva no admite la operación se
+	// registran como rechazados y no detienen al resto; el resultado se guarda en el ledger
+	// dentro de la misma transacción que los cambios de stock.
+	processed, err := c.ledger.ProcessOnce(ctx, orderConfirmedQueue, key, isRetryableError,
+		func(ctx context.Context) (any, error) {
+			result := &OrderMessageResult{
+				OrderID:  orderMsg.OrderID,
+				Articles: make([]ArticleOutcome, 0, len(orderMsg.Articles)),
+			}
+
+			for _, item := range orderMsg.Articles {
+				if err := c.stockService.ConfirmReservationByOrderID(ctx, orderMsg.OrderID, item.ArticleID, "Order confirmed via RabbitMQ"); err != nil {
+					if isRetryableError(err) {
+						return nil, err
+					}
+
+					log.Printf("OrderConfirmedConsumer: Failed to confirm reservation for article %s in order %s: %v",
+						item.ArticleID, orderMsg.OrderID, err)
+					result.Articles = append(result.Articles, ArticleOutcome{
+						ArticleID: item.ArticleID,
+						Status:    articleOutcomeRejected,
+						Error:     err.Error(),
+					})
+					continue
+				}
+
+				log.Printf("OrderConfirmedConsumer: Successfully confirmed reservation for article %s in order %s",
+					item.ArticleID, orderMsg.OrderID)
+				result.Articles = append(result.Articles, ArticleOutcome{
+					ArticleID: item.ArticleID,
+					Status:    articleOutcomeApplied,
+				})
+			}
+
+			return result, nil
+		})
+	if err != nil {
+		return err
+	}
 
-		log.Printf("OrderConfirmedConsumer: Successfully confirmed reservation for article %s in order %s",
-			item.ArticleID, orderMsg.OrderID)
+	if processed.Duplicate {
+		log.Printf("OrderConfirmedConsumer: Message %s for order %s already processed at %s, skipping",
+			key, orderMsg.OrderID, processed.ProcessedAt.Format(time.RFC3339))
+		return nil
 	}
 
-	log.Printf("OrderConfirmedConsumer: Successfully confirmed all reservations for order: %s", orderMsg.OrderID)
+	log.Printf("OrderConfirmedConsumer: Finished processing order confirmed: %s", orderMsg.OrderID)
 	return nil
 }
 
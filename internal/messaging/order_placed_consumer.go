@@ -3,9 +3,11 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
-	"strings"
+	"time"
 
+	"github.com/MatiasTelo/stockgo/internal/config"
 	"github.com/MatiasTelo/stockgo/internal/models"
 	"github.com/MatiasTelo/stockgo/internal/service"
 	"github.com/rabbitmq/amqp091-go"
@@ -13,10 +15,10 @@ import (
 
 // OrderPlacedConsumer maneja los eventos de órdenes creadas
 type OrderPlacedConsumer struct {
-	stockService               *service.StockService
-	connection                 *amqp091.Connection
-	channel                    *amqp091.Channel
-	insufficientStockPublisher *InsufficientStockPublisher
+	stockService *service.StockService
+	ledger       *service.MessageLedger
+	channel      *amqp091.Channel
+	retry        config.RetryConfig
 }
 
 // OrderPlacedMessageWrapper es el contenedor del mensaje que viene de RabbitMQ
@@ -29,10 +31,11 @@ type OrderPlacedMessageWrapper struct {
 
 // OrderPlacedMessage representa el mensaje de orden creada
 type OrderPlacedMessage struct {
-	OrderID  string              `json:"orderId"`
-	CartID   string              `json:"cartId"`
-	UserID   string              `json:"userId"`
-	Articles []ArticlePlacedData `json:"articles"`
+	OrderID           string                   `json:"orderId"`
+	CartID            string                   `json:"cartId"`
+	UserID            string                   `json:"userId"`
+	Articles          []ArticlePlacedData      `json:"articles"`
+	ReservationPolicy models.ReservationPolicy `json:"reservationPolicy,omitempty"`
 }
 
 // ArticlePlacedData representa un artículo en la orden
@@ -41,25 +44,41 @@ type ArticlePlacedData struct {
 	Quantity  int    `json:"quantity"`
 }
 
-func NewOrderPlacedConsumer(stockService *service.StockService, conn *amqp091.Connection, insufficientStockPublisher *InsufficientStockPublisher) (*OrderPlacedConsumer, error) {
+func NewOrderPlacedConsumer(stockService *service.StockService, ledger *service.MessageLedger, retry config.RetryConfig) *OrderPlacedConsumer {
+	return &OrderPlacedConsumer{
+		stockService: stockService,
+		ledger:       ledger,
+		retry:        retry,
+	}
+}
+
+// Name identifica al consumer en los logs de RabbitMQService
+func (c *OrderPlacedConsumer) Name() string {
+	return "OrderPlacedConsumer"
+}
+
+// Start abre un canal sobre la conexión, declara la topología y comienza a consumir.
+// RabbitMQService lo invoca en cada (re)conexión y cuando el consumer se detiene solo.
+func (c *OrderPlacedConsumer) Start(ctx context.Context, conn *amqp091.Connection) (<-chan error, error) {
 	ch, err := conn.Channel()
 	if err != nil {
 		return nil, err
 	}
 
-	consumer := &OrderPlacedConsumer{
-		stockService:               stockService,
-		connection:                 conn,
-		channel:                    ch,
-		insufficientStockPublisher: insufficientStockPublisher,
+	// Modo confirm para reprogramar y aparcar mensajes sin perderlos
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, err
 	}
 
-	if err := consumer.setupQueue(); err != nil {
+	c.channel = ch
+
+	if err := c.setupQueue(); err != nil {
 		ch.Close()
 		return nil, err
 	}
 
-	return consumer, nil
+	return c.StartConsuming(ctx)
 }
 
 func (c *OrderPlacedConsumer) setupQueue() error {
@@ -77,33 +96,26 @@ func (c *OrderPlacedConsumer) setupQueue() error {
 		return err
 	}
 
-	// Declarar cola
-	queue, err := c.channel.QueueDeclare(
-		"order_placed_stock", // nombre único para stock service
-		true,                 // durable
-		false,                // delete when unused
-		false,                // exclusive
-		false,                // no-wait
-		nil,                  // arguments
-	)
-	if err != nil {
+	// Declarar cola con su cola de reintentos y su DLQ
+	if err := declareRetryableQueue(c.channel, orderPlacedQueue); err != nil {
 		return err
 	}
 
 	// Bind cola al exchange (routing key vacío para fanout)
 	return c.channel.QueueBind(
-		queue.Name,     // queue name
-		"",             // routing key vacío para fanout
-		"order_placed", // exchange
+		orderPlacedQueue, // queue name
+		"",               // routing key vacío para fanout
+		"order_placed",   // exchange
 		false,
 		nil,
 	)
 }
 
-// StartConsuming inicia el consumo de mensajes
-func (c *OrderPlacedConsumer) StartConsuming(ctx context.Context) error {
+// StartConsuming inicia el consumo de mensajes. El canal retornado recibe la causa si el
+// broker cierra el canal o cancela el consumer, y se cierra sin valor al cancelar el contexto.
+func (c *OrderPlacedConsumer) StartConsuming(ctx context.Context) (<-chan error, error) {
 	msgs, err := c.channel.Consume(
-		"order_placed_stock",          // queue
+		orderPlacedQueue,              // queue
 		"stock-order-placed-consumer", // consumer tag
 		false,                         // auto-ack
 		false,                         // exclusive
@@ -113,12 +125,19 @@ func (c *OrderPlacedConsumer) StartConsuming(ctx context.Context) error {
 	)
 	if err != nil {
 		log.Printf("OrderPlacedConsumer: Failed to start consuming: %v", err)
-		return err
+		return nil, err
 	}
 
 	log.Println("OrderPlacedConsumer: Successfully started, waiting for order_placed messages...")
 
+	ch := c.channel
+	closed := ch.NotifyClose(make(chan *amqp091.Error, 1))
+	cancelled := ch.NotifyCancel(make(chan string, 1))
+	stopped := make(chan error, 1)
+
 	go func() {
+		defer close(stopped)
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -126,21 +145,15 @@ func (c *OrderPlacedConsumer) StartConsuming(ctx context.Context) error {
 				return
 			case msg, ok := <-msgs:
 				if !ok {
-					log.Println("OrderPlacedConsumer: Channel closed")
+					err := consumerStopCause(closed, cancelled)
+					log.Printf("OrderPlacedConsumer: Stopped consuming: %v", err)
+					stopped <- err
 					return
 				}
 
 				if err := c.handleMessage(ctx, msg); err != nil {
 					log.Printf("OrderPlacedConsumer: Error processing message: %v", err)
-
-					// Verificar si es un error recuperable o no recuperable
-					if c.isRecoverableError(err) {
-						log.Printf("OrderPlacedConsumer: Recoverable error, requeuing message")
-						msg.Nack(false, true) // requeue only for recoverable errors
-					} else {
-						log.Printf("OrderPlacedConsumer: Non-recoverable error, rejecting message: %v", err)
-						msg.Nack(false, false) // reject without requeuing
-					}
+					handleDeliveryFailure(ctx, ch, "OrderPlacedConsumer", orderPlacedQueue, c.retry, msg, err, isRetryableError(err))
 				} else {
 					msg.Ack(false)
 				}
@@ -148,120 +161,74 @@ func (c *OrderPlacedConsumer) StartConsuming(ctx context.Context) error {
 		}
 	}()
 
-	return nil
+	return stopped, nil
 }
 
 func (c *OrderPlacedConsumer) handleMessage(ctx context.Context, msg amqp091.Delivery) error {
 	var wrapper OrderPlacedMessageWrapper
 	if err := json.Unmarshal(msg.Body, &wrapper); err != nil {
 		log.Printf("OrderPlacedConsumer: Failed to unmarshal message: %v", err)
-		return err
+		return fmt.Errorf("%w: %v", models.ErrInvalidOrder, err)
 	}
 
 	orderMsg := wrapper.Message
-	log.Printf("OrderPlacedConsumer: Processing order placed: %s with %d items", orderMsg.OrderID, len(orderMsg.Articles))
+	if orderMsg.OrderID == "" {
+		return fmt.Errorf("%w: missing orderId", models.ErrInvalidOrder)
+	}
 
-	// Array para recopilar artículos con stock insuficiente
-	var insufficientStockArticles []string
-	// Array para tracking de artículos reservados exitosamente
-	var successfullyReserved []string
-	// Flag para indicar si hubo algún error
-	var hasError error
+	log.Printf("OrderPlacedConsumer: Processing order placed: %s with %d items", orderMsg.OrderID, len(orderMsg.Articles))
 
-	// Intentar reservar stock para cada artículo en la orden
+	items := make([]models.OrderReservationItem, 0, len(orderMsg.Articles))
 	for _, item := range orderMsg.Articles {
-		req := &models.ReserveStockRequest{
+		items = append(items, models.OrderReservationItem{
 			ArticleID: item.ArticleID,
 			Quantity:  item.Quantity,
-			OrderID:   orderMsg.OrderID,
-		}
-
-		if err := c.stockService.ReserveStock(ctx, req); err != nil {
-			log.Printf("OrderPlacedConsumer: Failed to reserve stock for article %s in order %s: %v",
-				item.ArticleID, orderMsg.OrderID, err)
-
-			// Si es error de stock insuficiente, agregarlo a la lista
-			if strings.Contains(err.Error(), "insufficient stock") || strings.Contains(err.Error(), "not enough stock") {
-				insufficientStockArticles = append(insufficientStockArticles, item.ArticleID)
-			}
-
-			// Guardar el error pero continuar verificando los demás artículos
-			hasError = err
-		} else {
-			// Marcar como exitosamente reservado
-			successfullyReserved = append(successfullyReserved, item.ArticleID)
-			log.Printf("OrderPlacedConsumer: Successfully reserved %d units of article %s for order %s",
-				item.Quantity, item.ArticleID, orderMsg.OrderID)
-		}
+		})
 	}
 
-	// Si hubo artículos con stock insuficiente, publicar mensaje y rechazar la orden
-	if len(insufficientStockArticles) > 0 {
-		log.Printf("OrderPlacedConsumer: Order %s has insufficient stock for %d article(s): %v",
-			orderMsg.OrderID, len(insufficientStockArticles), insufficientStockArticles)
-
-		// Publicar mensaje con TODOS los artículos sin stock
-		if c.insufficientStockPublisher != nil {
-			if err := c.insufficientStockPublisher.PublishInsufficientStock(ctx, orderMsg.OrderID, insufficientStockArticles); err != nil {
-				log.Printf("OrderPlacedConsumer: Failed to publish insufficient stock alert: %v", err)
-			} else {
-				log.Printf("OrderPlacedConsumer: Published insufficient stock alert for order %s with %d articles",
-					orderMsg.OrderID, len(insufficientStockArticles))
+	var result *models.OrderReservationResult
+	key := messageKey(msg, wrapper.CorrelationID, orderMsg.OrderID)
+	ctx = messageContext(ctx, orderPlacedQueue, msg, key, wrapper.CorrelationID)
+
+	// Reservar los artículos de la orden en una única transacción según la política pedida,
+	// registrando el resultado en el ledger dentro de la misma transacción.
+	// Si falta stock, el servicio encola el mensaje insufficient_stock con el detalle por artículo.
+	processed, err := c.ledger.ProcessOnce(ctx, orderPlacedQueue, key, isRetryableError,
+		func(ctx context.Context) (any, error) {
+			var err error
+			result, err = c.stockService.ProcessPlacedOrder(ctx, orderMsg.OrderID, items, orderMsg.ReservationPolicy)
+			if result == nil {
+				return nil, err
 			}
-		}
+			return result, err
+		})
 
-		// Compensar las reservas que se hicieron exitosamente
-		if len(successfullyReserved) > 0 {
-			log.Printf("OrderPlacedConsumer: Compensating %d successful reservations", len(successfullyReserved))
-			c.compensateReservations(ctx, orderMsg.OrderID, orderMsg.Articles)
-		}
-
-		return hasError
-	}
-
-	// Si hubo algún error pero no fue de stock insuficiente, compensar y retornar error
-	if hasError != nil {
-		log.Printf("OrderPlacedConsumer: Error processing order %s, compensating reservations", orderMsg.OrderID)
-		c.compensateReservations(ctx, orderMsg.OrderID, orderMsg.Articles)
-		return hasError
+	if processed != nil && processed.Duplicate {
+		log.Printf("OrderPlacedConsumer: Message %s for order %s already processed at %s with outcome %s, skipping",
+			key, orderMsg.OrderID, processed.ProcessedAt.Format(time.RFC3339), processed.Outcome)
+		return nil
 	}
 
-	log.Printf("OrderPlacedConsumer: Successfully processed order placed: %s", orderMsg.OrderID)
-	return nil
-}
-
-// compensateReservations cancela las reservas ya hechas en caso de error
-func (c *OrderPlacedConsumer) compensateReservations(ctx context.Context, orderID string, items []ArticlePlacedData) {
-	log.Printf("OrderPlacedConsumer: Compensating reservations for failed order: %s", orderID)
-
-	for _, item := range items {
-		if err := c.stockService.CancelReservationByOrderID(ctx, orderID, item.ArticleID, "Compensation for failed order processing"); err != nil {
-			log.Printf("OrderPlacedConsumer: Failed to compensate reservation for article %s: %v", item.ArticleID, err)
-		}
+	if result != nil && result.IsPartial() {
+		log.Printf("OrderPlacedConsumer: Order %s has insufficient stock for %d article(s): %v",
+			orderMsg.OrderID, len(result.InsufficientArticles), result.InsufficientArticles)
 	}
-}
 
-// isRecoverableError determina si un error es recuperable o no
-func (c *OrderPlacedConsumer) isRecoverableError(err error) bool {
-	errorMsg := err.Error()
-
-	// Errores no recuperables (no reencolar)
-	nonRecoverableErrors := []string{
-		"already has an active reservation",
-		"duplicate order",
-		"invalid order format",
-		"article not found",
-		"insufficient stock",
-	}
+	if err != nil {
+		log.Printf("OrderPlacedConsumer: Failed to reserve stock for order %s: %v", orderMsg.OrderID, err)
 
-	for _, nonRecoverable := range nonRecoverableErrors {
-		if strings.Contains(errorMsg, nonRecoverable) {
-			return false
+		// El rechazo quedó registrado en el ledger (y el faltante notificado): el mensaje
+		// ya fue procesado y no debe reintentarse ni aparcarse
+		if processed != nil {
+			return nil
 		}
+		return err
 	}
 
-	// Por defecto, consideramos otros errores como recuperables
-	return true
+	log.Printf("OrderPlacedConsumer: Reserved %d of %d article(s) for order %s (policy %s)",
+		len(result.Reservations), len(result.Lines), orderMsg.OrderID, result.Policy)
+	log.Printf("OrderPlacedConsumer: Successfully processed order placed: %s", orderMsg.OrderID)
+	return nil
 }
 
 // Close cierra las conexiones del consumer
@@ -0,0 +1,255 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/google/uuid"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	outboxMaxBackoff   = 5 * time.Minute
+	outboxCleanupEvery = time.Hour
+	// outboxClaimLease es cuánto tiempo un lote reclamado queda reservado para el relay
+	outboxClaimLease = 2 * time.Minute
+)
+
+// OutboxRelay publica en RabbitMQ los mensajes de stock_outbox, en orden por agregado
+type OutboxRelay struct {
+	outboxRepo *repository.OutboxRepository
+	rabbitMQ   *RabbitMQService
+	mu         sync.Mutex
+	channel    *amqp091.Channel
+	interval   time.Duration
+	batchSize  int
+	retention  time.Duration
+}
+
+func NewOutboxRelay(outboxRepo *repository.OutboxRepository, rabbitMQ *RabbitMQService, interval time.Duration, batchSize int, retention time.Duration) *OutboxRelay {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &OutboxRelay{
+		outboxRepo: outboxRepo,
+		rabbitMQ:   rabbitMQ,
+		interval:   interval,
+		batchSize:  batchSize,
+		retention:  retention,
+	}
+}
+
+// Start inicia el drenado del outbox en segundo plano hasta que se cancele el contexto
+func (r *OutboxRelay) Start(ctx context.Context) {
+	log.Printf("OutboxRelay: Started, polling every %s", r.interval)
+
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		lastCleanup := time.Now()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("OutboxRelay: Context cancelled, stopping relay")
+				return
+			case <-ticker.C:
+				r.drain(ctx)
+
+				if r.retention > 0 && time.Since(lastCleanup) >= outboxCleanupEvery {
+					r.cleanup(ctx)
+					lastCleanup = time.Now()
+				}
+			}
+		}
+	}()
+}
+
+// drain publica lotes de mensajes pendientes hasta que no queden lotes completos
+func (r *OutboxRelay) drain(ctx context.Context) {
+	for {
+		claimed, err := r.relayBatch(ctx)
+		if errors.Is(err, ErrNotConnected) {
+			// Los mensajes quedan pendientes hasta que RabbitMQService reconecte
+			return
+		}
+		if err != nil {
+			log.Printf("OutboxRelay: Error relaying outbox messages: %v", err)
+			return
+		}
+
+		if claimed < r.batchSize {
+			return
+		}
+	}
+}
+
+// relayBatch reclama y publica un lote de mensajes y retorna cuántos reclamó
+func (r *OutboxRelay) relayBatch(ctx context.Context) (int, error) {
+	ch, err := r.ensureChannel()
+	if err != nil {
+		return 0, err
+	}
+
+	leaseUntil := time.Now().Add(outboxClaimLease)
+	messages, err := r.outboxRepo.ClaimPending(ctx, r.batchSize, leaseUntil)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, message := range messages {
+		// Vencido el reclamo, otra réplica puede tomar los mensajes restantes
+		if time.Now().After(leaseUntil) {
+			return len(messages), nil
+		}
+
+		publishErr := r.publish(ctx, ch, message)
+		if publishErr == nil {
+			if err := r.outboxRepo.MarkPublished(ctx, message.ID); err != nil {
+				return len(messages), err
+			}
+			continue
+		}
+
+		if isChannelError(ch, publishErr) {
+			r.discardChannel(ch)
+			if err := r.releaseClaims(messages[i:]); err != nil {
+				return len(messages), err
+			}
+			return len(messages), fmt.Errorf("error publishing message %s: %w", message.ID, publishErr)
+		}
+
+		nextAttemptAt := time.Now().Add(outboxBackoff(message.Attempts + 1))
+		log.Printf("OutboxRelay: Failed to publish message %s (%s, attempt %d), retrying at %s: %v",
+			message.ID, message.RoutingKey, message.Attempts+1, nextAttemptAt.Format(time.RFC3339), publishErr)
+
+		if err := r.outboxRepo.MarkFailed(ctx, message.ID, publishErr, nextAttemptAt); err != nil {
+			return len(messages), err
+		}
+	}
+
+	return len(messages), nil
+}
+
+// releaseClaims libera los mensajes reclamados que no se llegaron a publicar. Usa un contexto
+// sin cancelación para liberarlos también al detener el servicio.
+func (r *OutboxRelay) releaseClaims(messages []*models.OutboxMessage) error {
+	ids := make([]uuid.UUID, len(messages))
+	for i, message := range messages {
+		ids[i] = message.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishConfirmTimeout)
+	defer cancel()
+	return r.outboxRepo.ReleaseClaims(ctx, ids)
+}
+
+// isChannelError indica si el error de publicación se debe al canal (cerrado, sin confirmación
+// a tiempo o cancelado) y no al mensaje
+func isChannelError(ch *amqp091.Channel, err error) bool {
+	return ch.IsClosed() || errors.Is(err, amqp091.ErrClosed) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
+// publish envía un mensaje y espera la confirmación del broker
+func (r *OutboxRelay) publish(ctx context.Context, ch *amqp091.Channel, message *models.OutboxMessage) error {
+	return publishConfirmed(ctx, ch, message.Exchange, message.RoutingKey, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		Timestamp:    message.CreatedAt,
+		MessageId:    message.ID.String(),
+		Body:         message.Payload,
+	})
+}
+
+// ensureChannel abre un canal en modo confirm sobre la conexión vigente si no hay uno
+// abierto; tras una reconexión el canal anterior queda cerrado y se reemplaza
+func (r *OutboxRelay) ensureChannel() (*amqp091.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.channel != nil && !r.channel.IsClosed() {
+		return r.channel, nil
+	}
+
+	conn, err := r.rabbitMQ.Connection()
+	if err != nil {
+		return nil, err
+	}
+
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("error opening channel: %w", err)
+	}
+
+	if err := declarePublisherTopology(ch); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("error declaring publisher topology: %w", err)
+	}
+
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("error enabling publisher confirms: %w", err)
+	}
+
+	r.channel = ch
+	return ch, nil
+}
+
+// discardChannel cierra el canal con el que falló una publicación para que el próximo lote
+// abra uno nuevo
+func (r *OutboxRelay) discardChannel(ch *amqp091.Channel) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.channel == ch {
+		r.channel = nil
+	}
+	ch.Close()
+}
+
+// cleanup elimina los mensajes publicados más antiguos que la retención configurada
+func (r *OutboxRelay) cleanup(ctx context.Context) {
+	deleted, err := r.outboxRepo.DeletePublishedBefore(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		log.Printf("OutboxRelay: Error cleaning up published messages: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("OutboxRelay: Deleted %d published messages", deleted)
+	}
+}
+
+// Close cierra el canal del relay
+func (r *OutboxRelay) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.channel != nil {
+		return r.channel.Close()
+	}
+	return nil
+}
+
+// outboxBackoff calcula la espera antes del siguiente intento (1s, 2s, 4s... hasta 5 minutos)
+func outboxBackoff(attempt int) time.Duration {
+	backoff := time.Second
+	for i := 1; i < attempt && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
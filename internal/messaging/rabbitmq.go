@@ -2,48 +2,506 @@ package messaging
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"github.com/MatiasTelo/stockgo/internal/config"
 	"github.com/rabbitmq/amqp091-go"
 )
 
-// RabbitMQService provides a basic connection to RabbitMQ
+// Exchange y routing keys de los mensajes publicados por el servicio
+const (
+	ecommerceExchange            = "ecommerce"
+	lowStockRoutingKey           = "stock.alert.low"
+	lowStockQueue                = "stock.alerts.lowstock"
+	insufficientStockRoutingKey  = "insufficient_stock"
+	reservationExpiredRoutingKey = "stock.reservation.expired"
+)
+
+// Colas de los consumers. También se usan como nombre del consumer en el registro
+// de mensajes procesados.
+const (
+	orderPlacedQueue    = "order_placed_stock"
+	orderConfirmedQueue = "orders_confirmed_stock"
+	orderCanceledQueue  = "orders_canceled_stock"
+)
+
+// ConsumerQueues son las colas con reintentos y DLQ propias del servicio
+var ConsumerQueues = []string{orderPlacedQueue, orderConfirmedQueue, orderCanceledQueue}
+
+// publishConfirmTimeout es el tiempo máximo de espera de la confirmación del broker
+const publishConfirmTimeout = 10 * time.Second
+
+// ErrNotConnected indica que no hay una conexión activa con RabbitMQ
+var ErrNotConnected = errors.New("rabbitmq is not connected")
+
+// ConnectionState representa el estado de la conexión con RabbitMQ
+type ConnectionState string
+
+const (
+	ConnectionStateConnecting ConnectionState = "connecting"
+	ConnectionStateConnected  ConnectionState = "connected"
+	// ConnectionStateDegraded indica que hay conexión pero algún componente dejó de consumir
+	ConnectionStateDegraded     ConnectionState = "degraded"
+	ConnectionStateDisconnected ConnectionState = "disconnected"
+	ConnectionStateClosed       ConnectionState = "closed"
+)
+
+// ConnectionStatus describe el estado actual de la conexión, expuesto en /health
+type ConnectionStatus struct {
+	State      ConnectionState   `json:"state"`
+	Since      time.Time         `json:"since"`
+	LastError  string            `json:"last_error,omitempty"`
+	Reconnects int               `json:"reconnects"`
+	Components []ComponentStatus `json:"components,omitempty"`
+}
+
+// ComponentStatus describe el estado de un componente registrado
+type ComponentStatus struct {
+	Name      string    `json:"name"`
+	Running   bool      `json:"running"`
+	Since     time.Time `json:"since"`
+	LastError string    `json:"last_error,omitempty"`
+	Restarts  int       `json:"restarts"`
+}
+
+// Component es un consumer que RabbitMQService inicia sobre cada conexión establecida. El canal
+// que retorna Start recibe la causa si deja de consumir con la conexión abierta.
+type Component interface {
+	Name() string
+	Start(ctx context.Context, conn *amqp091.Connection) (<-chan error, error)
+	Close() error
+}
+
+// componentStop informa que el componente en la posición index dejó de consumir
+type componentStop struct {
+	index int
+	err   error
+}
+
+// errComponentStopped es la causa informada cuando un componente se detiene sin indicar por qué
+var errComponentStopped = errors.New("component stopped")
+
+// consumerStopCause retorna por qué se cerró el canal de entregas de un consumer, según las
+// notificaciones NotifyCancel y NotifyClose registradas en su canal
+func consumerStopCause(closed <-chan *amqp091.Error, cancelled <-chan string) error {
+	select {
+	case tag, ok := <-cancelled:
+		if ok {
+			return fmt.Errorf("consumer %q cancelled by broker", tag)
+		}
+	default:
+	}
+
+	select {
+	case amqpErr, ok := <-closed:
+		if ok && amqpErr != nil {
+			return fmt.Errorf("channel closed: %w", amqpErr)
+		}
+	default:
+	}
+
+	return errors.New("delivery channel closed")
+}
+
+// RabbitMQService mantiene la conexión con RabbitMQ y reinicia los componentes al reconectar
 type RabbitMQService struct {
-	conn   *amqp091.Connection
-	config *config.RabbitMQConfig
+	config     *config.RabbitMQConfig
+	mu         sync.RWMutex
+	conn       *amqp091.Connection
+	status     ConnectionStatus
+	components []Component
+	failures   []int // reinicios seguidos sin éxito de cada componente
+	minDelay   time.Duration
+	maxDelay   time.Duration
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+func NewRabbitMQService(cfg *config.RabbitMQConfig) *RabbitMQService {
+	minDelay := cfg.ReconnectMinDelay
+	if minDelay <= 0 {
+		minDelay = time.Second
+	}
+	maxDelay := cfg.ReconnectMaxDelay
+	if maxDelay < minDelay {
+		maxDelay = minDelay
+	}
+
+	return &RabbitMQService{
+		config:   cfg,
+		minDelay: minDelay,
+		maxDelay: maxDelay,
+		status: ConnectionStatus{
+			State: ConnectionStateConnecting,
+			Since: time.Now(),
+		},
+	}
+}
+
+// Register agrega componentes que se inician en cada conexión. Debe llamarse antes de Start.
+func (r *RabbitMQService) Register(components ...Component) {
+	r.components = append(r.components, components...)
+	r.failures = append(r.failures, make([]int, len(components))...)
+	for _, component := range components {
+		r.status.Components = append(r.status.Components, ComponentStatus{
+			Name:  component.Name(),
+			Since: r.status.Since,
+		})
+	}
+}
+
+// Start inicia la supervisión de la conexión en segundo plano hasta que se cancele el
+// contexto o se llame a Close
+func (r *RabbitMQService) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+		r.supervise(ctx)
+	}()
+}
+
+// Connection retorna la conexión vigente, o ErrNotConnected si no hay una activa
+func (r *RabbitMQService) Connection() (*amqp091.Connection, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.conn == nil || r.conn.IsClosed() || !r.status.State.isConnected() {
+		return nil, ErrNotConnected
+	}
+	return r.conn, nil
+}
+
+// Status retorna el estado actual de la conexión y de los componentes
+func (r *RabbitMQService) Status() ConnectionStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	status := r.status
+	status.Components = append([]ComponentStatus(nil), r.status.Components...)
+	return status
+}
+
+// isConnected indica si hay una conexión abierta, aunque algún componente esté detenido
+func (s ConnectionState) isConnected() bool {
+	return s == ConnectionStateConnected || s == ConnectionStateDegraded
+}
+
+// supervise mantiene la conexión abierta hasta que se cancele el contexto
+func (r *RabbitMQService) supervise(ctx context.Context) {
+	delay := r.minDelay
+
+	for {
+		conn, err := r.connect(ctx)
+		if err != nil {
+			r.setStatus(ConnectionStateDisconnected, nil, err)
+			log.Printf("RabbitMQService: Failed to connect, retrying in %s: %v", delay, err)
+
+			select {
+			case <-ctx.Done():
+				r.setStatus(ConnectionStateClosed, nil, nil)
+				return
+			case <-time.After(delay):
+			}
+
+			delay *= 2
+			if delay > r.maxDelay {
+				delay = r.maxDelay
+			}
+			continue
+		}
+
+		delay = r.minDelay
+		if !r.run(ctx, conn) {
+			return
+		}
+	}
+}
+
+// run inicia los componentes sobre la conexión y la vigila hasta que se pierda, reiniciando
+// los componentes que dejen de consumir. Retorna false si terminó porque se canceló el contexto.
+func (r *RabbitMQService) run(ctx context.Context, conn *amqp091.Connection) bool {
+	// Los componentes de esta conexión se detienen cuando se pierde
+	connCtx, cancelConn := context.WithCancel(ctx)
+	defer cancelConn()
+
+	closed := conn.NotifyClose(make(chan *amqp091.Error, 1))
+	stopped := make(chan componentStop)
+	restart := make(chan int)
+	r.setStatus(ConnectionStateConnected, conn, nil)
+
+	for i := range r.components {
+		r.startComponent(connCtx, conn, i, stopped)
+	}
+	log.Println("Connected to RabbitMQ successfully")
+
+	for {
+		select {
+		case <-ctx.Done():
+			cancelConn()
+			r.closeComponents()
+			conn.Close()
+			r.setStatus(ConnectionStateClosed, nil, nil)
+			log.Println("RabbitMQService: Connection closed")
+			return false
+
+		case amqpErr := <-closed:
+			cancelConn()
+			r.closeComponents()
+
+			var closeErr error = ErrNotConnected
+			if amqpErr != nil {
+				closeErr = amqpErr
+			}
+			r.setStatus(ConnectionStateDisconnected, nil, closeErr)
+
+			r.mu.Lock()
+			r.status.Reconnects++
+			r.mu.Unlock()
+
+			log.Printf("RabbitMQService: Connection lost, reconnecting: %v", closeErr)
+			return true
+
+		case stop := <-stopped:
+			// Si se cerró la conexión, la reconexión reinicia todos los componentes
+			if conn.IsClosed() {
+				continue
+			}
+
+			component := r.components[stop.index]
+			delay := r.restartDelay(stop.index)
+			log.Printf("RabbitMQService: %s stopped, restarting in %s: %v", component.Name(), delay, stop.err)
+			if err := component.Close(); err != nil && !errors.Is(err, amqp091.ErrClosed) {
+				log.Printf("RabbitMQService: Error closing %s: %v", component.Name(), err)
+			}
+			r.setComponentStatus(stop.index, false, stop.err)
+
+			// Los componentes se inician y cierran solo desde este loop
+			go func(index int) {
+				select {
+				case <-connCtx.Done():
+				case <-time.After(delay):
+					select {
+					case restart <- index:
+					case <-connCtx.Done():
+					}
+				}
+			}(stop.index)
+
+		case index := <-restart:
+			r.startComponent(connCtx, conn, index, stopped)
+		}
+	}
 }
 
-// MessagePublisher interface for publishing messages
-type MessagePublisher interface {
-	PublishLowStockAlert(ctx context.Context, articleID string, currentQuantity, minStock int) error
+// startComponent inicia el componente y reenvía a stopped la causa si deja de consumir. Si no
+// puede iniciarse, lo informa por stopped para que se reintente con backoff.
+func (r *RabbitMQService) startComponent(ctx context.Context, conn *amqp091.Connection, index int, stopped chan<- componentStop) {
+	report := func(err error) {
+		select {
+		case stopped <- componentStop{index: index, err: err}:
+		case <-ctx.Done():
+		}
+	}
+
+	component := r.components[index]
+	done, err := component.Start(ctx, conn)
+	if err != nil {
+		go report(fmt.Errorf("error starting %s: %w", component.Name(), err))
+		return
+	}
+	r.setComponentStatus(index, true, nil)
+
+	go func() {
+		err, ok := <-done
+		if !ok {
+			return
+		}
+		if err == nil {
+			err = errComponentStopped
+		}
+		report(err)
+	}()
 }
 
-func NewRabbitMQService(cfg *config.RabbitMQConfig) (*RabbitMQService, error) {
-	conn, err := amqp091.Dial(cfg.URL)
+// restartDelay calcula la espera antes de reiniciar un componente detenido, con el mismo
+// backoff que la reconexión según la cantidad de reinicios seguidos sin éxito
+func (r *RabbitMQService) restartDelay(index int) time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Components[index].Restarts++
+
+	delay := r.minDelay
+	for i := 0; i < r.failures[index] && delay < r.maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > r.maxDelay {
+		delay = r.maxDelay
+	}
+	r.failures[index]++
+	return delay
+}
+
+// connect abre la conexión y declara la topología de publicación
+func (r *RabbitMQService) connect(ctx context.Context) (*amqp091.Connection, error) {
+	conn, err := amqp091.Dial(r.config.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to RabbitMQ: %w", err)
 	}
 
-	service := &RabbitMQService{
-		conn:   conn,
-		config: cfg,
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error opening channel: %w", err)
+	}
+	err = declarePublisherTopology(ch)
+	ch.Close()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error declaring publisher topology: %w", err)
 	}
 
-	log.Println("Connected to RabbitMQ successfully")
-	return service, nil
+	return conn, nil
 }
 
-// GetConnection returns the RabbitMQ connection
-func (r *RabbitMQService) GetConnection() *amqp091.Connection {
-	return r.conn
+// closeComponents cierra los componentes registrados, ignorando los que ya estaban cerrados
+func (r *RabbitMQService) closeComponents() {
+	for i, component := range r.components {
+		if err := component.Close(); err != nil && !errors.Is(err, amqp091.ErrClosed) {
+			log.Printf("RabbitMQService: Error closing %s: %v", component.Name(), err)
+		}
+		r.setComponentStatus(i, false, nil)
+	}
 }
 
-// Close closes the RabbitMQ connection
+// setComponentStatus registra si el componente está consumiendo. Con la conexión abierta, el
+// estado pasa a degraded mientras algún componente esté detenido.
+func (r *RabbitMQService) setComponentStatus(index int, running bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := &r.status.Components[index]
+	if status.Running != running {
+		status.Running = running
+		status.Since = time.Now()
+	}
+	if err != nil {
+		status.LastError = err.Error()
+	}
+	if running {
+		r.failures[index] = 0
+	}
+
+	if !r.status.State.isConnected() {
+		return
+	}
+	state := ConnectionStateConnected
+	for _, component := range r.status.Components {
+		if !component.Running {
+			state = ConnectionStateDegraded
+			break
+		}
+	}
+	if r.status.State != state {
+		r.status.State = state
+		r.status.Since = time.Now()
+	}
+}
+
+func (r *RabbitMQService) setStatus(state ConnectionState, conn *amqp091.Connection, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.conn = conn
+	if r.status.State != state {
+		r.status.State = state
+		r.status.Since = time.Now()
+	}
+	if err != nil {
+		r.status.LastError = err.Error()
+	}
+}
+
+// Close detiene la supervisión, cierra los componentes y la conexión
 func (r *RabbitMQService) Close() error {
-	if r.conn != nil {
-		return r.conn.Close()
+	if r.cancel == nil {
+		return nil
+	}
+
+	r.cancel()
+	<-r.done
+	return nil
+}
+
+// declarePublisherTopology declara el exchange de publicación y la cola de alertas de stock bajo
+func declarePublisherTopology(ch *amqp091.Channel) error {
+	// Declarar exchange principal
+	err := ch.ExchangeDeclare(
+		ecommerceExchange, // name
+		"topic",           // type
+		true,              // durable
+		false,             // auto-deleted
+		false,             // internal
+		false,             // no-wait
+		nil,               // arguments
+	)
+	if err != nil {
+		return err
+	}
+
+	// Declarar cola para alertas de stock bajo
+	queue, err := ch.QueueDeclare(
+		lowStockQueue, // name
+		true,          // durable
+		false,         // delete when unused
+		false,         // exclusive
+		false,         // no-wait
+		nil,           // arguments
+	)
+	if err != nil {
+		return err
 	}
+
+	// Bind cola al exchange
+	return ch.QueueBind(
+		queue.Name,         // queue name
+		lowStockRoutingKey, // routing key
+		ecommerceExchange,  // exchange
+		false,
+		nil,
+	)
+}
+
+// publishConfirmed publica un mensaje en un canal en modo confirm y espera la confirmación del broker
+func publishConfirmed(ctx context.Context, ch *amqp091.Channel, exchange, routingKey string, msg amqp091.Publishing) error {
+	publishCtx, cancel := context.WithTimeout(ctx, publishConfirmTimeout)
+	defer cancel()
+
+	confirmation, err := ch.PublishWithDeferredConfirmWithContext(
+		publishCtx,
+		exchange,   // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		msg,
+	)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(publishCtx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("message nacked by broker")
+	}
+
 	return nil
 }
@@ -0,0 +1,62 @@
+package messaging
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// ReservationExpiredPublisher maneja la publicación de reservas vencidas.
+// Los mensajes se escriben en el outbox y el OutboxRelay los publica en RabbitMQ.
+type ReservationExpiredPublisher struct {
+	outboxRepo *repository.OutboxRepository
+}
+
+// ReservationExpiredMessage representa el mensaje de reserva vencida
+type ReservationExpiredMessage struct {
+	ReservationID string    `json:"reservation_id"`
+	OrderID       string    `json:"order_id"`
+	ArticleID     string    `json:"article_id"`
+	Quantity      int       `json:"quantity"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	ExpiredAt     time.Time `json:"expired_at"`
+}
+
+func NewReservationExpiredPublisher(outboxRepo *repository.OutboxRepository) *ReservationExpiredPublisher {
+	return &ReservationExpiredPublisher{
+		outboxRepo: outboxRepo,
+	}
+}
+
+// PublishReservationExpired encola un mensaje de reserva vencida dentro de la transacción indicada
+func (p *ReservationExpiredPublisher) PublishReservationExpired(ctx context.Context, tx pgx.Tx, reservation *models.StockReservation) error {
+	payload := ReservationExpiredMessage{
+		ReservationID: reservation.ID.String(),
+		OrderID:       reservation.OrderID,
+		ArticleID:     reservation.ArticleID,
+		Quantity:      reservation.Quantity,
+		ExpiredAt:     time.Now(),
+	}
+	if reservation.ExpiresAt != nil {
+		payload.ExpiresAt = *reservation.ExpiresAt
+	}
+
+	message, err := models.NewOutboxMessage(models.OutboxAggregateOrder, reservation.OrderID, ecommerceExchange, reservationExpiredRoutingKey, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := p.outboxRepo.WithTx(tx).Enqueue(ctx, message); err != nil {
+		log.Printf("ReservationExpiredPublisher: Failed to enqueue expiry for order %s, article %s: %v",
+			reservation.OrderID, reservation.ArticleID, err)
+		return err
+	}
+
+	log.Printf("ReservationExpiredPublisher: Enqueued reservation expired for order %s, article %s",
+		reservation.OrderID, reservation.ArticleID)
+	return nil
+}
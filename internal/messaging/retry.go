@@ -0,0 +1,227 @@
+package messaging
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/config"
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/google/uuid"
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Sufijos de las colas auxiliares de cada cola de consumer
+const (
+	retryQueueSuffix      = ".retry"
+	deadLetterQueueSuffix = ".dlq"
+)
+
+// Headers agregados a los mensajes reprogramados y a los aparcados en la cola *.dlq
+const (
+	retryAttemptsHeader  = "x-retry-attempts"
+	parkedIDHeader       = "x-parked-id"
+	parkedReasonHeader   = "x-parked-reason"
+	parkedAttemptsHeader = "x-parked-attempts"
+	parkedAtHeader       = "x-parked-at"
+	originalQueueHeader  = "x-original-queue"
+)
+
+// declareRetryableQueue declara la cola de un consumer junto con sus colas <queue>.retry y
+// <queue>.dlq. El dead-letter de la cola principal se configura con una policy.
+func declareRetryableQueue(ch *amqp091.Channel, queue string) error {
+	if _, err := ch.QueueDeclare(
+		queue+deadLetterQueueSuffix, // name
+		true,                        // durable
+		false,                       // delete when unused
+		false,                       // exclusive
+		false,                       // no-wait
+		nil,                         // arguments
+	); err != nil {
+		return err
+	}
+
+	if _, err := ch.QueueDeclare(
+		queue+retryQueueSuffix, // name
+		true,                   // durable
+		false,                  // delete when unused
+		false,                  // exclusive
+		false,                  // no-wait
+		amqp091.Table{
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		},
+	); err != nil {
+		return err
+	}
+
+	_, err := ch.QueueDeclare(
+		queue, // name
+		true,  // durable
+		false, // delete when unused
+		false, // exclusive
+		false, // no-wait
+		nil,   // arguments
+	)
+	return err
+}
+
+// handleDeliveryFailure reprograma el mensaje fallido en la cola de reintentos o lo aparca en la DLQ
+func handleDeliveryFailure(ctx context.Context, ch *amqp091.Channel, consumerName, queue string, retry config.RetryConfig, msg amqp091.Delivery, processErr error, recoverable bool) {
+	attempt := deliveryAttempts(msg, queue) + 1
+
+	if recoverable && attempt < retry.MaxAttempts {
+		delay := retryDelay(retry, attempt)
+
+		headers := copyHeaders(msg.Headers)
+		headers[retryAttemptsHeader] = int32(attempt)
+
+		retryMsg := republishing(msg, headers)
+		retryMsg.Expiration = strconv.FormatInt(delay.Milliseconds(), 10)
+
+		if err := publishConfirmed(ctx, ch, "", queue+retryQueueSuffix, retryMsg); err != nil {
+			log.Printf("%s: Failed to schedule retry, requeuing message: %v", consumerName, err)
+			msg.Nack(false, true)
+			return
+		}
+
+		log.Printf("%s: Recoverable error on attempt %d/%d, retrying in %s: %v",
+			consumerName, attempt, retry.MaxAttempts, delay, processErr)
+		msg.Ack(false)
+		return
+	}
+
+	headers := copyHeaders(msg.Headers)
+	headers[parkedIDHeader] = uuid.New().String()
+	headers[parkedReasonHeader] = processErr.Error()
+	headers[parkedAttemptsHeader] = int32(attempt)
+	headers[parkedAtHeader] = time.Now().UTC().Format(time.RFC3339)
+	headers[originalQueueHeader] = queue
+
+	if err := publishConfirmed(ctx, ch, "", queue+deadLetterQueueSuffix, republishing(msg, headers)); err != nil {
+		// Se reencola para no perderlo si la cola principal no tiene dead-letter configurado
+		log.Printf("%s: Failed to park message, requeuing it: %v", consumerName, err)
+		msg.Nack(false, true)
+		return
+	}
+
+	log.Printf("%s: Parked message in %s after %d attempt(s): %v",
+		consumerName, queue+deadLetterQueueSuffix, attempt, processErr)
+	msg.Ack(false)
+}
+
+// isRetryableError indica si el error es transitorio; los errores de dominio no se reintentan
+func isRetryableError(err error) bool {
+	var insufficientStock *models.ErrInsufficientStock
+	var reservationClosed *models.ErrReservationClosed
+	var articleUnavailable *models.ErrArticleUnavailable
+
+	switch {
+	case errors.Is(err, models.ErrInvalidOrder),
+		errors.Is(err, models.ErrArticleNotFound),
+		errors.Is(err, models.ErrDuplicateReservation),
+		errors.Is(err, models.ErrReservationNotFound),
+		errors.Is(err, models.ErrInsufficientReservedStock),
+		errors.As(err, &insufficientStock),
+		errors.As(err, &reservationClosed),
+		errors.As(err, &articleUnavailable):
+		return false
+	}
+
+	return true
+}
+
+// deliveryAttempts obtiene cuántas veces el mensaje ya pasó por la cola de reintentos
+func deliveryAttempts(msg amqp091.Delivery, queue string) int {
+	attempts := headerInt(msg.Headers[retryAttemptsHeader])
+
+	deaths, _ := msg.Headers["x-death"].([]interface{})
+	for _, entry := range deaths {
+		death, ok := entry.(amqp091.Table)
+		if !ok {
+			continue
+		}
+		if death["queue"] != queue+retryQueueSuffix || death["reason"] != "expired" {
+			continue
+		}
+
+		if count := headerInt(death["count"]); count > attempts {
+			attempts = count
+		}
+	}
+
+	return attempts
+}
+
+// headerInt convierte un valor numérico de un header AMQP a int
+func headerInt(value interface{}) int {
+	switch v := value.(type) {
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// retryDelay calcula la espera antes del intento indicado (base, 2*base, 4*base... hasta MaxDelay)
+func retryDelay(retry config.RetryConfig, attempt int) time.Duration {
+	delay := retry.BaseDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+	for i := 1; i < attempt && (retry.MaxDelay <= 0 || delay < retry.MaxDelay); i++ {
+		delay *= 2
+	}
+	if retry.MaxDelay > 0 && delay > retry.MaxDelay {
+		delay = retry.MaxDelay
+	}
+	return delay
+}
+
+// copyHeaders retorna una copia de los headers de un mensaje
+func copyHeaders(headers amqp091.Table) amqp091.Table {
+	copied := amqp091.Table{}
+	for key, value := range headers {
+		copied[key] = value
+	}
+	return copied
+}
+
+// republishing copia las propiedades de un mensaje recibido para volver a publicarlo
+func republishing(msg amqp091.Delivery, headers amqp091.Table) amqp091.Publishing {
+	return amqp091.Publishing{
+		Headers:         headers,
+		ContentType:     msg.ContentType,
+		ContentEncoding: msg.ContentEncoding,
+		DeliveryMode:    amqp091.Persistent,
+		Priority:        msg.Priority,
+		CorrelationId:   msg.CorrelationId,
+		ReplyTo:         msg.ReplyTo,
+		MessageId:       msg.MessageId,
+		Timestamp:       msg.Timestamp,
+		Type:            msg.Type,
+		UserId:          msg.UserId,
+		AppId:           msg.AppId,
+		Body:            msg.Body,
+	}
+}
+
+// parkedMessageID identifica un mensaje aparcado. Los mensajes que llegaron a la DLQ por
+// dead-letter (sin header propio) se identifican por message ID o por el hash del body.
+func parkedMessageID(msg amqp091.Delivery) string {
+	if id, ok := msg.Headers[parkedIDHeader].(string); ok && id != "" {
+		return id
+	}
+	if msg.MessageId != "" {
+		return msg.MessageId
+	}
+	sum := sha256.Sum256(msg.Body)
+	return hex.EncodeToString(sum[:8])
+}
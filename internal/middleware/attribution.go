@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+)
+
+// Headers con los que un cliente puede propagar sus IDs de correlación y causalidad
+const (
+	HeaderCorrelationID = "X-Correlation-ID"
+	HeaderCausationID   = "X-Causation-ID"
+)
+
+// serviceRole es el rol de las credenciales de servicio emitidas por el servicio de auth
+const serviceRole = "service"
+
+// AttributionMiddleware agrega al contexto de la request la atribución de los eventos de stock.
+// Debe registrarse después de requestid.
+func AttributionMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+
+		attribution := models.Attribution{
+			ActorType:     models.ActorTypeAnonymous,
+			RequestID:     requestID,
+			CorrelationID: c.Get(HeaderCorrelationID),
+			CausationID:   c.Get(HeaderCausationID),
+		}
+		if attribution.CorrelationID == "" {
+			attribution.CorrelationID = requestID
+		}
+		if attribution.CausationID == "" {
+			attribution.CausationID = requestID
+		}
+
+		c.SetUserContext(models.ContextWithAttribution(c.UserContext(), attribution))
+		return c.Next()
+	}
+}
+
+// OptionalAuthMiddleware valida el token si la request lo trae, para atribuir los movimientos
+// al usuario; sin token la request continúa como anónima. Un token inválido se rechaza.
+func OptionalAuthMiddleware(authService *service.AuthService) fiber.Handler {
+	auth := AuthMiddleware(authService)
+
+	return func(c *fiber.Ctx) error {
+		if extractToken(c) == "" {
+			return c.Next()
+		}
+		return auth(c)
+	}
+}
+
+// attributeUser registra al usuario autenticado como actor de la request
+func attributeUser(c *fiber.Ctx, user *service.UserResponse) {
+	attribution := models.AttributionFromContext(c.UserContext())
+
+	attribution.ActorType = models.ActorTypeUser
+	if user.Role == serviceRole {
+		attribution.ActorType = models.ActorTypeService
+	}
+	attribution.ActorID = user.ID
+	attribution.ActorName = user.Username
+
+	c.SetUserContext(models.ContextWithAttribution(c.UserContext(), attribution))
+}
@@ -31,6 +31,7 @@ func AuthMiddleware(authService *service.AuthService) fiber.Handler {
 		c.Locals("user_id", user.ID)
 		c.Locals("username", user.Username)
 		c.Locals("user", user)
+		attributeUser(c, user)
 
 		// 4. Continuar con el siguiente handler
 		return c.Next()
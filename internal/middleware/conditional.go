@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+// IfMatchMiddleware agrega al contexto de la request las versiones del header If-Match. Solo se
+// debe usar en rutas que modifican un único artículo.
+func IfMatchMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderIfMatch)
+		if header == "" {
+			return c.Next()
+		}
+
+		versions, err := models.ParseIfMatch(header)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "If-Match must be an ETag returned by GET /api/stock/articles/:articleId",
+				"details": err.Error(),
+			})
+		}
+
+		if len(versions) > 0 {
+			c.SetUserContext(models.ContextWithExpectedVersions(c.UserContext(), versions))
+		}
+		return c.Next()
+	}
+}
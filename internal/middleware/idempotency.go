@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Headers de las requests idempotentes
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+)
+
+// IdempotencyMiddleware hace idempotente la ruta cuando la request trae el header
+// Idempotency-Key. Debe registrarse después de AuthMiddleware u OptionalAuthMiddleware.
+func IdempotencyMiddleware(idempotencyService *service.IdempotencyService) fiber.Handler {
+	return idempotency(idempotencyService.Execute)
+}
+
+// IdempotencyOutcomeMiddleware es como IdempotencyMiddleware para rutas que manejan sus propias
+// transacciones
+func IdempotencyOutcomeMiddleware(idempotencyService *service.IdempotencyService) fiber.Handler {
+	return idempotency(idempotencyService.ExecuteRecorded)
+}
+
+type idempotentExecutor func(ctx context.Context, key *models.IdempotencyKey, handle service.IdempotentHandler) (*models.IdempotencyKey, error)
+
+func idempotency(execute idempotentExecutor) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		if key == "" {
+			return c.Next()
+		}
+		if len(key) > models.MaxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": fmt.Sprintf("Idempotency-Key cannot be longer than %d characters", models.MaxIdempotencyKeyLength),
+			})
+		}
+
+		record := &models.IdempotencyKey{
+			Scope:       idempotencyScope(c.UserContext()),
+			Key:         key,
+			RequestHash: requestHash(c),
+			Method:      c.Method(),
+			Path:        c.OriginalURL(),
+		}
+
+		requestCtx := c.UserContext()
+		entry, err := execute(requestCtx, record, func(ctx context.Context) (int, string, []byte, error) {
+			// Con Execute, los servicios que use el handler se anidan en la transacción del registro
+			c.SetUserContext(ctx)
+			defer c.SetUserContext(requestCtx)
+
+			if err := c.Next(); err != nil {
+				if err := c.App().ErrorHandler(c, err); err != nil {
+					return 0, "", nil, err
+				}
+			}
+
+			response := c.Response()
+			body := append([]byte(nil), response.Body()...)
+			return response.StatusCode(), string(response.Header.ContentType()), body, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if !entry.Replayed {
+			return nil
+		}
+
+		c.Set(HeaderIdempotentReplayed, "true")
+		if entry.ContentType != "" {
+			c.Set(fiber.HeaderContentType, entry.ContentType)
+		}
+		return c.Status(entry.StatusCode).Send(entry.ResponseBody)
+	}
+}
+
+// idempotencyScope separa las claves de cada actor, para que dos clientes no compartan
+// respuestas por usar la misma clave
+func idempotencyScope(ctx context.Context) string {
+	attribution := models.AttributionFromContext(ctx)
+	if attribution.ActorID == "" {
+		return string(models.ActorTypeAnonymous)
+	}
+	return string(attribution.ActorType) + ":" + attribution.ActorID
+}
+
+// requestHash identifica la request por método, ruta (con query), If-Match y cuerpo
+func requestHash(c *fiber.Ctx) string {
+	hash := sha256.New()
+	hash.Write([]byte(c.Method()))
+	hash.Write([]byte{'\n'})
+	hash.Write([]byte(c.OriginalURL()))
+	hash.Write([]byte{'\n'})
+	hash.Write([]byte(c.Get(fiber.HeaderIfMatch)))
+	hash.Write([]byte{'\n'})
+	hash.Write(c.Body())
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MatiasTelo/stockgo/internal/handlers"
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/service"
+	"github.com/gofiber/fiber/v2"
+)
+
+// fakeIdempotencyStore reproduce las reglas de IdempotencyService.Execute en memoria
+type fakeIdempotencyStore struct {
+	entries map[string]*models.IdempotencyKey
+}
+
+func (s *fakeIdempotencyStore) execute(ctx context.Context, key *models.IdempotencyKey, handle service.IdempotentHandler) (*models.IdempotencyKey, error) {
+	id := key.Scope + "|" + key.Key
+	if entry, ok := s.entries[id]; ok {
+		if entry.RequestHash != key.RequestHash {
+			return nil, fmt.Errorf("%w: %s %s", models.ErrIdempotencyKeyReused, entry.Method, entry.Path)
+		}
+		replay := *entry
+		replay.Replayed = true
+		return &replay, nil
+	}
+
+	statusCode, contentType, body, err := handle(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key.StatusCode, key.ContentType, key.ResponseBody = statusCode, contentType, body
+	if statusCode < 500 {
+		s.entries[id] = key
+	}
+	return key, nil
+}
+
+type idempotencyTestRequest struct {
+	method  string
+	path    string
+	key     string
+	ifMatch string
+	actor   string
+	body    string
+}
+
+func (r idempotencyTestRequest) do(t *testing.T, app *fiber.App) (int, string, bool) {
+	t.Helper()
+
+	req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if r.key != "" {
+		req.Header.Set(HeaderIdempotencyKey, r.key)
+	}
+	if r.ifMatch != "" {
+		req.Header.Set(fiber.HeaderIfMatch, r.ifMatch)
+	}
+	if r.actor != "" {
+		req.Header.Set("X-Test-Actor", r.actor)
+	}
+
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body), resp.Header.Get(HeaderIdempotentReplayed) == "true"
+}
+
+func newIdempotencyTestApp(calls *int) *fiber.App {
+	store := &fakeIdempotencyStore{entries: make(map[string]*models.IdempotencyKey)}
+
+	app := fiber.New(fiber.Config{ErrorHandler: handlers.ErrorHandler})
+	app.Use(func(c *fiber.Ctx) error {
+		if actor := c.Get("X-Test-Actor"); actor != "" {
+			attribution := models.Attribution{ActorType: models.ActorTypeUser, ActorID: actor}
+			c.SetUserContext(models.ContextWithAttribution(c.UserContext(), attribution))
+		}
+		return c.Next()
+	})
+
+	handler := func(c *fiber.Ctx) error {
+		*calls++
+		if c.Query("fail") != "" {
+			return c.Status(fiber.StatusServiceUnavailable).SendString("unavailable")
+		}
+		return c.Status(fiber.StatusCreated).SendString(fmt.Sprintf("call %d", *calls))
+	}
+	app.Put("/stock", idempotency(store.execute), handler)
+	app.Put("/other", idempotency(store.execute), handler)
+	return app
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	first := idempotencyTestRequest{method: fiber.MethodPut, path: "/stock", key: "k1", body: `{"quantity":1}`}
+
+	withPath := first
+	withPath.path = "/other"
+	withQuery := first
+	withQuery.path = "/stock?dry_run=true"
+	withBody := first
+	withBody.body = `{"quantity":2}`
+	withIfMatch := first
+	withIfMatch.ifMatch = `"3"`
+	withActor := first
+	withActor.actor = "user-2"
+	withKey := first
+	withKey.key = "k2"
+	withoutKey := first
+	withoutKey.key = ""
+
+	tests := []struct {
+		name         string
+		retry        idempotencyTestRequest
+		wantStatus   int
+		wantReplayed bool
+		wantCalls    int
+	}{
+		{name: "same request is replayed", retry: first, wantStatus: fiber.StatusCreated, wantReplayed: true, wantCalls: 1},
+		{name: "other path reuses the key", retry: withPath, wantStatus: fiber.StatusUnprocessableEntity, wantCalls: 1},
+		{name: "other query reuses the key", retry: withQuery, wantStatus: fiber.StatusUnprocessableEntity, wantCalls: 1},
+		{name: "other body reuses the key", retry: withBody, wantStatus: fiber.StatusUnprocessableEntity, wantCalls: 1},
+		{name: "other If-Match reuses the key", retry: withIfMatch, wantStatus: fiber.StatusUnprocessableEntity, wantCalls: 1},
+		{name: "other actor has its own keys", retry: withActor, wantStatus: fiber.StatusCreated, wantCalls: 2},
+		{name: "other key runs again", retry: withKey, wantStatus: fiber.StatusCreated, wantCalls: 2},
+		{name: "without key runs again", retry: withoutKey, wantStatus: fiber.StatusCreated, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			app := newIdempotencyTestApp(&calls)
+
+			status, firstBody, replayed := first.do(t, app)
+			if status != fiber.StatusCreated || replayed {
+				t.Fatalf("expected a fresh 201, got %d (replayed %v)", status, replayed)
+			}
+
+			status, body, replayed := tt.retry.do(t, app)
+			if status != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, status, body)
+			}
+			if replayed != tt.wantReplayed {
+				t.Errorf("expected replayed %v, got %v", tt.wantReplayed, replayed)
+			}
+			if tt.wantReplayed && body != firstBody {
+				t.Errorf("expected the original body %q, got %q", firstBody, body)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("expected the handler to run %d time(s), got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyMiddlewareServerErrorIsNotStored(t *testing.T) {
+	calls := 0
+	app := newIdempotencyTestApp(&calls)
+	req := idempotencyTestRequest{method: fiber.MethodPut, path: "/stock?fail=1", key: "k1"}
+
+	for i := 1; i <= 2; i++ {
+		status, _, replayed := req.do(t, app)
+		if status != fiber.StatusServiceUnavailable || replayed {
+			t.Fatalf("attempt %d: expected a fresh 503, got %d (replayed %v)", i, status, replayed)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected the handler to run twice, got %d", calls)
+	}
+}
+
+func TestIdempotencyMiddlewareKeyTooLong(t *testing.T) {
+	calls := 0
+	app := newIdempotencyTestApp(&calls)
+	req := idempotencyTestRequest{method: fiber.MethodPut, path: "/stock", key: strings.Repeat("k", models.MaxIdempotencyKeyLength+1)}
+
+	if status, _, _ := req.do(t, app); status != fiber.StatusBadRequest {
+		t.Errorf("expected 400, got %d", status)
+	}
+	if calls != 0 {
+		t.Errorf("expected the handler not to run, got %d call(s)", calls)
+	}
+}
+
+func TestRequestHash(t *testing.T) {
+	base := idempotencyTestRequest{method: fiber.MethodPut, path: "/stock?a=1", ifMatch: `"1"`, body: "{}"}
+
+	tests := []struct {
+		name     string
+		change   func(r *idempotencyTestRequest)
+		wantSame bool
+	}{
+		{name: "same request", change: func(r *idempotencyTestRequest) {}, wantSame: true},
+		{name: "idempotency key is not hashed", change: func(r *idempotencyTestRequest) { r.key = "other" }, wantSame: true},
+		{name: "method", change: func(r *idempotencyTestRequest) { r.method = fiber.MethodPost }},
+		{name: "path", change: func(r *idempotencyTestRequest) { r.path = "/other?a=1" }},
+		{name: "query", change: func(r *idempotencyTestRequest) { r.path = "/stock?a=2" }},
+		{name: "If-Match", change: func(r *idempotencyTestRequest) { r.ifMatch = `"2"` }},
+		{name: "missing If-Match", change: func(r *idempotencyTestRequest) { r.ifMatch = "" }},
+		{name: "body", change: func(r *idempotencyTestRequest) { r.body = `{"a":1}` }},
+		// Los separadores evitan que dos requests distintas concatenen al mismo texto
+		{name: "If-Match moved into the body", change: func(r *idempotencyTestRequest) { r.ifMatch = ""; r.body = "\"1\"\n{}" }},
+	}
+
+	hashOf := func(r idempotencyTestRequest) string {
+		var hash string
+		app := fiber.New()
+		app.All("/*", func(c *fiber.Ctx) error {
+			hash = requestHash(c)
+			return nil
+		})
+		r.do(t, app)
+		return hash
+	}
+	want := hashOf(base)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := base
+			tt.change(&req)
+
+			if got := hashOf(req); (got == want) != tt.wantSame {
+				t.Errorf("expected same hash %v, got %s vs %s", tt.wantSame, got, want)
+			}
+		})
+	}
+}
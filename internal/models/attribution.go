@@ -0,0 +1,50 @@
+package models
+
+import "context"
+
+// ActorType identifica qué clase de actor originó un movimiento de stock
+type ActorType string
+
+const (
+	ActorTypeUser      ActorType = "USER"      // usuario autenticado con token Bearer
+	ActorTypeService   ActorType = "SERVICE"   // credencial de servicio (token con rol "service")
+	ActorTypeConsumer  ActorType = "CONSUMER"  // consumer de RabbitMQ procesando un mensaje
+	ActorTypeSystem    ActorType = "SYSTEM"    // proceso interno (barrido de reservas vencidas)
+	ActorTypeAnonymous ActorType = "ANONYMOUS" // request HTTP sin autenticar
+)
+
+// IsValid verifica si el tipo de actor es uno de los conocidos
+func (t ActorType) IsValid() bool {
+	switch t {
+	case ActorTypeUser, ActorTypeService, ActorTypeConsumer, ActorTypeSystem, ActorTypeAnonymous:
+		return true
+	default:
+		return false
+	}
+}
+
+// Attribution registra quién o qué causó un movimiento de stock y con qué request o
+// mensaje se relaciona, para poder rastrear cada evento hasta su origen
+type Attribution struct {
+	ActorType     ActorType `json:"actor_type,omitempty" db:"actor_type"`
+	ActorID       string    `json:"actor_id,omitempty" db:"actor_id"`
+	ActorName     string    `json:"actor_name,omitempty" db:"actor_name"`
+	RequestID     string    `json:"request_id,omitempty" db:"request_id"`
+	MessageID     string    `json:"message_id,omitempty" db:"message_id"`
+	CorrelationID string    `json:"correlation_id,omitempty" db:"correlation_id"`
+	CausationID   string    `json:"causation_id,omitempty" db:"causation_id"`
+}
+
+type attributionContextKey struct{}
+
+// ContextWithAttribution retorna un contexto que transporta la atribución indicada; los
+// eventos de stock registrados con ese contexto la heredan
+func ContextWithAttribution(ctx context.Context, attribution Attribution) context.Context {
+	return context.WithValue(ctx, attributionContextKey{}, attribution)
+}
+
+// AttributionFromContext retorna la atribución del contexto, o una vacía si no tiene
+func AttributionFromContext(ctx context.Context) Attribution {
+	attribution, _ := ctx.Value(attributionContextKey{}).(Attribution)
+	return attribution
+}
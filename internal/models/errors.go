@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errores de dominio retornados por los repositorios y el servicio
+var (
+	ErrArticleNotFound           = errors.New("article not found")
+	ErrArticleAlreadyExists      = errors.New("article already exists")
+	ErrDuplicateReservation      = errors.New("order already has an active reservation for article")
+	ErrReservationNotFound       = errors.New("no active reservation found for this order and article")
+	ErrInsufficientReservedStock = errors.New("insufficient reserved stock")
+	ErrInvalidOrder              = errors.New("invalid order format")
+	ErrInvalidCursor             = errors.New("invalid cursor")
+	ErrReconciliationRunNotFound = errors.New("reconciliation run not found")
+	ErrReconciliationInProgress  = errors.New("another reconciliation run is in progress")
+	ErrSnapshotInProgress        = errors.New("another snapshot run is in progress")
+	ErrHistoryArchived           = errors.New("stock history is archived")
+	ErrArchiveInProgress         = errors.New("another archive run is in progress")
+	ErrInvalidStockSettings      = errors.New("invalid stock settings")
+	ErrInvalidStockStatus        = errors.New("invalid stock status")
+	ErrStockCountNotFound        = errors.New("stock count not found")
+	ErrStockCountAlreadyOpen     = errors.New("location already has an open stock count")
+	ErrStockCountClosed          = errors.New("stock count is no longer open")
+	ErrInvalidStockCount         = errors.New("invalid stock count")
+	ErrInvalidImport             = errors.New("invalid import")
+	ErrInvalidBatch              = errors.New("invalid batch")
+	ErrIdempotencyKeyReused      = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress  = errors.New("a request with this idempotency key is still in progress")
+)
+
+// ErrInsufficientStock indica que no hay stock disponible suficiente para un artículo
+type ErrInsufficientStock struct {
+	ArticleID string
+	Available int
+	Requested int
+}
+
+func (e *ErrInsufficientStock) Error() string {
+	if e.ArticleID == "" {
+		return fmt.Sprintf("insufficient stock: available %d, requested %d", e.Available, e.Requested)
+	}
+	return fmt.Sprintf("insufficient stock for article %s: available %d, requested %d", e.ArticleID, e.Available, e.Requested)
+}
+
+// ErrArticleUnavailable indica que el estado del artículo no admite la operación pedida
+type ErrArticleUnavailable struct {
+	ArticleID string
+	Status    StockStatus
+	Operation string
+}
+
+func (e *ErrArticleUnavailable) Error() string {
+	return fmt.Sprintf("article %s is %s and does not accept %s", e.ArticleID, strings.ToLower(string(e.Status)), e.Operation)
+}
+
+// ErrCountReservationConflict indica que la cantidad contada de algunos artículos es menor que
+// su stock reservado: ajustarlos dejaría reserved > quantity
+type ErrCountReservationConflict struct {
+	ArticleIDs []string
+}
+
+func (e *ErrCountReservationConflict) Error() string {
+	return fmt.Sprintf("counted quantity is below reserved stock for articles: %s", strings.Join(e.ArticleIDs, ", "))
+}
+
+// ErrBatchOperationFailed indica que una operación de un lote todo-o-nada falló y se deshizo
+// el lote completo. Result informa el estado de cada operación.
+type ErrBatchOperationFailed struct {
+	Index  int
+	Err    error
+	Result *StockBatchResult
+}
+
+func (e *ErrBatchOperationFailed) Error() string {
+	return fmt.Sprintf("batch operation %d failed: %v", e.Index, e.Err)
+}
+
+func (e *ErrBatchOperationFailed) Unwrap() error {
+	return e.Err
+}
+
+// ErrVersionMismatch indica que el artículo cambió desde la versión indicada en If-Match.
+// CurrentVersion es 0 si el artículo no existe.
+type ErrVersionMismatch struct {
+	ArticleID      string
+	CurrentVersion int64
+}
+
+func (e *ErrVersionMismatch) Error() string {
+	if e.CurrentVersion == 0 {
+		return fmt.Sprintf("article %s does not exist", e.ArticleID)
+	}
+	return fmt.Sprintf("article %s has changed: current version is %d", e.ArticleID, e.CurrentVersion)
+}
+
+// ErrReservationClosed indica que la reserva ya no está activa y no admite la transición pedida
+type ErrReservationClosed struct {
+	Status ReservationStatus
+}
+
+func (e *ErrReservationClosed) Error() string {
+	switch e.Status {
+	case ReservationStatusCancelled:
+		return "reservation has already been cancelled"
+	case ReservationStatusConfirmed:
+		return "reservation has already been confirmed"
+	case ReservationStatusExpired:
+		return "reservation has expired"
+	default:
+		return fmt.Sprintf("reservation is %s", e.Status)
+	}
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// MaxIdempotencyKeyLength es el largo máximo del header Idempotency-Key (columna VARCHAR(255))
+const MaxIdempotencyKeyLength = 255
+
+// IdempotencyKey es la respuesta registrada para una request enviada con el header Idempotency-Key
+type IdempotencyKey struct {
+	Scope        string    `json:"scope" db:"scope"`
+	Key          string    `json:"idempotency_key" db:"idempotency_key"`
+	RequestHash  string    `json:"request_hash" db:"request_hash"`
+	Method       string    `json:"method" db:"method"`
+	Path         string    `json:"path" db:"path"`
+	StatusCode   int       `json:"status_code" db:"status_code"`
+	ContentType  string    `json:"content_type" db:"content_type"`
+	ResponseBody []byte    `json:"-" db:"response_body"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
+
+	// Replayed indica que la clave ya estaba registrada y la respuesta es la original
+	Replayed bool `json:"-" db:"-"`
+}
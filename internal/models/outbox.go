@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage representa un mensaje pendiente de publicar en RabbitMQ.
+// Se escribe en la misma transacción que el cambio de stock que lo origina.
+type OutboxMessage struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	Sequence      int64           `json:"sequence" db:"sequence"`
+	AggregateType string          `json:"aggregate_type" db:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id" db:"aggregate_id"`
+	Exchange      string          `json:"exchange" db:"exchange"`
+	RoutingKey    string          `json:"routing_key" db:"routing_key"`
+	Payload       json.RawMessage `json:"payload" db:"payload"`
+	Attempts      int             `json:"attempts" db:"attempts"`
+	LastError     *string         `json:"last_error,omitempty" db:"last_error"`
+	AvailableAt   time.Time       `json:"available_at" db:"available_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	PublishedAt   *time.Time      `json:"published_at,omitempty" db:"published_at"`
+}
+
+// Tipos de agregado usados para ordenar los mensajes del outbox
+const (
+	OutboxAggregateArticle = "article"
+	OutboxAggregateOrder   = "order"
+)
+
+// NewOutboxMessage crea un mensaje de outbox serializando el payload a JSON
+func NewOutboxMessage(aggregateType, aggregateID, exchange, routingKey string, payload any) (*OutboxMessage, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OutboxMessage{
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Exchange:      exchange,
+		RoutingKey:    routingKey,
+		Payload:       body,
+	}, nil
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ProcessedOutcome representa el resultado registrado de un mensaje consumido
+type ProcessedOutcome string
+
+const (
+	ProcessedOutcomeProcessing ProcessedOutcome = "PROCESSING"
+	ProcessedOutcomeSucceeded  ProcessedOutcome = "SUCCEEDED"
+	ProcessedOutcomeRejected   ProcessedOutcome = "REJECTED"
+)
+
+// ProcessedMessage es una entrada del registro de mensajes procesados por un consumer
+type ProcessedMessage struct {
+	Consumer    string           `json:"consumer" db:"consumer"`
+	MessageID   string           `json:"message_id" db:"message_id"`
+	Outcome     ProcessedOutcome `json:"outcome" db:"outcome"`
+	Result      json.RawMessage  `json:"result,omitempty" db:"result"`
+	Error       *string          `json:"error,omitempty" db:"error"`
+	ProcessedAt time.Time        `json:"processed_at" db:"processed_at"`
+
+	// Duplicate indica que el mensaje ya había sido procesado y no se volvió a aplicar
+	Duplicate bool `json:"-" db:"-"`
+}
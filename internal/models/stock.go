@@ -1,22 +1,62 @@
 package models
 
 import (
+	"fmt"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
 // Stock representa el stock de un artículo
 type Stock struct {
-	ID           uuid.UUID `json:"id" db:"id"`
-	ArticleID    string    `json:"article_id" db:"article_id"`
-	Quantity     int       `json:"quantity" db:"quantity"`
-	Reserved     int       `json:"reserved" db:"reserved"`
-	MinStock     int       `json:"min_stock" db:"min_stock"`
-	MaxStock     int       `json:"max_stock" db:"max_stock"`
-	Location     string    `json:"location" db:"location"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	ID        uuid.UUID   `json:"id" db:"id"`
+	ArticleID string      `json:"article_id" db:"article_id"`
+	Quantity  int         `json:"quantity" db:"quantity"`
+	Reserved  int         `json:"reserved" db:"reserved"`
+	MinStock  int         `json:"min_stock" db:"min_stock"`
+	MaxStock  int         `json:"max_stock" db:"max_stock"`
+	Location  string      `json:"location" db:"location"`
+	Status    StockStatus `json:"status" db:"status"`
+	Version   int64       `json:"version" db:"version"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
+
+// StockStatus representa el estado del ciclo de vida de un artículo
+type StockStatus string
+
+const (
+	// StockStatusActive admite todos los movimientos
+	StockStatusActive StockStatus = "ACTIVE"
+	// StockStatusDiscontinued no admite reposiciones; se sigue vendiendo el stock existente
+	StockStatusDiscontinued StockStatus = "DISCONTINUED"
+	// StockStatusBlocked no admite reservas nuevas
+	StockStatusBlocked StockStatus = "BLOCKED"
+	// StockStatusArchived retira el artículo: no admite reposiciones ni reservas nuevas y no
+	// aparece en los listados
+	StockStatusArchived StockStatus = "ARCHIVED"
+)
+
+// IsValid verifica si el estado es uno de los conocidos
+func (s StockStatus) IsValid() bool {
+	switch s {
+	case StockStatusActive, StockStatusDiscontinued, StockStatusBlocked, StockStatusArchived:
+		return true
+	default:
+		return false
+	}
+}
+
+// AllowsReplenishment indica si el estado admite reposiciones
+func (s StockStatus) AllowsReplenishment() bool {
+	return s == StockStatusActive || s == StockStatusBlocked
+}
+
+// AllowsReservations indica si el estado admite reservas nuevas. Las reservas existentes se
+// pueden confirmar o cancelar en cualquier estado.
+func (s StockStatus) AllowsReservations() bool {
+	return s == StockStatusActive || s == StockStatusDiscontinued
 }
 
 // AvailableQuantity retorna la cantidad disponible (no reservada)
@@ -43,11 +83,73 @@ type CreateStockRequest struct {
 	Location  string `json:"location"`
 }
 
-// UpdateStockRequest representa la estructura para actualizar stock
+// MaxLocationLength es el largo máximo de la ubicación de un artículo (columna VARCHAR(255))
+const MaxLocationLength = 255
+
+// UpdateStockRequest representa la estructura para actualizar la configuración de un
+// artículo. Los campos omitidos conservan su valor; la cantidad solo cambia con movimientos.
 type UpdateStockRequest struct {
-	Quantity int `json:"quantity" validate:"min=0"`
-	MinStock int `json:"min_stock" validate:"min=0"`
-	MaxStock int `json:"max_stock" validate:"min=0"`
+	MinStock *int    `json:"min_stock,omitempty" validate:"omitempty,min=0"`
+	MaxStock *int    `json:"max_stock,omitempty" validate:"omitempty,min=0"`
+	Location *string `json:"location,omitempty"`
+}
+
+// IsEmpty indica si el request no modifica ningún campo
+func (r *UpdateStockRequest) IsEmpty() bool {
+	return r.MinStock == nil && r.MaxStock == nil && r.Location == nil
+}
+
+// StockSettingChange es el valor anterior y el nuevo de un campo de configuración
+type StockSettingChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// ApplySettings aplica los campos del request al stock y retorna los cambios efectivos,
+// por nombre de campo. Valida el resultado con las mismas reglas que los CHECK de la tabla.
+func (s *Stock) ApplySettings(req *UpdateStockRequest) (map[string]StockSettingChange, error) {
+	changes := make(map[string]StockSettingChange)
+
+	minStock, maxStock, location := s.MinStock, s.MaxStock, s.Location
+	if req.MinStock != nil {
+		minStock = *req.MinStock
+	}
+	if req.MaxStock != nil {
+		maxStock = *req.MaxStock
+	}
+	if req.Location != nil {
+		location = *req.Location
+	}
+
+	switch {
+	case minStock < 0:
+		return nil, fmt.Errorf("%w: min_stock cannot be negative", ErrInvalidStockSettings)
+	case maxStock < 0:
+		return nil, fmt.Errorf("%w: max_stock cannot be negative", ErrInvalidStockSettings)
+	case maxStock > 0 && maxStock < minStock:
+		return nil, fmt.Errorf("%w: max_stock (%d) cannot be less than min_stock (%d)", ErrInvalidStockSettings, maxStock, minStock)
+	case utf8.RuneCountInString(location) > MaxLocationLength:
+		return nil, fmt.Errorf("%w: location cannot be longer than %d characters", ErrInvalidStockSettings, MaxLocationLength)
+	}
+
+	if minStock != s.MinStock {
+		changes["min_stock"] = StockSettingChange{From: s.MinStock, To: minStock}
+	}
+	if maxStock != s.MaxStock {
+		changes["max_stock"] = StockSettingChange{From: s.MaxStock, To: maxStock}
+	}
+	if location != s.Location {
+		changes["location"] = StockSettingChange{From: s.Location, To: location}
+	}
+
+	s.MinStock, s.MaxStock, s.Location = minStock, maxStock, location
+	return changes, nil
+}
+
+// UpdateStockStatusRequest representa la estructura para cambiar el estado de un artículo
+type UpdateStockStatusRequest struct {
+	Status StockStatus `json:"status" validate:"required"`
+	Reason string      `json:"reason"`
 }
 
 // ReserveStockRequest representa la estructura para reservar stock
@@ -57,9 +159,65 @@ type ReserveStockRequest struct {
 	OrderID   string `json:"order_id" validate:"required"`
 }
 
+// OrderReservationItem representa una línea de una orden a reservar
+type OrderReservationItem struct {
+	ArticleID string `json:"article_id" validate:"required"`
+	Quantity  int    `json:"quantity" validate:"min=1"`
+}
+
+// ReservationPolicy define qué hacer con una orden cuando no hay stock para todas sus líneas
+type ReservationPolicy string
+
+const (
+	// ReservationPolicyAllOrNothing reserva todas las líneas o ninguna
+	ReservationPolicyAllOrNothing ReservationPolicy = "all_or_nothing"
+	// ReservationPolicyReserveAvailable reserva lo disponible de cada línea e informa el faltante
+	ReservationPolicyReserveAvailable ReservationPolicy = "reserve_available"
+	// ReservationPolicySkipMissingLines reserva completas las líneas con stock y omite el resto
+	ReservationPolicySkipMissingLines ReservationPolicy = "skip_missing_lines"
+)
+
+// IsValid verifica si la política es conocida
+func (p ReservationPolicy) IsValid() bool {
+	switch p {
+	case ReservationPolicyAllOrNothing, ReservationPolicyReserveAvailable, ReservationPolicySkipMissingLines:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReserveOrderRequest representa la estructura para reservar todas las líneas de una orden
+type ReserveOrderRequest struct {
+	Items  []OrderReservationItem `json:"items" validate:"required"`
+	Policy ReservationPolicy      `json:"policy,omitempty"`
+}
+
+// OrderReservationLine representa el resultado de reservar una línea de la orden
+type OrderReservationLine struct {
+	ArticleID string `json:"article_id"`
+	Requested int    `json:"requested"`
+	Reserved  int    `json:"reserved"`
+	Short     int    `json:"short"`
+}
+
+// OrderReservationResult representa el resultado de reservar una orden completa
+type OrderReservationResult struct {
+	OrderID              string                 `json:"order_id"`
+	Policy               ReservationPolicy      `json:"policy"`
+	Lines                []OrderReservationLine `json:"lines"`
+	Reservations         []*StockReservation    `json:"reservations"`
+	InsufficientArticles []string               `json:"insufficient_articles,omitempty"`
+}
+
+// IsPartial indica si alguna línea quedó con faltante
+func (r *OrderReservationResult) IsPartial() bool {
+	return len(r.InsufficientArticles) > 0
+}
+
 // StockMovementRequest representa la estructura para movimientos de stock
 type StockMovementRequest struct {
 	ArticleID string `json:"article_id" validate:"required"`
 	Quantity  int    `json:"quantity" validate:"min=1"`
 	Reason    string `json:"reason"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// MaxBatchOperations es la cantidad máxima de operaciones por lote
+const MaxBatchOperations = 500
+
+// StockBatchOperationType es el tipo de una operación del lote
+type StockBatchOperationType string
+
+const (
+	BatchOperationReplenish StockBatchOperationType = "replenish"
+	BatchOperationDeduct    StockBatchOperationType = "deduct"
+	BatchOperationReserve   StockBatchOperationType = "reserve"
+	BatchOperationCancel    StockBatchOperationType = "cancel"
+	BatchOperationConfirm   StockBatchOperationType = "confirm"
+)
+
+// IsValid verifica si el tipo de operación es uno de los conocidos
+func (t StockBatchOperationType) IsValid() bool {
+	switch t {
+	case BatchOperationReplenish, BatchOperationDeduct, BatchOperationReserve, BatchOperationCancel, BatchOperationConfirm:
+		return true
+	default:
+		return false
+	}
+}
+
+// StockBatchMode define qué hacer con el lote cuando falla una operación
+type StockBatchMode string
+
+const (
+	// BatchModeAllOrNothing aplica todas las operaciones o ninguna
+	BatchModeAllOrNothing StockBatchMode = "all_or_nothing"
+	// BatchModeBestEffort aplica las operaciones que pueden aplicarse y reporta las que fallan
+	BatchModeBestEffort StockBatchMode = "best_effort"
+)
+
+// IsValid verifica si el modo es uno de los conocidos
+func (m StockBatchMode) IsValid() bool {
+	return m == BatchModeAllOrNothing || m == BatchModeBestEffort
+}
+
+// StockBatchOperation es una operación del lote. quantity se usa en replenish, deduct y
+// reserve; order_id en reserve, cancel y confirm.
+type StockBatchOperation struct {
+	Type      StockBatchOperationType `json:"type"`
+	ArticleID string                  `json:"article_id"`
+	Quantity  int                     `json:"quantity,omitempty"`
+	OrderID   string                  `json:"order_id,omitempty"`
+	Reason    string                  `json:"reason,omitempty"`
+}
+
+// Validate verifica que la operación tenga los campos que su tipo requiere
+func (o *StockBatchOperation) Validate() error {
+	if !o.Type.IsValid() {
+		return fmt.Errorf("type must be replenish, deduct, reserve, cancel or confirm")
+	}
+	if o.ArticleID == "" {
+		return fmt.Errorf("article_id is required")
+	}
+
+	switch o.Type {
+	case BatchOperationReplenish, BatchOperationDeduct, BatchOperationReserve:
+		if o.Quantity <= 0 {
+			return fmt.Errorf("quantity must be greater than 0")
+		}
+	}
+
+	switch o.Type {
+	case BatchOperationReserve, BatchOperationCancel, BatchOperationConfirm:
+		if o.OrderID == "" {
+			return fmt.Errorf("order_id is required")
+		}
+	}
+	return nil
+}
+
+// StockBatchRequest representa la estructura para ejecutar un lote de operaciones
+type StockBatchRequest struct {
+	Mode       StockBatchMode        `json:"mode,omitempty"`
+	Operations []StockBatchOperation `json:"operations"`
+}
+
+// StockBatchOperationStatus es el resultado de una operación del lote
+type StockBatchOperationStatus string
+
+const (
+	// BatchStatusApplied indica que la operación quedó aplicada
+	BatchStatusApplied StockBatchOperationStatus = "applied"
+	// BatchStatusFailed indica que la operación falló y no se aplicó
+	BatchStatusFailed StockBatchOperationStatus = "failed"
+	// BatchStatusRolledBack indica que la operación se ejecutó pero se deshizo porque falló
+	// otra operación del lote
+	BatchStatusRolledBack StockBatchOperationStatus = "rolled_back"
+	// BatchStatusSkipped indica que la operación no se ejecutó porque falló una anterior
+	BatchStatusSkipped StockBatchOperationStatus = "skipped"
+)
+
+// StockBatchOperationResult es el resultado de una operación del lote. Stock es el estado
+// del artículo después de la operación, solo si quedó aplicada.
+type StockBatchOperationResult struct {
+	Index     int                       `json:"index"`
+	Type      StockBatchOperationType   `json:"type"`
+	ArticleID string                    `json:"article_id"`
+	OrderID   string                    `json:"order_id,omitempty"`
+	Status    StockBatchOperationStatus `json:"status"`
+	Stock     *Stock                    `json:"stock,omitempty"`
+	Err       error                     `json:"-"`
+}
+
+// StockBatchResult es el resultado de un lote, con una entrada por operación en el orden
+// recibido. BatchID es el causation_id de los eventos registrados por el lote.
+type StockBatchResult struct {
+	BatchID    uuid.UUID                   `json:"batch_id"`
+	Mode       StockBatchMode              `json:"mode"`
+	Applied    int                         `json:"applied"`
+	Failed     int                         `json:"failed"`
+	Operations []StockBatchOperationResult `json:"operations"`
+}
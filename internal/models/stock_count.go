@@ -0,0 +1,112 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockCountStatus representa el estado de una sesión de recuento
+type StockCountStatus string
+
+const (
+	StockCountStatusOpen      StockCountStatus = "OPEN"
+	StockCountStatusPosted    StockCountStatus = "POSTED"
+	StockCountStatusCancelled StockCountStatus = "CANCELLED"
+)
+
+// StockCountLineStatus representa el resultado de publicar una línea del recuento
+type StockCountLineStatus string
+
+const (
+	// StockCountLinePending es una línea de una sesión aún no publicada
+	StockCountLinePending StockCountLineStatus = "PENDING"
+	// StockCountLineAdjusted es una línea con diferencia, ajustada con un evento ADJUST
+	StockCountLineAdjusted StockCountLineStatus = "ADJUSTED"
+	// StockCountLineUnchanged es una línea sin diferencia con el stock registrado
+	StockCountLineUnchanged StockCountLineStatus = "UNCHANGED"
+	// StockCountLineSkipped es una línea que no se ajustó porque la cantidad contada no cubre
+	// el stock reservado del artículo
+	StockCountLineSkipped StockCountLineStatus = "SKIPPED"
+)
+
+// StockCountConflictPolicy define qué hacer al publicar un recuento cuando la cantidad contada
+// de un artículo es menor que su stock reservado
+type StockCountConflictPolicy string
+
+const (
+	// StockCountConflictReject rechaza la publicación completa
+	StockCountConflictReject StockCountConflictPolicy = "reject"
+	// StockCountConflictSkip publica el resto de las líneas y deja esas sin ajustar
+	StockCountConflictSkip StockCountConflictPolicy = "skip"
+)
+
+// IsValid verifica si la política es conocida
+func (p StockCountConflictPolicy) IsValid() bool {
+	return p == StockCountConflictReject || p == StockCountConflictSkip
+}
+
+// StockCountSession es un recuento físico de los artículos de una ubicación
+type StockCountSession struct {
+	ID        uuid.UUID        `json:"id" db:"id"`
+	Location  string           `json:"location" db:"location"`
+	Status    StockCountStatus `json:"status" db:"status"`
+	Notes     string           `json:"notes,omitempty" db:"notes"`
+	OpenedBy  string           `json:"opened_by,omitempty" db:"opened_by"`
+	ClosedBy  string           `json:"closed_by,omitempty" db:"closed_by"`
+	CreatedAt time.Time        `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at" db:"updated_at"`
+	ClosedAt  *time.Time       `json:"closed_at,omitempty" db:"closed_at"`
+}
+
+// StockCountLine es la cantidad contada de un artículo. Variance es la diferencia contada -
+// esperada y al publicar se aplica sobre el stock de ese momento.
+type StockCountLine struct {
+	ID                  uuid.UUID            `json:"id" db:"id"`
+	SessionID           uuid.UUID            `json:"session_id" db:"session_id"`
+	ArticleID           string               `json:"article_id" db:"article_id"`
+	CountedQuantity     int                  `json:"counted_quantity" db:"counted_quantity"`
+	Reason              string               `json:"reason,omitempty" db:"reason"`
+	Status              StockCountLineStatus `json:"status" db:"status"`
+	ExpectedQuantity    *int                 `json:"expected_quantity" db:"expected_quantity"`
+	Variance            *int                 `json:"variance" db:"variance"`
+	CurrentQuantity     *int                 `json:"current_quantity,omitempty" db:"-"`
+	Reserved            *int                 `json:"reserved,omitempty" db:"-"`
+	ReservationConflict bool                 `json:"reservation_conflict,omitempty" db:"-"`
+	AdjustEventID       *uuid.UUID           `json:"adjust_event_id,omitempty" db:"adjust_event_id"`
+	CountedBy           string               `json:"counted_by,omitempty" db:"counted_by"`
+	CreatedAt           time.Time            `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time            `json:"updated_at" db:"updated_at"`
+}
+
+// StockCountReport es una sesión de recuento con sus líneas. Uncounted son los artículos de la
+// ubicación sin cantidad contada; solo se informa en sesiones abiertas.
+type StockCountReport struct {
+	Session   *StockCountSession `json:"session"`
+	Lines     []*StockCountLine  `json:"lines"`
+	Uncounted []string           `json:"uncounted,omitempty"`
+}
+
+// OpenStockCountRequest representa la estructura para abrir un recuento
+type OpenStockCountRequest struct {
+	Location string `json:"location" validate:"required"`
+	Notes    string `json:"notes"`
+}
+
+// StockCountItem es la cantidad contada de un artículo
+type StockCountItem struct {
+	ArticleID       string `json:"article_id" validate:"required"`
+	CountedQuantity *int   `json:"counted_quantity" validate:"required,min=0"`
+	Reason          string `json:"reason"`
+}
+
+// SubmitStockCountRequest representa la estructura para cargar cantidades contadas. Volver a
+// cargar un artículo reemplaza su cantidad anterior.
+type SubmitStockCountRequest struct {
+	Items []StockCountItem `json:"items" validate:"required"`
+}
+
+// PostStockCountRequest representa la estructura para publicar un recuento
+type PostStockCountRequest struct {
+	OnReservationConflict StockCountConflictPolicy `json:"on_reservation_conflict,omitempty"`
+}
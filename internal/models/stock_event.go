@@ -10,14 +10,34 @@ import (
 type StockEventType string
 
 const (
-	EventTypeAdd         StockEventType = "ADD"
-	EventTypeReplenish   StockEventType = "REPLENISH"
-	EventTypeDeduct      StockEventType = "DEDUCT"
-	EventTypeReserve     StockEventType = "RESERVE"
-	EventTypeCancelReserve StockEventType = "CANCEL_RESERVE"
-	EventTypeLowStock    StockEventType = "LOW_STOCK"
+	EventTypeAdd            StockEventType = "ADD"
+	EventTypeReplenish      StockEventType = "REPLENISH"
+	EventTypeDeduct         StockEventType = "DEDUCT"
+	EventTypeReserve        StockEventType = "RESERVE"
+	EventTypeCancelReserve  StockEventType = "CANCEL_RESERVE"
+	EventTypeConfirmReserve StockEventType = "CONFIRM_RESERVE"
+	EventTypeExpireReserve  StockEventType = "EXPIRE_RESERVE"
+	EventTypeAdjust         StockEventType = "ADJUST"
+	EventTypeTransferOut    StockEventType = "TRANSFER_OUT"
+	EventTypeTransferIn     StockEventType = "TRANSFER_IN"
+	EventTypeLowStock       StockEventType = "LOW_STOCK"
+	EventTypeSettingsChange StockEventType = "SETTINGS_CHANGE"
+	EventTypeStatusChange   StockEventType = "STATUS_CHANGE"
 )
 
+// IsValid verifica si el tipo de evento es uno de los conocidos
+func (t StockEventType) IsValid() bool {
+	switch t {
+	case EventTypeAdd, EventTypeReplenish, EventTypeDeduct, EventTypeReserve,
+		EventTypeCancelReserve, EventTypeConfirmReserve, EventTypeExpireReserve,
+		EventTypeAdjust, EventTypeTransferOut, EventTypeTransferIn, EventTypeLowStock,
+		EventTypeSettingsChange, EventTypeStatusChange:
+		return true
+	default:
+		return false
+	}
+}
+
 // StockEvent representa un evento del historial de stock
 type StockEvent struct {
 	ID        uuid.UUID      `json:"id" db:"id"`
@@ -27,7 +47,48 @@ type StockEvent struct {
 	OrderID   *string        `json:"order_id,omitempty" db:"order_id"`
 	Reason    string         `json:"reason" db:"reason"`
 	Metadata  string         `json:"metadata,omitempty" db:"metadata"` // JSON para datos adicionales
-	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	// Saldos del artículo antes y después del evento; nulos en eventos anteriores a su registro
+	QuantityBefore *int `json:"quantity_before" db:"quantity_before"`
+	QuantityAfter  *int `json:"quantity_after" db:"quantity_after"`
+	ReservedBefore *int `json:"reserved_before" db:"reserved_before"`
+	ReservedAfter  *int `json:"reserved_after" db:"reserved_after"`
+	// Origen del movimiento: actor, request HTTP o mensaje de RabbitMQ
+	Attribution
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// Deltas retorna cómo modifica el evento la cantidad y el stock reservado. ADJUST y LOW_STOCK no
+// tienen un sentido fijo.
+func (t StockEventType) Deltas(quantity int) (quantityDelta, reservedDelta int) {
+	switch t {
+	case EventTypeAdd, EventTypeReplenish, EventTypeTransferIn:
+		return quantity, 0
+	case EventTypeDeduct, EventTypeTransferOut:
+		return -quantity, 0
+	case EventTypeReserve:
+		return 0, quantity
+	case EventTypeCancelReserve, EventTypeExpireReserve:
+		return 0, -quantity
+	case EventTypeConfirmReserve:
+		return -quantity, -quantity
+	default:
+		return 0, 0
+	}
+}
+
+// SetBalances completa los saldos del evento a partir del stock resultante del movimiento
+func (e *StockEvent) SetBalances(after *Stock) {
+	quantityDelta, reservedDelta := e.EventType.Deltas(e.Quantity)
+
+	quantityBefore := after.Quantity - quantityDelta
+	quantityAfter := after.Quantity
+	reservedBefore := after.Reserved - reservedDelta
+	reservedAfter := after.Reserved
+
+	e.QuantityBefore = &quantityBefore
+	e.QuantityAfter = &quantityAfter
+	e.ReservedBefore = &reservedBefore
+	e.ReservedAfter = &reservedAfter
 }
 
 // CreateStockEventRequest representa la estructura para crear un evento
@@ -42,14 +103,14 @@ type CreateStockEventRequest struct {
 
 // StockReservation representa una reserva de stock
 type StockReservation struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	ArticleID string    `json:"article_id" db:"article_id"`
-	OrderID   string    `json:"order_id" db:"order_id"`
-	Quantity  int       `json:"quantity" db:"quantity"`
-	Status    string    `json:"status" db:"status"` // ACTIVE, CONFIRMED, CANCELLED
-	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        uuid.UUID         `json:"id" db:"id"`
+	ArticleID string            `json:"article_id" db:"article_id"`
+	OrderID   string            `json:"order_id" db:"order_id"`
+	Quantity  int               `json:"quantity" db:"quantity"`
+	Status    ReservationStatus `json:"status" db:"status"`
+	ExpiresAt *time.Time        `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" db:"updated_at"`
 }
 
 // ReservationStatus representa los estados de una reserva
@@ -60,4 +121,19 @@ const (
 	ReservationStatusConfirmed ReservationStatus = "CONFIRMED"
 	ReservationStatusCancelled ReservationStatus = "CANCELLED"
 	ReservationStatusExpired   ReservationStatus = "EXPIRED"
-)
\ No newline at end of file
+)
+
+// CanTransitionTo verifica si una reserva puede pasar del estado actual al indicado.
+// Solo las reservas activas pueden cambiar de estado; el resto son estados finales.
+func (s ReservationStatus) CanTransitionTo(next ReservationStatus) bool {
+	if s != ReservationStatusActive {
+		return false
+	}
+
+	switch next {
+	case ReservationStatusConfirmed, ReservationStatusCancelled, ReservationStatusExpired:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// StockEventPartitionStatus representa el estado de una partición mensual de stock_events
+type StockEventPartitionStatus string
+
+const (
+	PartitionStatusAttached StockEventPartitionStatus = "ATTACHED"
+	// PartitionStatusDetached indica que la partición ya se exportó y se separó de
+	// stock_events, pero su tabla se conserva hasta verificar el archivo
+	PartitionStatusDetached StockEventPartitionStatus = "DETACHED"
+	PartitionStatusArchived StockEventPartitionStatus = "ARCHIVED"
+)
+
+// StockEventPartition es una partición mensual de stock_events
+type StockEventPartition struct {
+	Name          string                    `json:"name" db:"name"`
+	RangeStart    time.Time                 `json:"range_start" db:"range_start"`
+	RangeEnd      time.Time                 `json:"range_end" db:"range_end"`
+	Status        StockEventPartitionStatus `json:"status" db:"status"`
+	ArchivePath   *string                   `json:"archive_path,omitempty" db:"archive_path"`
+	ArchivedRows  *int                      `json:"archived_rows,omitempty" db:"archived_rows"`
+	ArchiveSHA256 *string                   `json:"archive_sha256,omitempty" db:"archive_sha256"`
+	ArchivedAt    *time.Time                `json:"archived_at,omitempty" db:"archived_at"`
+	CreatedAt     time.Time                 `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Límites de página para las consultas de eventos
+const (
+	DefaultEventPageSize = 50
+	MaxEventPageSize     = 500
+)
+
+// EventCursor es la posición de un evento en el orden (created_at DESC, id DESC). La página
+// siguiente contiene los eventos estrictamente posteriores a esa posición en ese orden.
+type EventCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode serializa el cursor como un token opaco para la API
+func (c EventCursor) Encode() string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeEventCursor interpreta un token generado por EventCursor.Encode
+func DecodeEventCursor(token string) (*EventCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &EventCursor{}
+	if cursor.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if cursor.ID, err = uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	return cursor, nil
+}
+
+// StockEventFilter son los criterios de búsqueda de eventos; los campos vacíos no filtran.
+// From es inclusivo y To exclusivo.
+type StockEventFilter struct {
+	ArticleID  string
+	OrderID    string
+	EventTypes []StockEventType
+	ActorType  ActorType
+	ActorID    string
+	From       *time.Time
+	To         *time.Time
+	After      *EventCursor
+	Limit      int
+}
+
+// StockEventPage es una página de eventos, del más reciente al más antiguo. NextCursor es
+// nil cuando no hay más eventos.
+type StockEventPage struct {
+	Events     []*StockEvent `json:"data"`
+	Count      int           `json:"count"`
+	NextCursor *string       `json:"next_cursor"`
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Límites de la importación masiva de artículos
+const (
+	// MaxImportRows es la cantidad máxima de filas por archivo importado
+	MaxImportRows = 50000
+	// MaxArticleIDLength es el largo máximo del article_id (columna VARCHAR(100))
+	MaxArticleIDLength = 100
+)
+
+// StockFileFormat es el formato de archivo de la importación y la exportación de artículos
+type StockFileFormat string
+
+const (
+	StockFileFormatCSV    StockFileFormat = "csv"
+	StockFileFormatNDJSON StockFileFormat = "ndjson"
+)
+
+// IsValid verifica si el formato es uno de los conocidos
+func (f StockFileFormat) IsValid() bool {
+	return f == StockFileFormatCSV || f == StockFileFormatNDJSON
+}
+
+// StockImportMode define qué hacer con las filas de artículos que ya existen
+type StockImportMode string
+
+const (
+	// StockImportModeCreate solo crea artículos: una fila de un artículo existente es un error
+	StockImportModeCreate StockImportMode = "create"
+	// StockImportModeUpsert crea los artículos nuevos y actualiza los existentes
+	StockImportModeUpsert StockImportMode = "upsert"
+)
+
+// IsValid verifica si el modo es uno de los conocidos
+func (m StockImportMode) IsValid() bool {
+	return m == StockImportModeCreate || m == StockImportModeUpsert
+}
+
+// StockImportRow es una fila del archivo importado. Los campos omitidos conservan su valor en
+// los artículos existentes y toman el valor por defecto en los nuevos.
+type StockImportRow struct {
+	Line      int     `json:"-"`
+	ArticleID string  `json:"article_id"`
+	Quantity  *int    `json:"quantity,omitempty"`
+	MinStock  *int    `json:"min_stock,omitempty"`
+	MaxStock  *int    `json:"max_stock,omitempty"`
+	Location  *string `json:"location,omitempty"`
+}
+
+// Validate verifica la fila por sí sola, sin mirar el estado actual del artículo
+func (r *StockImportRow) Validate() error {
+	switch {
+	case r.ArticleID == "":
+		return fmt.Errorf("article_id is required")
+	case utf8.RuneCountInString(r.ArticleID) > MaxArticleIDLength:
+		return fmt.Errorf("article_id cannot be longer than %d characters", MaxArticleIDLength)
+	case r.Quantity != nil && *r.Quantity < 0:
+		return fmt.Errorf("quantity cannot be negative")
+	case r.MinStock != nil && *r.MinStock < 0:
+		return fmt.Errorf("min_stock cannot be negative")
+	case r.MaxStock != nil && *r.MaxStock < 0:
+		return fmt.Errorf("max_stock cannot be negative")
+	case r.Location != nil && utf8.RuneCountInString(*r.Location) > MaxLocationLength:
+		return fmt.Errorf("location cannot be longer than %d characters", MaxLocationLength)
+	}
+	return nil
+}
+
+// SettingsRequest retorna los campos de configuración de la fila como request de actualización
+func (r *StockImportRow) SettingsRequest() *UpdateStockRequest {
+	return &UpdateStockRequest{
+		MinStock: r.MinStock,
+		MaxStock: r.MaxStock,
+		Location: r.Location,
+	}
+}
+
+// StockImportRowError es el error de validación de una fila del archivo
+type StockImportRowError struct {
+	Line      int    `json:"line"`
+	ArticleID string `json:"article_id,omitempty"`
+	Error     string `json:"error"`
+}
+
+// StockImportResult es el resultado de una importación. Si hay errores no se aplica ninguna
+// fila; en modo dry-run los contadores informan lo que se hubiera aplicado.
+type StockImportResult struct {
+	Mode      StockImportMode       `json:"mode"`
+	DryRun    bool                  `json:"dry_run"`
+	Rows      int                   `json:"rows"`
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Unchanged int                   `json:"unchanged"`
+	Errors    []StockImportRowError `json:"errors"`
+}
+
+// HasErrors indica si alguna fila no pasó la validación
+func (r *StockImportResult) HasErrors() bool {
+	return len(r.Errors) > 0
+}
+
+// AddError agrega el error de una fila al resultado
+func (r *StockImportResult) AddError(row *StockImportRow, err error) {
+	r.Errors = append(r.Errors, StockImportRowError{Line: row.Line, ArticleID: row.ArticleID, Error: err.Error()})
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Límites de página para el listado de artículos
+const (
+	DefaultStockPageSize = 50
+	MaxStockPageSize     = 500
+)
+
+// StockSortField es la columna por la que se ordena el listado de artículos
+type StockSortField string
+
+const (
+	StockSortCreatedAt StockSortField = "created_at"
+	StockSortUpdatedAt StockSortField = "updated_at"
+	StockSortQuantity  StockSortField = "quantity"
+	StockSortReserved  StockSortField = "reserved"
+	StockSortAvailable StockSortField = "available"
+	StockSortMinStock  StockSortField = "min_stock"
+	StockSortMaxStock  StockSortField = "max_stock"
+)
+
+// IsValid verifica si la columna de orden es una de las conocidas
+func (f StockSortField) IsValid() bool {
+	switch f {
+	case StockSortCreatedAt, StockSortUpdatedAt, StockSortQuantity, StockSortReserved,
+		StockSortAvailable, StockSortMinStock, StockSortMaxStock:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsTime indica si la columna de orden es una fecha; el resto son numéricas
+func (f StockSortField) IsTime() bool {
+	return f == StockSortCreatedAt || f == StockSortUpdatedAt
+}
+
+// SortValue retorna el valor del stock en la columna de orden
+func (s *Stock) SortValue(field StockSortField) any {
+	switch field {
+	case StockSortUpdatedAt:
+		return s.UpdatedAt
+	case StockSortQuantity:
+		return s.Quantity
+	case StockSortReserved:
+		return s.Reserved
+	case StockSortAvailable:
+		return s.AvailableQuantity()
+	case StockSortMinStock:
+		return s.MinStock
+	case StockSortMaxStock:
+		return s.MaxStock
+	default:
+		return s.CreatedAt
+	}
+}
+
+// StockCursor es la posición de un artículo en el orden (columna, article_id) del listado
+type StockCursor struct {
+	Sort      StockSortField
+	Desc      bool
+	Value     any // int para las columnas numéricas, time.Time para las fechas
+	ArticleID string
+}
+
+// NewStockCursor retorna el cursor que sigue al stock en el orden indicado
+func NewStockCursor(stock *Stock, sort StockSortField, desc bool) StockCursor {
+	return StockCursor{Sort: sort, Desc: desc, Value: stock.SortValue(sort), ArticleID: stock.ArticleID}
+}
+
+// Encode serializa el cursor como un token opaco para la API
+func (c StockCursor) Encode() string {
+	order := "asc"
+	if c.Desc {
+		order = "desc"
+	}
+
+	var value string
+	switch v := c.Value.(type) {
+	case time.Time:
+		value = v.UTC().Format(time.RFC3339Nano)
+	case int:
+		value = strconv.Itoa(v)
+	}
+
+	raw := string(c.Sort) + "|" + order + "|" + value + "|" + c.ArticleID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeStockCursor interpreta un token generado por StockCursor.Encode
+func DecodeStockCursor(token string) (*StockCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	parts := strings.SplitN(string(raw), "|", 4)
+	if len(parts) != 4 || parts[3] == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	cursor := &StockCursor{Sort: StockSortField(parts[0]), ArticleID: parts[3]}
+	if !cursor.Sort.IsValid() {
+		return nil, fmt.Errorf("%w: unknown sort field %q", ErrInvalidCursor, parts[0])
+	}
+
+	switch parts[1] {
+	case "asc":
+	case "desc":
+		cursor.Desc = true
+	default:
+		return nil, fmt.Errorf("%w: unknown order %q", ErrInvalidCursor, parts[1])
+	}
+
+	if cursor.Sort.IsTime() {
+		if cursor.Value, err = time.Parse(time.RFC3339Nano, parts[2]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+	} else if cursor.Value, err = strconv.Atoi(parts[2]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	return cursor, nil
+}
+
+// StockFilter son los criterios del listado de artículos; los campos vacíos no filtran
+type StockFilter struct {
+	Location     string
+	LowStock     bool
+	MinAvailable *int
+	MaxAvailable *int
+	Statuses     []StockStatus
+	UpdatedSince *time.Time
+	Sort         StockSortField
+	Desc         bool
+	After        *StockCursor
+	Limit        int
+}
+
+// StockPage es una página del listado de artículos. Total es la cantidad de artículos que
+// cumplen el filtro en todas las páginas; NextCursor es nil cuando no hay más artículos.
+type StockPage struct {
+	Stocks     []*Stock `json:"data"`
+	Count      int      `json:"count"`
+	Total      int      `json:"total"`
+	NextCursor *string  `json:"next_cursor"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReconciliationRun es una corrida de conciliación entre la tabla stocks y el historial de
+// eventos. FinishedAt es nil mientras la corrida está en curso o si se interrumpió.
+type ReconciliationRun struct {
+	ID              uuid.UUID  `json:"id" db:"id"`
+	StartedAt       time.Time  `json:"started_at" db:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at" db:"finished_at"`
+	Repair          bool       `json:"repair" db:"repair"`
+	ArticlesChecked int        `json:"articles_checked" db:"articles_checked"`
+	Discrepancies   int        `json:"discrepancies" db:"discrepancies"`
+	Repaired        int        `json:"repaired" db:"repaired"`
+	TriggeredBy     string     `json:"triggered_by,omitempty" db:"triggered_by"`
+}
+
+// StockDiscrepancy es un artículo cuyo stock no coincide con el que resulta de sus eventos.
+// El drift es stock - ledger.
+type StockDiscrepancy struct {
+	ID             uuid.UUID  `json:"id" db:"id"`
+	RunID          uuid.UUID  `json:"run_id" db:"run_id"`
+	ArticleID      string     `json:"article_id" db:"article_id"`
+	StockQuantity  *int       `json:"stock_quantity" db:"stock_quantity"`
+	StockReserved  *int       `json:"stock_reserved" db:"stock_reserved"`
+	LedgerQuantity int        `json:"ledger_quantity" db:"ledger_quantity"`
+	LedgerReserved int        `json:"ledger_reserved" db:"ledger_reserved"`
+	QuantityDrift  int        `json:"quantity_drift" db:"quantity_drift"`
+	ReservedDrift  int        `json:"reserved_drift" db:"reserved_drift"`
+	Repaired       bool       `json:"repaired" db:"repaired"`
+	RepairEventID  *uuid.UUID `json:"repair_event_id,omitempty" db:"repair_event_id"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+}
+
+// HasDrift indica si el stock difiere del historial
+func (d *StockDiscrepancy) HasDrift() bool {
+	return d.StockQuantity == nil || d.QuantityDrift != 0 || d.ReservedDrift != 0
+}
+
+// ReconciliationReport es una corrida de conciliación con las discrepancias encontradas
+type ReconciliationReport struct {
+	Run           *ReconciliationRun  `json:"run"`
+	Discrepancies []*StockDiscrepancy `json:"discrepancies"`
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StockSnapshot es el estado de un artículo al cierre de TakenAt: incluye todos los eventos
+// con created_at <= TakenAt. Acota la reconstrucción histórica a los eventos posteriores.
+type StockSnapshot struct {
+	ArticleID string    `json:"article_id" db:"article_id"`
+	TakenAt   time.Time `json:"taken_at" db:"taken_at"`
+	Quantity  int       `json:"quantity" db:"quantity"`
+	Reserved  int       `json:"reserved" db:"reserved"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// StockAsOf es el stock de un artículo reconstruido a partir del historial en un momento dado
+type StockAsOf struct {
+	ArticleID      string     `json:"article_id"`
+	AsOf           time.Time  `json:"as_of"`
+	Quantity       int        `json:"quantity"`
+	Reserved       int        `json:"reserved"`
+	Available      int        `json:"available"`
+	SnapshotAt     *time.Time `json:"snapshot_at,omitempty"`
+	LastEventID    *uuid.UUID `json:"last_event_id,omitempty"`
+	LastEventAt    *time.Time `json:"last_event_at,omitempty"`
+	EventsReplayed int        `json:"events_replayed"`
+}
+
+// ApplySnapshot toma el estado de la snapshot como punto de partida
+func (s *StockAsOf) ApplySnapshot(snapshot *StockSnapshot) {
+	takenAt := snapshot.TakenAt
+	s.Quantity = snapshot.Quantity
+	s.Reserved = snapshot.Reserved
+	s.SnapshotAt = &takenAt
+	s.Available = s.Quantity - s.Reserved
+}
+
+// ApplyEvent avanza el estado con un evento: si el evento registró sus saldos se toman
+// directamente; si es anterior a ese registro, se aplica su variación según el tipo
+func (s *StockAsOf) ApplyEvent(event *StockEvent) {
+	if event.QuantityAfter != nil && event.ReservedAfter != nil {
+		s.Quantity = *event.QuantityAfter
+		s.Reserved = *event.ReservedAfter
+	} else {
+		quantityDelta, reservedDelta := event.EventType.Deltas(event.Quantity)
+		s.Quantity += quantityDelta
+		s.Reserved += reservedDelta
+	}
+
+	id := event.ID
+	createdAt := event.CreatedAt
+	s.LastEventID = &id
+	s.LastEventAt = &createdAt
+	s.EventsReplayed++
+	s.Available = s.Quantity - s.Reserved
+}
+
+// HasHistory indica si se encontró alguna snapshot o evento hasta el momento consultado
+func (s *StockAsOf) HasHistory() bool {
+	return s.SnapshotAt != nil || s.LastEventID != nil
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AnyVersion representa If-Match: * (el artículo debe existir, en cualquier versión). Las
+// versiones reales empiezan en 1.
+const AnyVersion int64 = 0
+
+// StockETag retorna el ETag de una versión del artículo
+func StockETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseIfMatch interpreta el header If-Match como una lista de versiones del artículo
+func ParseIfMatch(header string) ([]int64, error) {
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return []int64{AnyVersion}, nil
+		}
+
+		tag = strings.TrimPrefix(tag, "W/")
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			return nil, fmt.Errorf("invalid ETag %q", tag)
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("invalid ETag %q", tag)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+type expectedVersionsContextKey struct{}
+
+// ContextWithExpectedVersions retorna un contexto que transporta las versiones aceptadas por
+// el header If-Match. StockService las verifica contra el artículo antes de modificarlo.
+func ContextWithExpectedVersions(ctx context.Context, versions []int64) context.Context {
+	return context.WithValue(ctx, expectedVersionsContextKey{}, versions)
+}
+
+// ExpectedVersionsFromContext retorna las versiones aceptadas; ok es false si la request no
+// trae una condición
+func ExpectedVersionsFromContext(ctx context.Context) ([]int64, bool) {
+	versions, ok := ctx.Value(expectedVersionsContextKey{}).([]int64)
+	return versions, ok && len(versions) > 0
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestStockETag(t *testing.T) {
+	tests := []struct {
+		version int64
+		want    string
+	}{
+		{1, `"1"`},
+		{42, `"42"`},
+		{9007199254740993, `"9007199254740993"`},
+	}
+
+	for _, tt := range tests {
+		if got := StockETag(tt.version); got != tt.want {
+			t.Errorf("StockETag(%d): expected %s, got %s", tt.version, tt.want, got)
+		}
+
+		versions, err := ParseIfMatch(StockETag(tt.version))
+		if err != nil || !reflect.DeepEqual(versions, []int64{tt.version}) {
+			t.Errorf("ParseIfMatch(StockETag(%d)): got %v, %v", tt.version, versions, err)
+		}
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    []int64
+		wantErr bool
+	}{
+		{name: "strong ETag", header: `"3"`, want: []int64{3}},
+		{name: "weak ETag", header: `W/"3"`, want: []int64{3}},
+		{name: "list", header: `"3", W/"5" ,"8"`, want: []int64{3, 5, 8}},
+		{name: "any version", header: "*", want: []int64{AnyVersion}},
+		{name: "any version with spaces", header: "  * ", want: []int64{AnyVersion}},
+		{name: "any version in a list", header: `"3", *`, want: []int64{AnyVersion}},
+		{name: "empty", header: "", wantErr: true},
+		{name: "unquoted", header: "3", wantErr: true},
+		{name: "missing closing quote", header: `"3`, wantErr: true},
+		{name: "lone quote", header: `"`, wantErr: true},
+		{name: "empty tag", header: `""`, wantErr: true},
+		{name: "not a number", header: `"abc"`, wantErr: true},
+		{name: "zero", header: `"0"`, wantErr: true},
+		{name: "negative", header: `"-1"`, wantErr: true},
+		{name: "lowercase weak prefix", header: `w/"3"`, wantErr: true},
+		{name: "empty list element", header: `"3",`, wantErr: true},
+		{name: "one invalid in a list", header: `"3", "x"`, wantErr: true},
+		{name: "overflow", header: `"9223372036854775808"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIfMatch(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExpectedVersionsFromContext(t *testing.T) {
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   []int64
+		wantOK bool
+	}{
+		{name: "no condition", ctx: context.Background()},
+		{name: "empty versions", ctx: ContextWithExpectedVersions(context.Background(), nil)},
+		{name: "versions", ctx: ContextWithExpectedVersions(context.Background(), []int64{2, 3}), want: []int64{2, 3}, wantOK: true},
+		{name: "any version", ctx: ContextWithExpectedVersions(context.Background(), []int64{AnyVersion}), want: []int64{AnyVersion}, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ExpectedVersionsFromContext(tt.ctx)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok %v, got %v", tt.wantOK, ok)
+			}
+			if ok && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// DBTX abstrae pgxpool.Pool y pgx.Tx para que los repositorios puedan
+// ejecutarse tanto sobre el pool como dentro de una transacción
+type DBTX interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+type txContextKey struct{}
+
+// ContextWithTx retorna un contexto que transporta la transacción, para que TxManager.WithTx la
+// reutilice mediante un savepoint
+func ContextWithTx(ctx context.Context, tx pgx.Tx) context.Context {
+	return context.WithValue(ctx, txContextKey{}, tx)
+}
+
+// TxFromContext retorna la transacción que transporta el contexto, si la hay
+func TxFromContext(ctx context.Context) (pgx.Tx, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(pgx.Tx)
+	return tx, ok
+}
+
+// managedTx es una transacción abierta por TxManager con sus funciones AfterCommit pendientes
+type managedTx struct {
+	pgx.Tx
+	parent      *managedTx
+	afterCommit []func()
+}
+
+// Begin abre un savepoint que también difiere sus funciones AfterCommit
+func (t *managedTx) Begin(ctx context.Context) (pgx.Tx, error) {
+	savepoint, err := t.Tx.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &managedTx{Tx: savepoint, parent: t}, nil
+}
+
+// Commit confirma la transacción (o libera el savepoint) y ejecuta o traspasa las funciones
+// registradas con AfterCommit
+func (t *managedTx) Commit(ctx context.Context) error {
+	if err := t.Tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	hooks := t.afterCommit
+	t.afterCommit = nil
+	if t.parent != nil {
+		t.parent.afterCommit = append(t.parent.afterCommit, hooks...)
+		return nil
+	}
+	for _, fn := range hooks {
+		fn()
+	}
+	return nil
+}
+
+// AfterCommit ejecuta fn después del commit de la transacción de db, o en el momento si db no es
+// una transacción de TxManager
+func AfterCommit(db DBTX, fn func()) {
+	if tx, ok := db.(*managedTx); ok {
+		tx.afterCommit = append(tx.afterCommit, fn)
+		return
+	}
+	fn()
+}
+
+// TxManager ejecuta operaciones dentro de una transacción de PostgreSQL
+type TxManager struct {
+	db *pgxpool.Pool
+}
+
+func NewTxManager(db *pgxpool.Pool) *TxManager {
+	return &TxManager{
+		db: db,
+	}
+}
+
+// WithTx ejecuta fn dentro de una transacción, o en un savepoint si el contexto ya transporta
+// una; hace commit si fn no retorna error y rollback en caso contrario
+func (m *TxManager) WithTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	var tx pgx.Tx
+	var err error
+	if outer, ok := TxFromContext(ctx); ok {
+		tx, err = outer.Begin(ctx)
+	} else {
+		var raw pgx.Tx
+		raw, err = m.db.Begin(ctx)
+		if err == nil {
+			tx = &managedTx{Tx: raw}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+
+	return nil
+}
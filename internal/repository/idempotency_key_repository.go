@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type IdempotencyKeyRepository struct {
+	db DBTX
+}
+
+func NewIdempotencyKeyRepository(db *pgxpool.Pool) *IdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *IdempotencyKeyRepository) WithTx(tx pgx.Tx) *IdempotencyKeyRepository {
+	return &IdempotencyKeyRepository{
+		db: tx,
+	}
+}
+
+// Claim registra la clave como en proceso; retorna false si ya existía una entrada vigente
+func (r *IdempotencyKeyRepository) Claim(ctx context.Context, key *models.IdempotencyKey) (bool, error) {
+	query := `
+		INSERT INTO idempotency_keys (scope, idempotency_key, request_hash, method, path, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (scope, idempotency_key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    method = EXCLUDED.method,
+		    path = EXCLUDED.path,
+		    status_code = NULL,
+		    content_type = NULL,
+		    response_body = NULL,
+		    created_at = EXCLUDED.created_at,
+		    expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at
+	`
+
+	result, err := r.db.Exec(ctx, query,
+		key.Scope, key.Key, key.RequestHash, key.Method, key.Path, key.CreatedAt, key.ExpiresAt)
+	if err != nil {
+		return false, fmt.Errorf("error claiming idempotency key: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// GetIdempotencyKey obtiene la entrada registrada para una clave, o nil si no existe
+func (r *IdempotencyKeyRepository) GetIdempotencyKey(ctx context.Context, scope, key string) (*models.IdempotencyKey, error) {
+	query := `
+		SELECT scope, idempotency_key, request_hash, method, path,
+		       COALESCE(status_code, 0), COALESCE(content_type, ''), response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE scope = $1 AND idempotency_key = $2
+	`
+
+	var entry models.IdempotencyKey
+	err := r.db.QueryRow(ctx, query, scope, key).Scan(
+		&entry.Scope, &entry.Key, &entry.RequestHash, &entry.Method, &entry.Path,
+		&entry.StatusCode, &entry.ContentType, &entry.ResponseBody, &entry.CreatedAt, &entry.ExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting idempotency key: %w", err)
+	}
+
+	return &entry, nil
+}
+
+// Complete guarda la respuesta de una clave previamente reclamada
+func (r *IdempotencyKeyRepository) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $1, content_type = $2, response_body = $3
+		WHERE scope = $4 AND idempotency_key = $5
+	`
+
+	_, err := r.db.Exec(ctx, query, key.StatusCode, key.ContentType, key.ResponseBody, key.Scope, key.Key)
+	if err != nil {
+		return fmt.Errorf("error completing idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// ReleaseClaim elimina una clave reclamada que no llegó a registrar su respuesta
+func (r *IdempotencyKeyRepository) ReleaseClaim(ctx context.Context, scope, key string) error {
+	_, err := r.db.Exec(ctx,
+		"DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2 AND status_code IS NULL",
+		scope, key)
+	if err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteExpired elimina las claves vencidas y retorna cuántas se eliminaron
+func (r *IdempotencyKeyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired idempotency keys: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
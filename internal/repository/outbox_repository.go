@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type OutboxRepository struct {
+	db DBTX
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *OutboxRepository) WithTx(tx pgx.Tx) *OutboxRepository {
+	return &OutboxRepository{
+		db: tx,
+	}
+}
+
+// Enqueue guarda un mensaje pendiente de publicación
+func (r *OutboxRepository) Enqueue(ctx context.Context, message *models.OutboxMessage) error {
+	query := `
+		INSERT INTO stock_outbox (id, aggregate_type, aggregate_id, exchange, routing_key, payload, available_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING sequence
+	`
+
+	message.ID = uuid.New()
+	message.CreatedAt = time.Now()
+	message.AvailableAt = message.CreatedAt
+
+	err := r.db.QueryRow(ctx, query,
+		message.ID, message.AggregateType, message.AggregateID, message.Exchange,
+		message.RoutingKey, message.Payload, message.AvailableAt, message.CreatedAt).Scan(&message.Sequence)
+
+	if err != nil {
+		return fmt.Errorf("error enqueuing outbox message: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimPending reclama hasta leaseUntil el mensaje pendiente más antiguo de cada agregado
+func (r *OutboxRepository) ClaimPending(ctx context.Context, limit int, leaseUntil time.Time) ([]*models.OutboxMessage, error) {
+	query := `
+		WITH claimed AS (
+			UPDATE stock_outbox
+			SET available_at = $2
+			WHERE id IN (
+				SELECT o.id
+				FROM stock_outbox o
+				WHERE o.published_at IS NULL
+				AND o.available_at <= NOW()
+				AND NOT EXISTS (
+					SELECT 1 FROM stock_outbox p
+					WHERE p.aggregate_type = o.aggregate_type AND p.aggregate_id = o.aggregate_id
+					AND p.published_at IS NULL AND p.sequence < o.sequence
+				)
+				ORDER BY o.sequence
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, sequence, aggregate_type, aggregate_id, exchange, routing_key,
+				payload, attempts, last_error, available_at, created_at, published_at
+		)
+		SELECT * FROM claimed
+		ORDER BY sequence
+	`
+
+	rows, err := r.db.Query(ctx, query, limit, leaseUntil)
+	if err != nil {
+		return nil, fmt.Errorf("error querying outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	var messages []*models.OutboxMessage
+	for rows.Next() {
+		var message models.OutboxMessage
+		err := rows.Scan(
+			&message.ID, &message.Sequence, &message.AggregateType, &message.AggregateID,
+			&message.Exchange, &message.RoutingKey, &message.Payload, &message.Attempts,
+			&message.LastError, &message.AvailableAt, &message.CreatedAt, &message.PublishedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning outbox message: %w", err)
+		}
+		messages = append(messages, &message)
+	}
+
+	return messages, rows.Err()
+}
+
+// MarkPublished marca un mensaje como publicado
+func (r *OutboxRepository) MarkPublished(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE stock_outbox SET published_at = $1, attempts = attempts + 1, last_error = NULL WHERE id = $2",
+		time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("error marking outbox message as published: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed registra un intento fallido y posterga el siguiente intento
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uuid.UUID, publishErr error, nextAttemptAt time.Time) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE stock_outbox SET attempts = attempts + 1, last_error = $1, available_at = $2 WHERE id = $3",
+		publishErr.Error(), nextAttemptAt, id)
+	if err != nil {
+		return fmt.Errorf("error marking outbox message as failed: %w", err)
+	}
+	return nil
+}
+
+// ReleaseClaims devuelve mensajes reclamados y no publicados a la cola, sin contar un intento
+func (r *OutboxRepository) ReleaseClaims(ctx context.Context, ids []uuid.UUID) error {
+	_, err := r.db.Exec(ctx,
+		"UPDATE stock_outbox SET available_at = NOW() WHERE id = ANY($1) AND published_at IS NULL",
+		ids)
+	if err != nil {
+		return fmt.Errorf("error releasing outbox messages: %w", err)
+	}
+	return nil
+}
+
+// DeletePublishedBefore elimina los mensajes ya publicados antes de la fecha indicada
+func (r *OutboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.Exec(ctx,
+		"DELETE FROM stock_outbox WHERE published_at IS NOT NULL AND published_at < $1",
+		before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting published outbox messages: %w", err)
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ProcessedMessageRepository struct {
+	db DBTX
+}
+
+func NewProcessedMessageRepository(db *pgxpool.Pool) *ProcessedMessageRepository {
+	return &ProcessedMessageRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *ProcessedMessageRepository) WithTx(tx pgx.Tx) *ProcessedMessageRepository {
+	return &ProcessedMessageRepository{
+		db: tx,
+	}
+}
+
+// Claim registra el mensaje como en proceso; retorna false si el consumer ya lo había registrado
+func (r *ProcessedMessageRepository) Claim(ctx context.Context, consumer, messageID string) (bool, error) {
+	query := `
+		INSERT INTO processed_messages (consumer, message_id, outcome, processed_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (consumer, message_id) DO NOTHING
+	`
+
+	result, err := r.db.Exec(ctx, query, consumer, messageID, models.ProcessedOutcomeProcessing, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("error claiming processed message: %w", err)
+	}
+
+	return result.RowsAffected() == 1, nil
+}
+
+// GetProcessedMessage obtiene la entrada registrada para un mensaje, o nil si no existe
+func (r *ProcessedMessageRepository) GetProcessedMessage(ctx context.Context, consumer, messageID string) (*models.ProcessedMessage, error) {
+	query := `
+		SELECT consumer, message_id, outcome, result, error, processed_at
+		FROM processed_messages
+		WHERE consumer = $1 AND message_id = $2
+	`
+
+	var message models.ProcessedMessage
+	err := r.db.QueryRow(ctx, query, consumer, messageID).Scan(
+		&message.Consumer, &message.MessageID, &message.Outcome,
+		&message.Result, &message.Error, &message.ProcessedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting processed message: %w", err)
+	}
+
+	return &message, nil
+}
+
+// Complete guarda el resultado final de un mensaje previamente reclamado
+func (r *ProcessedMessageRepository) Complete(ctx context.Context, message *models.ProcessedMessage) error {
+	query := `
+		UPDATE processed_messages
+		SET outcome = $1, result = $2, error = $3, processed_at = $4
+		WHERE consumer = $5 AND message_id = $6
+	`
+
+	message.ProcessedAt = time.Now()
+
+	var result any
+	if len(message.Result) > 0 {
+		result = json.RawMessage(message.Result)
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		message.Outcome, result, message.Error, message.ProcessedAt,
+		message.Consumer, message.MessageID)
+	if err != nil {
+		return fmt.Errorf("error completing processed message: %w", err)
+	}
+
+	return nil
+}
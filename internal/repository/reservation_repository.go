@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolationCode es el código de PostgreSQL para violaciones de UNIQUE
+const uniqueViolationCode = "23505"
+
+type ReservationRepository struct {
+	db DBTX
+}
+
+func NewReservationRepository(db *pgxpool.Pool) *ReservationRepository {
+	return &ReservationRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *ReservationRepository) WithTx(tx pgx.Tx) *ReservationRepository {
+	return &ReservationRepository{
+		db: tx,
+	}
+}
+
+// CreateReservation crea una nueva reserva activa
+func (r *ReservationRepository) CreateReservation(ctx context.Context, reservation *models.StockReservation) error {
+	query := `
+		INSERT INTO stock_reservations (id, article_id, order_id, quantity, status, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	reservation.ID = uuid.New()
+	reservation.CreatedAt = time.Now()
+	reservation.UpdatedAt = reservation.CreatedAt
+	if reservation.Status == "" {
+		reservation.Status = models.ReservationStatusActive
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		reservation.ID, reservation.ArticleID, reservation.OrderID, reservation.Quantity,
+		reservation.Status, reservation.ExpiresAt, reservation.CreatedAt, reservation.UpdatedAt)
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return fmt.Errorf("%w: order %s, article %s", models.ErrDuplicateReservation, reservation.OrderID, reservation.ArticleID)
+		}
+		return fmt.Errorf("error creating reservation: %w", err)
+	}
+
+	return nil
+}
+
+// GetActiveReservation obtiene la reserva activa de una orden para un artículo, o nil si no existe
+func (r *ReservationRepository) GetActiveReservation(ctx context.Context, orderID, articleID string) (*models.StockReservation, error) {
+	query := `
+		SELECT id, article_id, order_id, quantity, status, expires_at, created_at, updated_at
+		FROM stock_reservations
+		WHERE order_id = $1 AND article_id = $2 AND status = 'ACTIVE'
+	`
+
+	reservation, err := scanReservation(r.db.QueryRow(ctx, query, orderID, articleID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting active reservation: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// GetLatestReservationForUpdate obtiene la reserva más reciente de una orden para un artículo
+// y bloquea la fila hasta el fin de la transacción. Retorna nil si no existe.
+func (r *ReservationRepository) GetLatestReservationForUpdate(ctx context.Context, orderID, articleID string) (*models.StockReservation, error) {
+	query := `
+		SELECT id, article_id, order_id, quantity, status, expires_at, created_at, updated_at
+		FROM stock_reservations
+		WHERE order_id = $1 AND article_id = $2
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+
+	reservation, err := scanReservation(r.db.QueryRow(ctx, query, orderID, articleID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting reservation: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// GetReservationsByOrderID obtiene todas las reservas de una orden
+func (r *ReservationRepository) GetReservationsByOrderID(ctx context.Context, orderID string) ([]*models.StockReservation, error) {
+	query := `
+		SELECT id, article_id, order_id, quantity, status, expires_at, created_at, updated_at
+		FROM stock_reservations
+		WHERE order_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying reservations by order: %w", err)
+	}
+	defer rows.Close()
+
+	var reservations []*models.StockReservation
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning reservation: %w", err)
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, rows.Err()
+}
+
+// ListExpiredReservations obtiene un lote de reservas activas vencidas, sin bloquearlas. Cada
+// reserva se bloquea después con LockExpiredReservation, una vez bloqueado su artículo.
+func (r *ReservationRepository) ListExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*models.StockReservation, error) {
+	query := `
+		SELECT id, article_id, order_id, quantity, status, expires_at, created_at, updated_at
+		FROM stock_reservations
+		WHERE status = 'ACTIVE' AND expires_at IS NOT NULL AND expires_at <= $1
+		ORDER BY expires_at ASC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error querying expired reservations: %w", err)
+	}
+	defer rows.Close()
+
+	var reservations []*models.StockReservation
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning reservation: %w", err)
+		}
+		reservations = append(reservations, reservation)
+	}
+
+	return reservations, rows.Err()
+}
+
+// LockExpiredReservation bloquea la reserva si sigue activa y vencida; retorna nil si otra
+// transacción ya la cerró. El artículo de la reserva debe estar bloqueado.
+func (r *ReservationRepository) LockExpiredReservation(ctx context.Context, id uuid.UUID, now time.Time) (*models.StockReservation, error) {
+	query := `
+		SELECT id, article_id, order_id, quantity, status, expires_at, created_at, updated_at
+		FROM stock_reservations
+		WHERE id = $1 AND status = 'ACTIVE' AND expires_at IS NOT NULL AND expires_at <= $2
+		FOR UPDATE
+	`
+
+	reservation, err := scanReservation(r.db.QueryRow(ctx, query, id, now))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error locking expired reservation: %w", err)
+	}
+
+	return reservation, nil
+}
+
+// UpdateReservationStatus cambia el estado de una reserva siempre que siga en el estado esperado
+func (r *ReservationRepository) UpdateReservationStatus(ctx context.Context, id uuid.UUID, from, to models.ReservationStatus) error {
+	query := `
+		UPDATE stock_reservations
+		SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`
+
+	result, err := r.db.Exec(ctx, query, to, time.Now(), id, from)
+	if err != nil {
+		return fmt.Errorf("error updating reservation status: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return fmt.Errorf("reservation %s is no longer %s", id, from)
+	}
+
+	return nil
+}
+
+func scanReservation(row pgx.Row) (*models.StockReservation, error) {
+	var reservation models.StockReservation
+	err := row.Scan(
+		&reservation.ID, &reservation.ArticleID, &reservation.OrderID, &reservation.Quantity,
+		&reservation.Status, &reservation.ExpiresAt, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
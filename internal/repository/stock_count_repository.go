@@ -0,0 +1,247 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const stockCountSessionColumns = `id, location, status, COALESCE(notes, ''), COALESCE(opened_by, ''),
+	COALESCE(closed_by, ''), created_at, updated_at, closed_at`
+
+type StockCountRepository struct {
+	db DBTX
+}
+
+func NewStockCountRepository(db *pgxpool.Pool) *StockCountRepository {
+	return &StockCountRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *StockCountRepository) WithTx(tx pgx.Tx) *StockCountRepository {
+	return &StockCountRepository{
+		db: tx,
+	}
+}
+
+// CreateSession registra una sesión de recuento abierta. Retorna ErrStockCountAlreadyOpen si
+// la ubicación ya tiene una.
+func (r *StockCountRepository) CreateSession(ctx context.Context, session *models.StockCountSession) error {
+	query := `
+		INSERT INTO stock_count_sessions (id, location, status, notes, opened_by, created_at, updated_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6, $6)
+	`
+
+	session.ID = uuid.New()
+	session.Status = models.StockCountStatusOpen
+	session.CreatedAt = time.Now()
+	session.UpdatedAt = session.CreatedAt
+
+	_, err := r.db.Exec(ctx, query,
+		session.ID, session.Location, session.Status, session.Notes, session.OpenedBy, session.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return fmt.Errorf("%w: %s", models.ErrStockCountAlreadyOpen, session.Location)
+		}
+		return fmt.Errorf("error creating stock count: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession obtiene una sesión de recuento por ID
+func (r *StockCountRepository) GetSession(ctx context.Context, id uuid.UUID) (*models.StockCountSession, error) {
+	return r.getSession(ctx, id, "")
+}
+
+// GetSessionForUpdate obtiene una sesión de recuento y bloquea su fila hasta el fin de la
+// transacción; debe ejecutarse dentro de una transacción
+func (r *StockCountRepository) GetSessionForUpdate(ctx context.Context, id uuid.UUID) (*models.StockCountSession, error) {
+	return r.getSession(ctx, id, "FOR UPDATE")
+}
+
+func (r *StockCountRepository) getSession(ctx context.Context, id uuid.UUID, lock string) (*models.StockCountSession, error) {
+	query := `
+		SELECT ` + stockCountSessionColumns + `
+		FROM stock_count_sessions
+		WHERE id = $1
+		` + lock
+
+	var session models.StockCountSession
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&session.ID, &session.Location, &session.Status, &session.Notes, &session.OpenedBy,
+		&session.ClosedBy, &session.CreatedAt, &session.UpdatedAt, &session.ClosedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", models.ErrStockCountNotFound, id)
+		}
+		return nil, fmt.Errorf("error getting stock count: %w", err)
+	}
+
+	return &session, nil
+}
+
+// CloseSession registra el estado final de la sesión (publicada o cancelada)
+func (r *StockCountRepository) CloseSession(ctx context.Context, session *models.StockCountSession, status models.StockCountStatus) error {
+	query := `
+		UPDATE stock_count_sessions
+		SET status = $2, closed_by = NULLIF($3, ''), closed_at = $4, updated_at = $4
+		WHERE id = $1
+	`
+
+	closedAt := time.Now()
+	if _, err := r.db.Exec(ctx, query, session.ID, status, session.ClosedBy, closedAt); err != nil {
+		return fmt.Errorf("error closing stock count: %w", err)
+	}
+
+	session.Status = status
+	session.ClosedAt = &closedAt
+	session.UpdatedAt = closedAt
+	return nil
+}
+
+// GetArticleLocations obtiene la ubicación de los artículos indicados; los inexistentes no
+// aparecen en el mapa
+func (r *StockCountRepository) GetArticleLocations(ctx context.Context, articleIDs []string) (map[string]string, error) {
+	rows, err := r.db.Query(ctx,
+		"SELECT article_id, COALESCE(location, '') FROM stocks WHERE article_id = ANY($1)",
+		articleIDs)
+	if err != nil {
+		return nil, fmt.Errorf("error querying article locations: %w", err)
+	}
+	defer rows.Close()
+
+	locations := make(map[string]string, len(articleIDs))
+	for rows.Next() {
+		var articleID, location string
+		if err := rows.Scan(&articleID, &location); err != nil {
+			return nil, fmt.Errorf("error scanning article location: %w", err)
+		}
+		locations[articleID] = location
+	}
+
+	return locations, rows.Err()
+}
+
+// UpsertLine registra la cantidad contada de un artículo junto con su stock actual como
+// cantidad esperada; si ya estaba cargado reemplaza ambas
+func (r *StockCountRepository) UpsertLine(ctx context.Context, line *models.StockCountLine) error {
+	query := `
+		INSERT INTO stock_count_lines (id, session_id, article_id, counted_quantity, reason, status,
+			submitted_quantity, counted_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), 'PENDING',
+			(SELECT quantity FROM stocks WHERE article_id = $3), NULLIF($6, ''), $7, $7)
+		ON CONFLICT (session_id, article_id) DO UPDATE SET
+			counted_quantity = EXCLUDED.counted_quantity,
+			submitted_quantity = EXCLUDED.submitted_quantity,
+			reason = EXCLUDED.reason,
+			counted_by = EXCLUDED.counted_by,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	now := time.Now()
+	_, err := r.db.Exec(ctx, query,
+		uuid.New(), line.SessionID, line.ArticleID, line.CountedQuantity, line.Reason, line.CountedBy, now)
+	if err != nil {
+		return fmt.Errorf("error saving stock count line: %w", err)
+	}
+
+	return nil
+}
+
+// GetLines obtiene las líneas de la sesión ordenadas por artículo
+func (r *StockCountRepository) GetLines(ctx context.Context, sessionID uuid.UUID) ([]*models.StockCountLine, error) {
+	query := `
+		SELECT l.id, l.session_id, l.article_id, l.counted_quantity, COALESCE(l.reason, ''), l.status,
+			COALESCE(l.expected_quantity, l.submitted_quantity, s.quantity),
+			COALESCE(l.variance, l.counted_quantity - COALESCE(l.submitted_quantity, s.quantity)),
+			CASE WHEN l.status = 'PENDING' THEN s.quantity END,
+			CASE WHEN l.status = 'PENDING' THEN s.reserved END,
+			l.adjust_event_id, COALESCE(l.counted_by, ''), l.created_at, l.updated_at
+		FROM stock_count_lines l
+		LEFT JOIN stocks s ON s.article_id = l.article_id
+		WHERE l.session_id = $1
+		ORDER BY l.article_id
+	`
+
+	rows, err := r.db.Query(ctx, query, sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stock count lines: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []*models.StockCountLine{}
+	for rows.Next() {
+		var line models.StockCountLine
+		if err := rows.Scan(
+			&line.ID, &line.SessionID, &line.ArticleID, &line.CountedQuantity, &line.Reason, &line.Status,
+			&line.ExpectedQuantity, &line.Variance, &line.CurrentQuantity, &line.Reserved,
+			&line.AdjustEventID, &line.CountedBy, &line.CreatedAt, &line.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning stock count line: %w", err)
+		}
+		lines = append(lines, &line)
+	}
+
+	return lines, rows.Err()
+}
+
+// UpdateLineResult registra el resultado de publicar la línea
+func (r *StockCountRepository) UpdateLineResult(ctx context.Context, line *models.StockCountLine) error {
+	query := `
+		UPDATE stock_count_lines
+		SET status = $2, expected_quantity = $3, variance = $4, adjust_event_id = $5, updated_at = $6
+		WHERE id = $1
+	`
+
+	line.UpdatedAt = time.Now()
+	_, err := r.db.Exec(ctx, query,
+		line.ID, line.Status, line.ExpectedQuantity, line.Variance, line.AdjustEventID, line.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error updating stock count line: %w", err)
+	}
+
+	return nil
+}
+
+// GetUncountedArticleIDs obtiene los artículos no archivados de la ubicación que no tienen
+// cantidad contada en la sesión
+func (r *StockCountRepository) GetUncountedArticleIDs(ctx context.Context, sessionID uuid.UUID, location string) ([]string, error) {
+	query := `
+		SELECT s.article_id
+		FROM stocks s
+		WHERE s.location = $2 AND s.status <> 'ARCHIVED'
+		  AND NOT EXISTS (
+			SELECT 1 FROM stock_count_lines l
+			WHERE l.session_id = $1 AND l.article_id = s.article_id
+		  )
+		ORDER BY s.article_id
+	`
+
+	rows, err := r.db.Query(ctx, query, sessionID, location)
+	if err != nil {
+		return nil, fmt.Errorf("error querying uncounted articles: %w", err)
+	}
+	defer rows.Close()
+
+	var articleIDs []string
+	for rows.Next() {
+		var articleID string
+		if err := rows.Scan(&articleID); err != nil {
+			return nil, fmt.Errorf("error scanning article id: %w", err)
+		}
+		articleIDs = append(articleIDs, articleID)
+	}
+
+	return articleIDs, rows.Err()
+}
@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// archiveRunLockKey identifica el advisory lock que serializa el archivado de particiones
+// entre réplicas
+const archiveRunLockKey = "stock_event_archive"
+
+const stockEventPartitionColumns = `name, range_start, range_end, status, archive_path, archived_rows,
+	archive_sha256, archived_at, created_at`
+
+// restoredEventColumns son las columnas que se cargan al restaurar un archivo de eventos
+var restoredEventColumns = []string{
+	"id", "article_id", "event_type", "quantity", "order_id", "reason", "metadata",
+	"quantity_before", "quantity_after", "reserved_before", "reserved_after",
+	"actor_type", "actor_id", "actor_name", "request_id", "message_id",
+	"correlation_id", "causation_id", "created_at",
+}
+
+type StockEventPartitionRepository struct {
+	db DBTX
+}
+
+func NewStockEventPartitionRepository(db *pgxpool.Pool) *StockEventPartitionRepository {
+	return &StockEventPartitionRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *StockEventPartitionRepository) WithTx(tx pgx.Tx) *StockEventPartitionRepository {
+	return &StockEventPartitionRepository{
+		db: tx,
+	}
+}
+
+// TryLockArchiveRun toma el advisory lock del archivado hasta el fin de la transacción.
+// Retorna false si otra réplica lo tiene; debe ejecutarse dentro de una transacción.
+func (r *StockEventPartitionRepository) TryLockArchiveRun(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.db.QueryRow(ctx,
+		"SELECT pg_try_advisory_xact_lock(hashtext($1))",
+		archiveRunLockKey).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("error acquiring archive lock: %w", err)
+	}
+
+	return locked, nil
+}
+
+// EnsurePartition crea, si no existe, la partición del mes que contiene month (límites en
+// UTC) y retorna su nombre. Los meses ya archivados no se vuelven a crear.
+func (r *StockEventPartitionRepository) EnsurePartition(ctx context.Context, month time.Time) (string, error) {
+	var name string
+	err := r.db.QueryRow(ctx,
+		"SELECT create_stock_events_partition($1::date)",
+		month.UTC().Format("2006-01-02")).Scan(&name)
+	if err != nil {
+		return "", fmt.Errorf("error creating stock events partition: %w", err)
+	}
+
+	return name, nil
+}
+
+// GetPartition obtiene una partición por nombre, o nil si no está registrada
+func (r *StockEventPartitionRepository) GetPartition(ctx context.Context, name string) (*models.StockEventPartition, error) {
+	query := `
+		SELECT ` + stockEventPartitionColumns + `
+		FROM stock_event_partitions
+		WHERE name = $1
+	`
+
+	partition, err := scanStockEventPartition(r.db.QueryRow(ctx, query, name))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting stock events partition: %w", err)
+	}
+
+	return partition, nil
+}
+
+// GetPartitionsToArchive obtiene, de la más antigua a la más reciente, las particiones
+// adjuntas cuyo rango termina hasta before y las separadas cuyo archivo falta verificar
+func (r *StockEventPartitionRepository) GetPartitionsToArchive(ctx context.Context, before time.Time) ([]*models.StockEventPartition, error) {
+	query := `
+		SELECT ` + stockEventPartitionColumns + `
+		FROM stock_event_partitions
+		WHERE (status = 'ATTACHED' AND range_end <= $1) OR status = 'DETACHED'
+		ORDER BY range_start
+	`
+
+	rows, err := r.db.Query(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stock events partitions: %w", err)
+	}
+	defer rows.Close()
+
+	var partitions []*models.StockEventPartition
+	for rows.Next() {
+		partition, err := scanStockEventPartition(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning stock events partition: %w", err)
+		}
+		partitions = append(partitions, partition)
+	}
+
+	return partitions, rows.Err()
+}
+
+// GetArchivedHorizon retorna el fin del rango archivado más reciente: los eventos anteriores
+// ya no están en stock_events. Retorna nil si no se archivó ninguna partición.
+func (r *StockEventPartitionRepository) GetArchivedHorizon(ctx context.Context) (*time.Time, error) {
+	var horizon *time.Time
+	err := r.db.QueryRow(ctx,
+		"SELECT MAX(range_end) FROM stock_event_partitions WHERE status IN ('DETACHED', 'ARCHIVED')").Scan(&horizon)
+	if err != nil {
+		return nil, fmt.Errorf("error getting archived horizon: %w", err)
+	}
+
+	return horizon, nil
+}
+
+// ExportPartition recorre en orden cronológico los eventos de la partición y retorna cuántos
+// se procesaron
+func (r *StockEventPartitionRepository) ExportPartition(ctx context.Context, name string, fn func(*models.StockEvent) error) (int, error) {
+	query := `
+		SELECT ` + stockEventColumns + `
+		FROM ` + pgx.Identifier{name}.Sanitize() + `
+		ORDER BY created_at, id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("error querying partition %s: %w", name, err)
+	}
+	defer rows.Close()
+
+	exported := 0
+	for rows.Next() {
+		event, err := scanStockEvent(rows)
+		if err != nil {
+			return exported, fmt.Errorf("error scanning stock event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return exported, err
+		}
+		exported++
+	}
+
+	return exported, rows.Err()
+}
+
+// CountPartitionRows retorna la cantidad de eventos de la partición
+func (r *StockEventPartitionRepository) CountPartitionRows(ctx context.Context, name string) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM "+pgx.Identifier{name}.Sanitize()).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("error counting rows of partition %s: %w", name, err)
+	}
+
+	return count, nil
+}
+
+// AccumulateArchivedBalances suma a stock_event_archived_balances la variación neta de
+// cantidad y reservado de los eventos de la partición, por artículo
+func (r *StockEventPartitionRepository) AccumulateArchivedBalances(ctx context.Context, name string) error {
+	query := `
+		INSERT INTO stock_event_archived_balances (article_id, quantity, reserved, updated_at)
+		SELECT article_id, SUM(` + ledgerQuantityDelta + `), SUM(` + ledgerReservedDelta + `), NOW()
+		FROM ` + pgx.Identifier{name}.Sanitize() + `
+		GROUP BY article_id
+		ON CONFLICT (article_id) DO UPDATE SET
+			quantity = stock_event_archived_balances.quantity + EXCLUDED.quantity,
+			reserved = stock_event_archived_balances.reserved + EXCLUDED.reserved,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	if _, err := r.db.Exec(ctx, query); err != nil {
+		return fmt.Errorf("error accumulating archived balances of partition %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// DetachPartition separa la partición de stock_events y conserva su tabla
+func (r *StockEventPartitionRepository) DetachPartition(ctx context.Context, name string) error {
+	if _, err := r.db.Exec(ctx, "ALTER TABLE stock_events DETACH PARTITION "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return fmt.Errorf("error detaching partition %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// DropDetachedPartition elimina la tabla de una partición ya separada de stock_events
+func (r *StockEventPartitionRepository) DropDetachedPartition(ctx context.Context, name string) error {
+	if _, err := r.db.Exec(ctx, "DROP TABLE IF EXISTS "+pgx.Identifier{name}.Sanitize()); err != nil {
+		return fmt.Errorf("error dropping partition %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// MarkDetached registra el archivo de la partición y la marca como separada
+func (r *StockEventPartitionRepository) MarkDetached(ctx context.Context, partition *models.StockEventPartition) error {
+	query := `
+		UPDATE stock_event_partitions
+		SET status = 'DETACHED', archive_path = $2, archived_rows = $3, archive_sha256 = $4
+		WHERE name = $1
+	`
+
+	_, err := r.db.Exec(ctx, query,
+		partition.Name, partition.ArchivePath, partition.ArchivedRows, partition.ArchiveSHA256)
+	if err != nil {
+		return fmt.Errorf("error marking partition %s as detached: %w", partition.Name, err)
+	}
+
+	partition.Status = models.PartitionStatusDetached
+	return nil
+}
+
+// MarkArchived registra el archivo verificado de la partición y la marca como archivada
+func (r *StockEventPartitionRepository) MarkArchived(ctx context.Context, partition *models.StockEventPartition) error {
+	query := `
+		UPDATE stock_event_partitions
+		SET status = 'ARCHIVED', archive_path = $2, archived_rows = $3, archive_sha256 = $4, archived_at = $5
+		WHERE name = $1
+	`
+
+	archivedAt := time.Now()
+	_, err := r.db.Exec(ctx, query,
+		partition.Name, partition.ArchivePath, partition.ArchivedRows, partition.ArchiveSHA256, archivedAt)
+	if err != nil {
+		return fmt.Errorf("error marking partition %s as archived: %w", partition.Name, err)
+	}
+
+	partition.Status = models.PartitionStatusArchived
+	partition.ArchivedAt = &archivedAt
+	return nil
+}
+
+// CreateRestoreTable crea una tabla independiente con las columnas de stock_events para
+// cargar un archivo. Falla si la tabla ya existe.
+func (r *StockEventPartitionRepository) CreateRestoreTable(ctx context.Context, table string) error {
+	query := "CREATE TABLE " + pgx.Identifier{table}.Sanitize() + " (LIKE stock_events INCLUDING DEFAULTS)"
+
+	if _, err := r.db.Exec(ctx, query); err != nil {
+		return fmt.Errorf("error creating restore table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// CopyEvents carga los eventos en la tabla indicada mediante COPY
+func (r *StockEventPartitionRepository) CopyEvents(ctx context.Context, table string, events []*models.StockEvent) (int64, error) {
+	rows := make([][]any, 0, len(events))
+	for _, event := range events {
+		metadata := event.Metadata
+		if metadata == "" {
+			metadata = "{}"
+		}
+
+		rows = append(rows, []any{
+			event.ID, event.ArticleID, string(event.EventType), event.Quantity, event.OrderID, event.Reason, metadata,
+			event.QuantityBefore, event.QuantityAfter, event.ReservedBefore, event.ReservedAfter,
+			nullIfEmpty(string(event.ActorType)), nullIfEmpty(event.ActorID), nullIfEmpty(event.ActorName),
+			nullIfEmpty(event.RequestID), nullIfEmpty(event.MessageID),
+			nullIfEmpty(event.CorrelationID), nullIfEmpty(event.CausationID),
+			event.CreatedAt,
+		})
+	}
+
+	copied, err := r.db.CopyFrom(ctx, pgx.Identifier{table}, restoredEventColumns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return copied, fmt.Errorf("error copying events into %s: %w", table, err)
+	}
+
+	return copied, nil
+}
+
+func scanStockEventPartition(row pgx.Row) (*models.StockEventPartition, error) {
+	var partition models.StockEventPartition
+	err := row.Scan(
+		&partition.Name, &partition.RangeStart, &partition.RangeEnd, &partition.Status,
+		&partition.ArchivePath, &partition.ArchivedRows, &partition.ArchiveSHA256,
+		&partition.ArchivedAt, &partition.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &partition, nil
+}
+
+// nullIfEmpty convierte las cadenas vacías en NULL
+func nullIfEmpty(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
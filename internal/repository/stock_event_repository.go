@@ -3,15 +3,26 @@ package repository
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/MatiasTelo/stockgo/internal/models"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// stockEventColumns son las columnas que lee scanStockEvent, en el mismo orden. Las
+// columnas de atribución son opcionales y se leen como cadena vacía cuando son NULL.
+const stockEventColumns = `id, article_id, event_type, quantity, order_id, reason, metadata,
+		quantity_before, quantity_after, reserved_before, reserved_after,
+		COALESCE(actor_type, ''), COALESCE(actor_id, ''), COALESCE(actor_name, ''),
+		COALESCE(request_id, ''), COALESCE(message_id, ''),
+		COALESCE(correlation_id, ''), COALESCE(causation_id, ''),
+		created_at`
+
 type StockEventRepository struct {
-	db *pgxpool.Pool
+	db DBTX
 }
 
 func NewStockEventRepository(db *pgxpool.Pool) *StockEventRepository {
@@ -20,11 +31,23 @@ func NewStockEventRepository(db *pgxpool.Pool) *StockEventRepository {
 	}
 }
 
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *StockEventRepository) WithTx(tx pgx.Tx) *StockEventRepository {
+	return &StockEventRepository{
+		db: tx,
+	}
+}
+
 // CreateStockEvent crea un nuevo evento de stock
 func (r *StockEventRepository) CreateStockEvent(ctx context.Context, event *models.StockEvent) error {
 	query := `
-		INSERT INTO stock_events (id, article_id, event_type, quantity, order_id, reason, metadata, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO stock_events (id, article_id, event_type, quantity, order_id, reason, metadata,
+		                          quantity_before, quantity_after, reserved_before, reserved_after,
+		                          actor_type, actor_id, actor_name, request_id, message_id,
+		                          correlation_id, causation_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11,
+		        NULLIF($12, ''), NULLIF($13, ''), NULLIF($14, ''), NULLIF($15, ''), NULLIF($16, ''),
+		        NULLIF($17, ''), NULLIF($18, ''), $19)
 	`
 
 	event.ID = uuid.New()
@@ -38,7 +61,11 @@ func (r *StockEventRepository) CreateStockEvent(ctx context.Context, event *mode
 
 	_, err := r.db.Exec(ctx, query,
 		event.ID, event.ArticleID, event.EventType, event.Quantity,
-		event.OrderID, event.Reason, metadata, event.CreatedAt)
+		event.OrderID, event.Reason, metadata,
+		event.QuantityBefore, event.QuantityAfter, event.ReservedBefore, event.ReservedAfter,
+		string(event.ActorType), event.ActorID, event.ActorName, event.RequestID, event.MessageID,
+		event.CorrelationID, event.CausationID,
+		event.CreatedAt)
 
 	if err != nil {
 		return fmt.Errorf("error creating stock event: %w", err)
@@ -47,98 +74,174 @@ func (r *StockEventRepository) CreateStockEvent(ctx context.Context, event *mode
 	return nil
 }
 
-// GetStockEventsByArticleID obtiene eventos por ID del artículo
-func (r *StockEventRepository) GetStockEventsByArticleID(ctx context.Context, articleID string, limit int) ([]*models.StockEvent, error) {
+// GetStockEventsByOrderID obtiene eventos por ID de orden
+func (r *StockEventRepository) GetStockEventsByOrderID(ctx context.Context, orderID string) ([]*models.StockEvent, error) {
 	query := `
-		SELECT id, article_id, event_type, quantity, order_id, reason, metadata, created_at
+		SELECT ` + stockEventColumns + `
 		FROM stock_events
-		WHERE article_id = $1
+		WHERE order_id = $1
 		ORDER BY created_at DESC
-		LIMIT $2
 	`
 
-	rows, err := r.db.Query(ctx, query, articleID, limit)
+	rows, err := r.db.Query(ctx, query, orderID)
 	if err != nil {
-		return nil, fmt.Errorf("error querying stock events: %w", err)
+		return nil, fmt.Errorf("error querying stock events by order: %w", err)
 	}
 	defer rows.Close()
 
 	var events []*models.StockEvent
 	for rows.Next() {
-		var event models.StockEvent
-		err := rows.Scan(
-			&event.ID, &event.ArticleID, &event.EventType, &event.Quantity,
-			&event.OrderID, &event.Reason, &event.Metadata, &event.CreatedAt)
+		event, err := scanStockEvent(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning stock event: %w", err)
 		}
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
 	return events, nil
 }
 
-// GetStockEventsByOrderID obtiene eventos por ID de orden
-func (r *StockEventRepository) GetStockEventsByOrderID(ctx context.Context, orderID string) ([]*models.StockEvent, error) {
+// ListStockEvents busca eventos según el filtro, del más reciente al más antiguo, paginando por
+// (created_at, id)
+func (r *StockEventRepository) ListStockEvents(ctx context.Context, filter models.StockEventFilter) ([]*models.StockEvent, error) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if filter.ArticleID != "" {
+		addCondition("article_id = %s", filter.ArticleID)
+	}
+	if filter.OrderID != "" {
+		addCondition("order_id = %s", filter.OrderID)
+	}
+	if len(filter.EventTypes) > 0 {
+		eventTypes := make([]string, len(filter.EventTypes))
+		for i, eventType := range filter.EventTypes {
+			eventTypes[i] = string(eventType)
+		}
+		addCondition("event_type = ANY(%s)", eventTypes)
+	}
+	if filter.ActorType != "" {
+		addCondition("actor_type = %s", string(filter.ActorType))
+	}
+	if filter.ActorID != "" {
+		addCondition("actor_id = %s", filter.ActorID)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= %s", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < %s", *filter.To)
+	}
+	if filter.After != nil {
+		addCondition("(created_at, id) < (%s, %s)", filter.After.CreatedAt, filter.After.ID)
+	}
+
 	query := `
-		SELECT id, article_id, event_type, quantity, order_id, reason, metadata, created_at
-		FROM stock_events
-		WHERE order_id = $1
-		ORDER BY created_at DESC
-	`
+		SELECT ` + stockEventColumns + `
+		FROM stock_events`
+	if len(conditions) > 0 {
+		query += `
+		WHERE ` + strings.Join(conditions, " AND ")
+	}
 
-	rows, err := r.db.Query(ctx, query, orderID)
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(`
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d`, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, fmt.Errorf("error querying stock events by order: %w", err)
+		return nil, fmt.Errorf("error querying stock events: %w", err)
 	}
 	defer rows.Close()
 
-	var events []*models.StockEvent
+	events := []*models.StockEvent{}
 	for rows.Next() {
-		var event models.StockEvent
-		err := rows.Scan(
-			&event.ID, &event.ArticleID, &event.EventType, &event.Quantity,
-			&event.OrderID, &event.Reason, &event.Metadata, &event.CreatedAt)
+		event, err := scanStockEvent(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning stock event: %w", err)
 		}
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
-	return events, nil
+	return events, rows.Err()
 }
 
-// GetAllStockEvents obtiene todos los eventos con paginación
-func (r *StockEventRepository) GetAllStockEvents(ctx context.Context, offset, limit int) ([]*models.StockEvent, error) {
+// GetLastBalancedEvent obtiene el último evento del artículo con saldos registrados y
+// created_at en el rango (since, until]; since nil no acota el inicio. Retorna nil si no hay.
+func (r *StockEventRepository) GetLastBalancedEvent(ctx context.Context, articleID string, since *time.Time, until time.Time) (*models.StockEvent, error) {
 	query := `
-		SELECT id, article_id, event_type, quantity, order_id, reason, metadata, created_at
+		SELECT ` + stockEventColumns + `
 		FROM stock_events
-		ORDER BY created_at DESC
-		OFFSET $1 LIMIT $2
+		WHERE article_id = $1
+		  AND created_at <= $2
+		  AND ($3::timestamptz IS NULL OR created_at > $3)
+		  AND quantity_after IS NOT NULL AND reserved_after IS NOT NULL
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
 	`
 
-	rows, err := r.db.Query(ctx, query, offset, limit)
+	event, err := scanStockEvent(r.db.QueryRow(ctx, query, articleID, until, since))
 	if err != nil {
-		return nil, fmt.Errorf("error querying stock events: %w", err)
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting last balanced stock event: %w", err)
+	}
+
+	return event, nil
+}
+
+// ListEventsToReplay obtiene, en orden cronológico, los eventos del artículo hasta until que
+// siguen a la posición after o, si es nil, con created_at posterior a since (nil: desde el inicio)
+func (r *StockEventRepository) ListEventsToReplay(ctx context.Context, articleID string, since *time.Time, after *models.EventCursor, until time.Time) ([]*models.StockEvent, error) {
+	query := `
+		SELECT ` + stockEventColumns + `
+		FROM stock_events
+		WHERE article_id = $1
+		  AND created_at <= $2
+		  AND ($3::timestamptz IS NULL OR created_at > $3)
+		  AND ($4::timestamptz IS NULL OR (created_at, id) > ($4, $5::uuid))
+		ORDER BY created_at, id
+	`
+
+	var afterCreatedAt *time.Time
+	var afterID *string
+	if after != nil {
+		id := after.ID.String()
+		afterCreatedAt = &after.CreatedAt
+		afterID = &id
+	}
+
+	rows, err := r.db.Query(ctx, query, articleID, until, since, afterCreatedAt, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stock events to replay: %w", err)
 	}
 	defer rows.Close()
 
 	var events []*models.StockEvent
 	for rows.Next() {
-		var event models.StockEvent
-		err := rows.Scan(
-			&event.ID, &event.ArticleID, &event.EventType, &event.Quantity,
-			&event.OrderID, &event.Reason, &event.Metadata, &event.CreatedAt)
+		event, err := scanStockEvent(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning stock event: %w", err)
 		}
-		events = append(events, &event)
+		events = append(events, event)
 	}
 
-	return events, nil
+	return events, rows.Err()
 }
 
 // HasActiveReservation verifica si existe una reserva activa para un order_id y article_id específicos
+// según el historial: un RESERVE sin un CANCEL_RESERVE, CONFIRM_RESERVE o EXPIRE_RESERVE posterior
 func (r *StockEventRepository) HasActiveReservation(ctx context.Context, orderID, articleID string) (bool, error) {
 	query := `
 		SELECT EXISTS(
@@ -147,7 +250,7 @@ func (r *StockEventRepository) HasActiveReservation(ctx context.Context, orderID
 			AND NOT EXISTS(
 				SELECT 1 FROM stock_events se2 
 				WHERE se2.order_id = $1 AND se2.article_id = $2
-				AND se2.event_type IN ('CANCEL_RESERVE', 'CONFIRM_RESERVE')
+				AND se2.event_type IN ('CANCEL_RESERVE', 'CONFIRM_RESERVE', 'EXPIRE_RESERVE')
 				AND se2.created_at > stock_events.created_at
 			)
 		)
@@ -161,3 +264,20 @@ func (r *StockEventRepository) HasActiveReservation(ctx context.Context, orderID
 
 	return exists, nil
 }
+
+// scanStockEvent lee una fila con las columnas de stockEventColumns
+func scanStockEvent(row pgx.Row) (*models.StockEvent, error) {
+	var event models.StockEvent
+	err := row.Scan(
+		&event.ID, &event.ArticleID, &event.EventType, &event.Quantity,
+		&event.OrderID, &event.Reason, &event.Metadata,
+		&event.QuantityBefore, &event.QuantityAfter, &event.ReservedBefore, &event.ReservedAfter,
+		&event.ActorType, &event.ActorID, &event.ActorName,
+		&event.RequestID, &event.MessageID,
+		&event.CorrelationID, &event.CausationID,
+		&event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// reconciliationRunLockKey identifica el advisory lock que serializa las corridas de
+// conciliación entre réplicas
+const reconciliationRunLockKey = "stock_reconciliation"
+
+// ledgerQuantityDelta y ledgerReservedDelta calculan la variación de un evento con el mismo
+// criterio que models.StockEventType.Deltas
+const (
+	ledgerQuantityDelta = `CASE
+			WHEN event_type IN ('ADD', 'REPLENISH', 'TRANSFER_IN') THEN quantity
+			WHEN event_type IN ('DEDUCT', 'TRANSFER_OUT', 'CONFIRM_RESERVE') THEN -quantity
+			WHEN event_type = 'ADJUST' THEN COALESCE(quantity_after - quantity_before, 0)
+			ELSE 0
+		END`
+	ledgerReservedDelta = `CASE
+			WHEN event_type = 'RESERVE' THEN quantity
+			WHEN event_type IN ('CANCEL_RESERVE', 'EXPIRE_RESERVE', 'CONFIRM_RESERVE') THEN -quantity
+			WHEN event_type = 'ADJUST' THEN COALESCE(reserved_after - reserved_before, 0)
+			ELSE 0
+		END`
+)
+
+// ledgerBalancesQuery suma por artículo la variación de sus eventos más la de sus eventos ya
+// archivados, que se conserva en stock_event_archived_balances
+const ledgerBalancesQuery = `
+	SELECT article_id, SUM(quantity) AS quantity, SUM(reserved) AS reserved
+	FROM (
+		SELECT article_id, ` + ledgerQuantityDelta + ` AS quantity, ` + ledgerReservedDelta + ` AS reserved
+		FROM stock_events
+		UNION ALL
+		SELECT article_id, quantity, reserved
+		FROM stock_event_archived_balances
+	) ledger_deltas
+`
+
+const stockDiscrepancyColumns = `id, run_id, article_id, stock_quantity, stock_reserved, ledger_quantity,
+	ledger_reserved, quantity_drift, reserved_drift, repaired, repair_event_id, created_at`
+
+type StockReconciliationRepository struct {
+	db DBTX
+}
+
+func NewStockReconciliationRepository(db *pgxpool.Pool) *StockReconciliationRepository {
+	return &StockReconciliationRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *StockReconciliationRepository) WithTx(tx pgx.Tx) *StockReconciliationRepository {
+	return &StockReconciliationRepository{
+		db: tx,
+	}
+}
+
+// TryLockRun toma el advisory lock de la corrida hasta el fin de la transacción; retorna false si
+// otra réplica lo tiene
+func (r *StockReconciliationRepository) TryLockRun(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.db.QueryRow(ctx,
+		"SELECT pg_try_advisory_xact_lock(hashtext($1))",
+		reconciliationRunLockKey).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("error acquiring reconciliation lock: %w", err)
+	}
+
+	return locked, nil
+}
+
+// GetLastRunTime retorna el inicio de la corrida más reciente, o nil si no hay ninguna
+func (r *StockReconciliationRepository) GetLastRunTime(ctx context.Context) (*time.Time, error) {
+	var startedAt *time.Time
+	err := r.db.QueryRow(ctx, "SELECT MAX(started_at) FROM stock_reconciliation_runs").Scan(&startedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error getting last reconciliation run time: %w", err)
+	}
+
+	return startedAt, nil
+}
+
+// CountStocks retorna la cantidad de artículos de la tabla stocks
+func (r *StockReconciliationRepository) CountStocks(ctx context.Context) (int, error) {
+	var count int
+	if err := r.db.QueryRow(ctx, "SELECT COUNT(*) FROM stocks").Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting stocks: %w", err)
+	}
+
+	return count, nil
+}
+
+// FindDiscrepancies retorna los artículos cuyo stock no coincide con los saldos de sus eventos
+func (r *StockReconciliationRepository) FindDiscrepancies(ctx context.Context) ([]*models.StockDiscrepancy, error) {
+	query := `
+		WITH ledger AS (` + ledgerBalancesQuery + ` GROUP BY article_id)
+		SELECT COALESCE(s.article_id, l.article_id), s.quantity, s.reserved,
+			COALESCE(l.quantity, 0), COALESCE(l.reserved, 0)
+		FROM stocks s
+		FULL OUTER JOIN ledger l ON l.article_id = s.article_id
+		WHERE s.article_id IS NULL
+		   OR s.quantity <> COALESCE(l.quantity, 0)
+		   OR s.reserved <> COALESCE(l.reserved, 0)
+		ORDER BY 1
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stock discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	var discrepancies []*models.StockDiscrepancy
+	for rows.Next() {
+		discrepancy := &models.StockDiscrepancy{}
+		if err := rows.Scan(
+			&discrepancy.ArticleID, &discrepancy.StockQuantity, &discrepancy.StockReserved,
+			&discrepancy.LedgerQuantity, &discrepancy.LedgerReserved); err != nil {
+			return nil, fmt.Errorf("error scanning stock discrepancy: %w", err)
+		}
+		setDrift(discrepancy)
+		discrepancies = append(discrepancies, discrepancy)
+	}
+
+	return discrepancies, rows.Err()
+}
+
+// GetArticleDiscrepancy compara el stock de un artículo con el saldo de sus eventos, haya o no
+// diferencia. Para un resultado estable la fila de stocks debe estar bloqueada por el llamador.
+func (r *StockReconciliationRepository) GetArticleDiscrepancy(ctx context.Context, articleID string) (*models.StockDiscrepancy, error) {
+	query := `
+		SELECT s.article_id, s.quantity, s.reserved,
+			COALESCE(l.quantity, 0), COALESCE(l.reserved, 0)
+		FROM stocks s
+		LEFT JOIN (` + ledgerBalancesQuery + ` WHERE article_id = $1 GROUP BY article_id) l ON TRUE
+		WHERE s.article_id = $1
+	`
+
+	discrepancy := &models.StockDiscrepancy{}
+	err := r.db.QueryRow(ctx, query, articleID).Scan(
+		&discrepancy.ArticleID, &discrepancy.StockQuantity, &discrepancy.StockReserved,
+		&discrepancy.LedgerQuantity, &discrepancy.LedgerReserved)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, models.ErrArticleNotFound
+		}
+		return nil, fmt.Errorf("error comparing article stock with its events: %w", err)
+	}
+
+	setDrift(discrepancy)
+	return discrepancy, nil
+}
+
+// CreateRun registra una corrida de conciliación
+func (r *StockReconciliationRepository) CreateRun(ctx context.Context, run *models.ReconciliationRun) error {
+	query := `
+		INSERT INTO stock_reconciliation_runs (id, started_at, finished_at, repair, articles_checked,
+			discrepancies, repaired, triggered_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''))
+	`
+
+	if run.ID == uuid.Nil {
+		run.ID = uuid.New()
+	}
+
+	_, err := r.db.Exec(ctx, query,
+		run.ID, run.StartedAt, run.FinishedAt, run.Repair, run.ArticlesChecked,
+		run.Discrepancies, run.Repaired, run.TriggeredBy)
+	if err != nil {
+		return fmt.Errorf("error creating reconciliation run: %w", err)
+	}
+
+	return nil
+}
+
+// FinishRun registra el resultado de una corrida ya creada con CreateRun
+func (r *StockReconciliationRepository) FinishRun(ctx context.Context, run *models.ReconciliationRun) error {
+	query := `
+		UPDATE stock_reconciliation_runs
+		SET finished_at = $1, discrepancies = $2, repaired = $3
+		WHERE id = $4
+	`
+
+	_, err := r.db.Exec(ctx, query, run.FinishedAt, run.Discrepancies, run.Repaired, run.ID)
+	if err != nil {
+		return fmt.Errorf("error finishing reconciliation run: %w", err)
+	}
+
+	return nil
+}
+
+// CreateDiscrepancy registra una discrepancia de la corrida
+func (r *StockReconciliationRepository) CreateDiscrepancy(ctx context.Context, discrepancy *models.StockDiscrepancy) error {
+	query := `
+		INSERT INTO stock_reconciliation_discrepancies (` + stockDiscrepancyColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	discrepancy.ID = uuid.New()
+	discrepancy.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		discrepancy.ID, discrepancy.RunID, discrepancy.ArticleID, discrepancy.StockQuantity,
+		discrepancy.StockReserved, discrepancy.LedgerQuantity, discrepancy.LedgerReserved,
+		discrepancy.QuantityDrift, discrepancy.ReservedDrift, discrepancy.Repaired,
+		discrepancy.RepairEventID, discrepancy.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating stock discrepancy: %w", err)
+	}
+
+	return nil
+}
+
+// GetRun obtiene una corrida por ID; con id nil obtiene la más reciente
+func (r *StockReconciliationRepository) GetRun(ctx context.Context, id *uuid.UUID) (*models.ReconciliationRun, error) {
+	query := `
+		SELECT id, started_at, finished_at, repair, articles_checked, discrepancies, repaired,
+			COALESCE(triggered_by, '')
+		FROM stock_reconciliation_runs
+		WHERE $1::uuid IS NULL OR id = $1
+		ORDER BY started_at DESC
+		LIMIT 1
+	`
+
+	var run models.ReconciliationRun
+	err := r.db.QueryRow(ctx, query, id).Scan(
+		&run.ID, &run.StartedAt, &run.FinishedAt, &run.Repair, &run.ArticlesChecked,
+		&run.Discrepancies, &run.Repaired, &run.TriggeredBy)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, models.ErrReconciliationRunNotFound
+		}
+		return nil, fmt.Errorf("error getting reconciliation run: %w", err)
+	}
+
+	return &run, nil
+}
+
+// GetDiscrepanciesByRunID obtiene las discrepancias de una corrida ordenadas por artículo
+func (r *StockReconciliationRepository) GetDiscrepanciesByRunID(ctx context.Context, runID uuid.UUID) ([]*models.StockDiscrepancy, error) {
+	query := `
+		SELECT ` + stockDiscrepancyColumns + `
+		FROM stock_reconciliation_discrepancies
+		WHERE run_id = $1
+		ORDER BY article_id
+	`
+
+	rows, err := r.db.Query(ctx, query, runID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stock discrepancies: %w", err)
+	}
+	defer rows.Close()
+
+	var discrepancies []*models.StockDiscrepancy
+	for rows.Next() {
+		var discrepancy models.StockDiscrepancy
+		if err := rows.Scan(
+			&discrepancy.ID, &discrepancy.RunID, &discrepancy.ArticleID, &discrepancy.StockQuantity,
+			&discrepancy.StockReserved, &discrepancy.LedgerQuantity, &discrepancy.LedgerReserved,
+			&discrepancy.QuantityDrift, &discrepancy.ReservedDrift, &discrepancy.Repaired,
+			&discrepancy.RepairEventID, &discrepancy.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning stock discrepancy: %w", err)
+		}
+		discrepancies = append(discrepancies, &discrepancy)
+	}
+
+	return discrepancies, rows.Err()
+}
+
+// setDrift calcula la diferencia entre la tabla stocks y el historial
+func setDrift(discrepancy *models.StockDiscrepancy) {
+	discrepancy.QuantityDrift = -discrepancy.LedgerQuantity
+	discrepancy.ReservedDrift = -discrepancy.LedgerReserved
+	if discrepancy.StockQuantity != nil {
+		discrepancy.QuantityDrift += *discrepancy.StockQuantity
+	}
+	if discrepancy.StockReserved != nil {
+		discrepancy.ReservedDrift += *discrepancy.StockReserved
+	}
+}
@@ -3,18 +3,21 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/MatiasTelo/stockgo/internal/models"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 )
 
 type StockRepository struct {
-	db    *pgxpool.Pool
+	db    DBTX
 	redis *redis.Client
 }
 
@@ -25,23 +28,39 @@ func NewStockRepository(db *pgxpool.Pool, redis *redis.Client) *StockRepository
 	}
 }
 
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *StockRepository) WithTx(tx pgx.Tx) *StockRepository {
+	return &StockRepository{
+		db:    tx,
+		redis: r.redis,
+	}
+}
+
 // CreateStock crea un nuevo registro de stock
 func (r *StockRepository) CreateStock(ctx context.Context, stock *models.Stock) error {
 	query := `
-		INSERT INTO stocks (id, article_id, quantity, reserved, min_stock, max_stock, location, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO stocks (id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
-	
+
 	stock.ID = uuid.New()
+	if stock.Status == "" {
+		stock.Status = models.StockStatusActive
+	}
+	stock.Version = 1
 	stock.CreatedAt = time.Now()
 	stock.UpdatedAt = time.Now()
 
 	_, err := r.db.Exec(ctx, query,
 		stock.ID, stock.ArticleID, stock.Quantity, stock.Reserved,
-		stock.MinStock, stock.MaxStock, stock.Location,
+		stock.MinStock, stock.MaxStock, stock.Location, stock.Status, stock.Version,
 		stock.CreatedAt, stock.UpdatedAt)
 
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return fmt.Errorf("%w: %s", models.ErrArticleAlreadyExists, stock.ArticleID)
+		}
 		return fmt.Errorf("error creating stock: %w", err)
 	}
 
@@ -66,57 +85,202 @@ func (r *StockRepository) GetStockByArticleID(ctx context.Context, articleID str
 	}
 
 	// Si no está en cache, obtener de la base de datos
+	stock, err := r.GetStockUncached(ctx, articleID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Guardar en cache
+	if r.redis != nil {
+		r.cacheStock(ctx, stock)
+	}
+
+	return stock, nil
+}
+
+// GetStockUncached obtiene el stock directamente de la base de datos, sin leer ni escribir la
+// cache. Se usa cuando la versión del artículo tiene que ser la vigente (ETag).
+func (r *StockRepository) GetStockUncached(ctx context.Context, articleID string) (*models.Stock, error) {
 	query := `
-		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, created_at, updated_at
+		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
 		FROM stocks
 		WHERE article_id = $1
 	`
-	
+
 	var stock models.Stock
 	err := r.db.QueryRow(ctx, query, articleID).Scan(
 		&stock.ID, &stock.ArticleID, &stock.Quantity, &stock.Reserved,
-		&stock.MinStock, &stock.MaxStock, &stock.Location,
+		&stock.MinStock, &stock.MaxStock, &stock.Location, &stock.Status, &stock.Version,
 		&stock.CreatedAt, &stock.UpdatedAt)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("stock not found for article_id: %s", articleID)
+			return nil, fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
 		}
 		return nil, fmt.Errorf("error getting stock: %w", err)
 	}
 
-	// Guardar en cache
-	if r.redis != nil {
-		r.cacheStock(ctx, &stock)
+	return &stock, nil
+}
+
+// GetStockForUpdate obtiene el stock directamente de la base de datos (sin cache) y bloquea
+// la fila hasta el fin de la transacción; debe ejecutarse dentro de una transacción
+func (r *StockRepository) GetStockForUpdate(ctx context.Context, articleID string) (*models.Stock, error) {
+	query := `
+		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
+		FROM stocks
+		WHERE article_id = $1
+		FOR UPDATE
+	`
+
+	var stock models.Stock
+	err := r.db.QueryRow(ctx, query, articleID).Scan(
+		&stock.ID, &stock.ArticleID, &stock.Quantity, &stock.Reserved,
+		&stock.MinStock, &stock.MaxStock, &stock.Location, &stock.Status, &stock.Version,
+		&stock.CreatedAt, &stock.UpdatedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
+		}
+		return nil, fmt.Errorf("error getting stock: %w", err)
 	}
 
 	return &stock, nil
 }
 
-// UpdateStockQuantity actualiza la cantidad de stock
-func (r *StockRepository) UpdateStockQuantity(ctx context.Context, articleID string, quantity int) error {
+// IncrementQuantity suma una cantidad al stock en un único UPDATE relativo, de modo que
+// dos reposiciones concurrentes no se pisen. Retorna el stock resultante.
+func (r *StockRepository) IncrementQuantity(ctx context.Context, articleID string, quantity int) (*models.Stock, error) {
 	query := `
-		UPDATE stocks 
-		SET quantity = $1, updated_at = $2
+		UPDATE stocks
+		SET quantity = quantity + $1, version = version + 1, updated_at = $2
 		WHERE article_id = $3
+		RETURNING id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
 	`
-	
-	result, err := r.db.Exec(ctx, query, quantity, time.Now(), articleID)
+
+	stock, err := r.scanStock(r.db.QueryRow(ctx, query, quantity, time.Now(), articleID))
 	if err != nil {
-		return fmt.Errorf("error updating stock quantity: %w", err)
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
+		}
+		return nil, fmt.Errorf("error incrementing stock quantity: %w", err)
 	}
 
-	if result.RowsAffected() == 0 {
-		return fmt.Errorf("stock not found for article_id: %s", articleID)
+	// Invalidar cache
+	r.invalidateStockCache(ctx, articleID)
+
+	return stock, nil
+}
+
+// SetQuantity fija la cantidad del artículo sin dejarla por debajo del stock reservado. El
+// llamador debe tener la fila bloqueada.
+func (r *StockRepository) SetQuantity(ctx context.Context, articleID string, quantity int) (*models.Stock, error) {
+	query := `
+		UPDATE stocks
+		SET quantity = $1, version = version + 1, updated_at = $2
+		WHERE article_id = $3 AND reserved <= $1
+		RETURNING id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
+	`
+
+	stock, err := r.scanStock(r.db.QueryRow(ctx, query, quantity, time.Now(), articleID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, &models.ErrCountReservationConflict{ArticleIDs: []string{articleID}}
+		}
+		return nil, fmt.Errorf("error setting stock quantity: %w", err)
 	}
 
 	// Invalidar cache
 	r.invalidateStockCache(ctx, articleID)
 
-	return nil
+	return stock, nil
+}
+
+// UpdateSettings actualiza el mínimo, el máximo y la ubicación del artículo. Retorna el
+// stock resultante.
+func (r *StockRepository) UpdateSettings(ctx context.Context, articleID string, minStock, maxStock int, location string) (*models.Stock, error) {
+	query := `
+		UPDATE stocks
+		SET min_stock = $1, max_stock = $2, location = $3, version = version + 1, updated_at = $4
+		WHERE article_id = $5
+		RETURNING id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
+	`
+
+	stock, err := r.scanStock(r.db.QueryRow(ctx, query, minStock, maxStock, location, time.Now(), articleID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
+		}
+		return nil, fmt.Errorf("error updating stock settings: %w", err)
+	}
+
+	// Invalidar cache
+	r.invalidateStockCache(ctx, articleID)
+
+	return stock, nil
 }
 
-// ReserveStock reserva una cantidad de stock
+// UpdateStatus cambia el estado del ciclo de vida del artículo. Retorna el stock resultante.
+func (r *StockRepository) UpdateStatus(ctx context.Context, articleID string, status models.StockStatus) (*models.Stock, error) {
+	query := `
+		UPDATE stocks
+		SET status = $1, version = version + 1, updated_at = $2
+		WHERE article_id = $3
+		RETURNING id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
+	`
+
+	stock, err := r.scanStock(r.db.QueryRow(ctx, query, status, time.Now(), articleID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
+		}
+		return nil, fmt.Errorf("error updating stock status: %w", err)
+	}
+
+	// Invalidar cache
+	r.invalidateStockCache(ctx, articleID)
+
+	return stock, nil
+}
+
+// DecrementQuantity descuenta una cantidad del stock disponible sin tocar el stock reservado
+func (r *StockRepository) DecrementQuantity(ctx context.Context, articleID string, quantity int) (*models.Stock, error) {
+	query := `
+		UPDATE stocks
+		SET quantity = quantity - $1, version = version + 1, updated_at = $2
+		WHERE article_id = $3 AND quantity - reserved >= $1
+		RETURNING id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
+	`
+
+	stock, err := r.scanStock(r.db.QueryRow(ctx, query, quantity, time.Now(), articleID))
+	if err == nil {
+		// Invalidar cache
+		r.invalidateStockCache(ctx, articleID)
+		return stock, nil
+	}
+
+	if err != pgx.ErrNoRows {
+		return nil, fmt.Errorf("error decrementing stock quantity: %w", err)
+	}
+
+	// Ninguna fila cumplió la condición: distinguir artículo inexistente de stock insuficiente
+	var available int
+	err = r.db.QueryRow(ctx,
+		"SELECT quantity - reserved FROM stocks WHERE article_id = $1",
+		articleID).Scan(&available)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
+		}
+		return nil, fmt.Errorf("error checking stock: %w", err)
+	}
+
+	return nil, &models.ErrInsufficientStock{ArticleID: articleID, Available: available, Requested: quantity}
+}
+
+// ReserveStock reserva una cantidad de stock. Falla si el estado del artículo no admite
+// reservas nuevas.
 func (r *StockRepository) ReserveStock(ctx context.Context, articleID string, quantity int) error {
 	tx, err := r.db.Begin(ctx)
 	if err != nil {
@@ -126,27 +290,32 @@ func (r *StockRepository) ReserveStock(ctx context.Context, articleID string, qu
 
 	// Verificar si hay suficiente stock disponible
 	var currentQuantity, reserved int
-	err = tx.QueryRow(ctx, 
-		"SELECT quantity, reserved FROM stocks WHERE article_id = $1 FOR UPDATE", 
-		articleID).Scan(&currentQuantity, &reserved)
-	
+	var status models.StockStatus
+	err = tx.QueryRow(ctx,
+		"SELECT quantity, reserved, status FROM stocks WHERE article_id = $1 FOR UPDATE",
+		articleID).Scan(&currentQuantity, &reserved, &status)
+
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return fmt.Errorf("stock not found for article_id: %s", articleID)
+			return fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
 		}
 		return fmt.Errorf("error checking stock: %w", err)
 	}
 
+	if !status.AllowsReservations() {
+		return &models.ErrArticleUnavailable{ArticleID: articleID, Status: status, Operation: "reservations"}
+	}
+
 	availableQuantity := currentQuantity - reserved
 	if availableQuantity < quantity {
-		return fmt.Errorf("insufficient stock: available %d, requested %d", availableQuantity, quantity)
+		return &models.ErrInsufficientStock{ArticleID: articleID, Available: availableQuantity, Requested: quantity}
 	}
 
 	// Actualizar stock reservado
 	_, err = tx.Exec(ctx,
-		"UPDATE stocks SET reserved = reserved + $1, updated_at = $2 WHERE article_id = $3",
+		"UPDATE stocks SET reserved = reserved + $1, version = version + 1, updated_at = $2 WHERE article_id = $3",
 		quantity, time.Now(), articleID)
-	
+
 	if err != nil {
 		return fmt.Errorf("error reserving stock: %w", err)
 	}
@@ -165,17 +334,17 @@ func (r *StockRepository) ReserveStock(ctx context.Context, articleID string, qu
 func (r *StockRepository) CancelReservation(ctx context.Context, articleID string, quantity int) error {
 	query := `
 		UPDATE stocks 
-		SET reserved = reserved - $1, updated_at = $2
+		SET reserved = reserved - $1, version = version + 1, updated_at = $2
 		WHERE article_id = $3 AND reserved >= $1
 	`
-	
+
 	result, err := r.db.Exec(ctx, query, quantity, time.Now(), articleID)
 	if err != nil {
 		return fmt.Errorf("error canceling reservation: %w", err)
 	}
 
 	if result.RowsAffected() == 0 {
-		return fmt.Errorf("insufficient reserved stock or stock not found for article_id: %s", articleID)
+		return fmt.Errorf("%w or article not found: %s", models.ErrInsufficientReservedStock, articleID)
 	}
 
 	// Invalidar cache
@@ -194,26 +363,26 @@ func (r *StockRepository) ConfirmReservation(ctx context.Context, articleID stri
 
 	// Verificar que hay suficiente stock reservado
 	var reserved int
-	err = tx.QueryRow(ctx, 
-		"SELECT reserved FROM stocks WHERE article_id = $1 FOR UPDATE", 
+	err = tx.QueryRow(ctx,
+		"SELECT reserved FROM stocks WHERE article_id = $1 FOR UPDATE",
 		articleID).Scan(&reserved)
-	
+
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			return fmt.Errorf("stock not found for article_id: %s", articleID)
+			return fmt.Errorf("%w: %s", models.ErrArticleNotFound, articleID)
 		}
 		return fmt.Errorf("error checking reserved stock: %w", err)
 	}
 
 	if reserved < quantity {
-		return fmt.Errorf("insufficient reserved stock: reserved %d, requested %d", reserved, quantity)
+		return fmt.Errorf("%w: reserved %d, requested %d", models.ErrInsufficientReservedStock, reserved, quantity)
 	}
 
 	// Descontar del stock y liberar la reserva
 	_, err = tx.Exec(ctx,
-		"UPDATE stocks SET quantity = quantity - $1, reserved = reserved - $1, updated_at = $2 WHERE article_id = $3",
+		"UPDATE stocks SET quantity = quantity - $1, reserved = reserved - $1, version = version + 1, updated_at = $2 WHERE article_id = $3",
 		quantity, time.Now(), articleID)
-	
+
 	if err != nil {
 		return fmt.Errorf("error confirming reservation: %w", err)
 	}
@@ -228,45 +397,240 @@ func (r *StockRepository) ConfirmReservation(ctx context.Context, articleID stri
 	return nil
 }
 
-// GetAllStocks obtiene todos los stocks
-func (r *StockRepository) GetAllStocks(ctx context.Context) ([]*models.Stock, error) {
+// LockStocksForUpdate bloquea los artículos en orden de article_id para evitar deadlocks; los
+// artículos inexistentes no aparecen en el mapa
+func (r *StockRepository) LockStocksForUpdate(ctx context.Context, articleIDs []string) (map[string]*models.Stock, error) {
 	query := `
-		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, created_at, updated_at
+		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
 		FROM stocks
-		ORDER BY created_at DESC
+		WHERE article_id = ANY($1)
+		ORDER BY article_id
+		FOR UPDATE
 	`
-	
-	rows, err := r.db.Query(ctx, query)
+
+	rows, err := r.db.Query(ctx, query, articleIDs)
 	if err != nil {
-		return nil, fmt.Errorf("error querying stocks: %w", err)
+		return nil, fmt.Errorf("error locking stocks: %w", err)
 	}
 	defer rows.Close()
 
-	var stocks []*models.Stock
+	stocks := make(map[string]*models.Stock, len(articleIDs))
 	for rows.Next() {
 		var stock models.Stock
 		err := rows.Scan(
 			&stock.ID, &stock.ArticleID, &stock.Quantity, &stock.Reserved,
-			&stock.MinStock, &stock.MaxStock, &stock.Location,
+			&stock.MinStock, &stock.MaxStock, &stock.Location, &stock.Status, &stock.Version,
 			&stock.CreatedAt, &stock.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning stock: %w", err)
 		}
-		stocks = append(stocks, &stock)
+		stocks[stock.ArticleID] = &stock
+	}
+
+	return stocks, rows.Err()
+}
+
+// IncrementReserved suma una cantidad al stock reservado sin superar la cantidad total
+func (r *StockRepository) IncrementReserved(ctx context.Context, articleID string, quantity int) error {
+	query := `
+		UPDATE stocks
+		SET reserved = reserved + $1, version = version + 1, updated_at = $2
+		WHERE article_id = $3 AND quantity - reserved >= $1
+	`
+
+	result, err := r.db.Exec(ctx, query, quantity, time.Now(), articleID)
+	if err != nil {
+		return fmt.Errorf("error reserving stock: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return &models.ErrInsufficientStock{ArticleID: articleID, Requested: quantity}
+	}
+
+	// Invalidar cache
+	r.invalidateStockCache(ctx, articleID)
+
+	return nil
+}
+
+// stockSortExpressions son las expresiones SQL de cada columna de orden del listado
+var stockSortExpressions = map[models.StockSortField]string{
+	models.StockSortCreatedAt: "created_at",
+	models.StockSortUpdatedAt: "updated_at",
+	models.StockSortQuantity:  "quantity",
+	models.StockSortReserved:  "reserved",
+	models.StockSortAvailable: "quantity - reserved",
+	models.StockSortMinStock:  "min_stock",
+	models.StockSortMaxStock:  "max_stock",
+}
+
+// ListStocks busca artículos según el filtro y el orden pedidos, paginando por (columna, article_id)
+func (r *StockRepository) ListStocks(ctx context.Context, filter models.StockFilter) ([]*models.Stock, error) {
+	sortExpr, ok := stockSortExpressions[filter.Sort]
+	if !ok {
+		sortExpr = stockSortExpressions[models.StockSortCreatedAt]
+	}
+	direction, comparison := "ASC", ">"
+	if filter.Desc {
+		direction, comparison = "DESC", "<"
+	}
+
+	conditions, args := stockFilterConditions(filter)
+	if filter.After != nil {
+		args = append(args, filter.After.Value, filter.After.ArticleID)
+		conditions = append(conditions,
+			fmt.Sprintf("(%s, article_id) %s ($%d, $%d)", sortExpr, comparison, len(args)-1, len(args)))
+	}
+
+	query := `
+		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
+		FROM stocks`
+	if len(conditions) > 0 {
+		query += `
+		WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(`
+		ORDER BY %s %s, article_id %s
+		LIMIT $%d`, sortExpr, direction, direction, len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying stocks: %w", err)
+	}
+	defer rows.Close()
+
+	stocks := []*models.Stock{}
+	for rows.Next() {
+		stock, err := r.scanStock(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning stock: %w", err)
+		}
+		stocks = append(stocks, stock)
+	}
+
+	return stocks, rows.Err()
+}
+
+// CountStocks retorna la cantidad de artículos que cumplen el filtro, sin considerar el cursor
+func (r *StockRepository) CountStocks(ctx context.Context, filter models.StockFilter) (int, error) {
+	conditions, args := stockFilterConditions(filter)
+
+	query := "SELECT COUNT(*) FROM stocks"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var count int
+	if err := r.db.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting stocks: %w", err)
 	}
 
-	return stocks, nil
+	return count, nil
 }
 
-// GetLowStocks obtiene stocks con cantidad baja
+// stockFilterConditions arma las condiciones del WHERE del listado y sus argumentos
+func stockFilterConditions(filter models.StockFilter) ([]string, []any) {
+	var conditions []string
+	var args []any
+
+	addCondition := func(condition string, values ...any) {
+		placeholders := make([]any, len(values))
+		for i, value := range values {
+			args = append(args, value)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf(condition, placeholders...))
+	}
+
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		addCondition("status = ANY(%s)", statuses)
+	} else {
+		addCondition("status <> %s", string(models.StockStatusArchived))
+	}
+	if filter.Location != "" {
+		addCondition("location = %s", filter.Location)
+	}
+	if filter.LowStock {
+		conditions = append(conditions, "quantity <= min_stock")
+	}
+	if filter.MinAvailable != nil {
+		addCondition("quantity - reserved >= %s", *filter.MinAvailable)
+	}
+	if filter.MaxAvailable != nil {
+		addCondition("quantity - reserved <= %s", *filter.MaxAvailable)
+	}
+	if filter.UpdatedSince != nil {
+		addCondition("updated_at >= %s", *filter.UpdatedSince)
+	}
+
+	return conditions, args
+}
+
+// ExportStocks llama a fn con cada artículo, incluidos los archivados, ordenados por article_id, y
+// retorna cuántos recorrió
+func (r *StockRepository) ExportStocks(ctx context.Context, fn func(*models.Stock) error) (int, error) {
+	query := `
+		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
+		FROM stocks
+		ORDER BY article_id
+	`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("error querying stocks: %w", err)
+	}
+	defer rows.Close()
+
+	exported := 0
+	for rows.Next() {
+		stock, err := r.scanStock(rows)
+		if err != nil {
+			return exported, fmt.Errorf("error scanning stock: %w", err)
+		}
+		if err := fn(stock); err != nil {
+			return exported, err
+		}
+		exported++
+	}
+
+	return exported, rows.Err()
+}
+
+// GetAllArticleIDs obtiene los article_id de todos los artículos, incluidos los archivados
+func (r *StockRepository) GetAllArticleIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, "SELECT article_id FROM stocks ORDER BY article_id")
+	if err != nil {
+		return nil, fmt.Errorf("error querying article ids: %w", err)
+	}
+	defer rows.Close()
+
+	var articleIDs []string
+	for rows.Next() {
+		var articleID string
+		if err := rows.Scan(&articleID); err != nil {
+			return nil, fmt.Errorf("error scanning article id: %w", err)
+		}
+		articleIDs = append(articleIDs, articleID)
+	}
+
+	return articleIDs, rows.Err()
+}
+
+// GetLowStocks obtiene stocks con cantidad baja, salvo los archivados
 func (r *StockRepository) GetLowStocks(ctx context.Context) ([]*models.Stock, error) {
 	query := `
-		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, created_at, updated_at
+		SELECT id, article_id, quantity, reserved, min_stock, max_stock, location, status, version, created_at, updated_at
 		FROM stocks
-		WHERE quantity <= min_stock
+		WHERE quantity <= min_stock AND status <> 'ARCHIVED'
 		ORDER BY (quantity - min_stock) ASC
 	`
-	
+
 	rows, err := r.db.Query(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("error querying low stocks: %w", err)
@@ -278,7 +642,7 @@ func (r *StockRepository) GetLowStocks(ctx context.Context) ([]*models.Stock, er
 		var stock models.Stock
 		err := rows.Scan(
 			&stock.ID, &stock.ArticleID, &stock.Quantity, &stock.Reserved,
-			&stock.MinStock, &stock.MaxStock, &stock.Location,
+			&stock.MinStock, &stock.MaxStock, &stock.Location, &stock.Status, &stock.Version,
 			&stock.CreatedAt, &stock.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning stock: %w", err)
@@ -286,10 +650,22 @@ func (r *StockRepository) GetLowStocks(ctx context.Context) ([]*models.Stock, er
 		stocks = append(stocks, &stock)
 	}
 
-	return stocks, nil
+	return stocks, rows.Err()
+}
+
+// scanStock lee una fila completa de stocks
+func (r *StockRepository) scanStock(row pgx.Row) (*models.Stock, error) {
+	var stock models.Stock
+	err := row.Scan(
+		&stock.ID, &stock.ArticleID, &stock.Quantity, &stock.Reserved,
+		&stock.MinStock, &stock.MaxStock, &stock.Location, &stock.Status, &stock.Version,
+		&stock.CreatedAt, &stock.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &stock, nil
 }
 
-// Métodos auxiliares para cache
 func (r *StockRepository) cacheStock(ctx context.Context, stock *models.Stock) {
 	if r.redis == nil {
 		return
@@ -304,11 +680,14 @@ func (r *StockRepository) cacheStock(ctx context.Context, stock *models.Stock) {
 	r.redis.Set(ctx, cacheKey, data, 5*time.Minute) // Cache por 5 minutos
 }
 
+// invalidateStockCache elimina el artículo de la caché; dentro de una transacción, después del commit
 func (r *StockRepository) invalidateStockCache(ctx context.Context, articleID string) {
 	if r.redis == nil {
 		return
 	}
 
 	cacheKey := fmt.Sprintf("stock:%s", articleID)
-	r.redis.Del(ctx, cacheKey)
-}
\ No newline at end of file
+	AfterCommit(r.db, func() {
+		r.redis.Del(context.WithoutCancel(ctx), cacheKey)
+	})
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// snapshotRunLockKey identifica el advisory lock que serializa las corridas de snapshots
+// entre réplicas
+const snapshotRunLockKey = "stock_snapshots"
+
+type StockSnapshotRepository struct {
+	db DBTX
+}
+
+func NewStockSnapshotRepository(db *pgxpool.Pool) *StockSnapshotRepository {
+	return &StockSnapshotRepository{
+		db: db,
+	}
+}
+
+// WithTx retorna una copia del repositorio que opera dentro de la transacción indicada
+func (r *StockSnapshotRepository) WithTx(tx pgx.Tx) *StockSnapshotRepository {
+	return &StockSnapshotRepository{
+		db: tx,
+	}
+}
+
+// TryLockSnapshotRun toma el advisory lock de la corrida de snapshots hasta el fin de la
+// transacción. Retorna false si otra réplica lo tiene; debe ejecutarse dentro de una transacción.
+func (r *StockSnapshotRepository) TryLockSnapshotRun(ctx context.Context) (bool, error) {
+	var locked bool
+	err := r.db.QueryRow(ctx,
+		"SELECT pg_try_advisory_xact_lock(hashtext($1))",
+		snapshotRunLockKey).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("error acquiring snapshot lock: %w", err)
+	}
+
+	return locked, nil
+}
+
+// GetLastSnapshotTime retorna el momento de la snapshot más reciente, o nil si no hay ninguna
+func (r *StockSnapshotRepository) GetLastSnapshotTime(ctx context.Context) (*time.Time, error) {
+	var takenAt *time.Time
+	err := r.db.QueryRow(ctx, "SELECT MAX(taken_at) FROM stock_snapshots").Scan(&takenAt)
+	if err != nil {
+		return nil, fmt.Errorf("error getting last snapshot time: %w", err)
+	}
+
+	return takenAt, nil
+}
+
+// GetLatestSnapshot obtiene la snapshot más reciente del artículo tomada hasta ts, o nil si no hay
+func (r *StockSnapshotRepository) GetLatestSnapshot(ctx context.Context, articleID string, ts time.Time) (*models.StockSnapshot, error) {
+	query := `
+		SELECT article_id, taken_at, quantity, reserved, created_at
+		FROM stock_snapshots
+		WHERE article_id = $1 AND taken_at <= $2
+		ORDER BY taken_at DESC
+		LIMIT 1
+	`
+
+	var snapshot models.StockSnapshot
+	err := r.db.QueryRow(ctx, query, articleID, ts).Scan(
+		&snapshot.ArticleID, &snapshot.TakenAt, &snapshot.Quantity, &snapshot.Reserved, &snapshot.CreatedAt)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting stock snapshot: %w", err)
+	}
+
+	return &snapshot, nil
+}
+
+// CreateSnapshot registra una snapshot; si ya existe una para el mismo artículo y momento no hace nada
+func (r *StockSnapshotRepository) CreateSnapshot(ctx context.Context, snapshot *models.StockSnapshot) error {
+	query := `
+		INSERT INTO stock_snapshots (article_id, taken_at, quantity, reserved, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (article_id, taken_at) DO NOTHING
+	`
+
+	snapshot.CreatedAt = time.Now()
+
+	_, err := r.db.Exec(ctx, query,
+		snapshot.ArticleID, snapshot.TakenAt, snapshot.Quantity, snapshot.Reserved, snapshot.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating stock snapshot: %w", err)
+	}
+
+	return nil
+}
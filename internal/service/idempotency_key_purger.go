@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// IdempotencyKeyPurger elimina periódicamente las claves de idempotencia vencidas. Las claves
+// vencidas ya no se usan para reintentos; el purgado solo acota el tamaño de la tabla.
+type IdempotencyKeyPurger struct {
+	idempotencyService *IdempotencyService
+	interval           time.Duration
+}
+
+func NewIdempotencyKeyPurger(idempotencyService *IdempotencyService, interval time.Duration) *IdempotencyKeyPurger {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+
+	return &IdempotencyKeyPurger{
+		idempotencyService: idempotencyService,
+		interval:           interval,
+	}
+}
+
+// Start inicia el purgado periódico en segundo plano hasta que se cancele el contexto
+func (p *IdempotencyKeyPurger) Start(ctx context.Context) {
+	log.Printf("IdempotencyKeyPurger: Started, purging every %s", p.interval)
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("IdempotencyKeyPurger: Context cancelled, stopping purger")
+				return
+			case <-ticker.C:
+				p.purge(ctx)
+			}
+		}
+	}()
+}
+
+// purge elimina las claves vencidas
+func (p *IdempotencyKeyPurger) purge(ctx context.Context) {
+	purged, err := p.idempotencyService.PurgeExpired(ctx)
+	if err != nil {
+		log.Printf("IdempotencyKeyPurger: Error purging expired keys: %v", err)
+		return
+	}
+
+	if purged > 0 {
+		log.Printf("IdempotencyKeyPurger: Purged %d expired key(s)", purged)
+	}
+}
@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// IdempotentHandler ejecuta la request y retorna la respuesta a registrar
+type IdempotentHandler func(ctx context.Context) (statusCode int, contentType string, body []byte, err error)
+
+// errIdempotentServerError hace rollback del registro cuando la respuesta es un error del
+// servidor, para que la request se pueda reintentar con la misma clave
+var errIdempotentServerError = errors.New("request failed with a server error")
+
+// IdempotencyService registra las respuestas de las requests con Idempotency-Key
+type IdempotencyService struct {
+	keyRepo   *repository.IdempotencyKeyRepository
+	txManager *repository.TxManager
+	ttl       time.Duration
+}
+
+func NewIdempotencyService(keyRepo *repository.IdempotencyKeyRepository, txManager *repository.TxManager, ttl time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+
+	return &IdempotencyService{
+		keyRepo:   keyRepo,
+		txManager: txManager,
+		ttl:       ttl,
+	}
+}
+
+// Execute ejecuta handle una única vez por scope y clave, en la misma transacción que el registro
+func (s *IdempotencyService) Execute(ctx context.Context, key *models.IdempotencyKey, handle IdempotentHandler) (*models.IdempotencyKey, error) {
+	now := time.Now()
+	key.CreatedAt = now
+	key.ExpiresAt = now.Add(s.ttl)
+
+	var entry *models.IdempotencyKey
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		keyRepo := s.keyRepo.WithTx(tx)
+
+		claimed, err := keyRepo.Claim(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if !claimed {
+			entry, err = keyRepo.GetIdempotencyKey(ctx, key.Scope, key.Key)
+			if err != nil {
+				return err
+			}
+			if entry == nil {
+				return fmt.Errorf("idempotency key %s not found after conflict", key.Key)
+			}
+			if entry.RequestHash != key.RequestHash {
+				return fmt.Errorf("%w: %s %s", models.ErrIdempotencyKeyReused, entry.Method, entry.Path)
+			}
+			entry.Replayed = true
+			return nil
+		}
+
+		statusCode, contentType, body, err := handle(repository.ContextWithTx(ctx, tx))
+		if err != nil {
+			return err
+		}
+
+		entry = key
+		entry.StatusCode = statusCode
+		entry.ContentType = contentType
+		entry.ResponseBody = body
+
+		if statusCode >= 500 {
+			return errIdempotentServerError
+		}
+
+		return keyRepo.Complete(ctx, entry)
+	})
+	if errors.Is(err, errIdempotentServerError) {
+		return entry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// ExecuteRecorded es como Execute pero ejecuta handle fuera de la transacción del registro
+func (s *IdempotencyService) ExecuteRecorded(ctx context.Context, key *models.IdempotencyKey, handle IdempotentHandler) (*models.IdempotencyKey, error) {
+	now := time.Now()
+	key.CreatedAt = now
+	key.ExpiresAt = now.Add(s.ttl)
+
+	claimed, err := s.keyRepo.Claim(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claimed {
+		entry, err := s.keyRepo.GetIdempotencyKey(ctx, key.Scope, key.Key)
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("idempotency key %s not found after conflict", key.Key)
+		}
+		if entry.RequestHash != key.RequestHash {
+			return nil, fmt.Errorf("%w: %s %s", models.ErrIdempotencyKeyReused, entry.Method, entry.Path)
+		}
+		if entry.StatusCode == 0 {
+			return nil, models.ErrIdempotencyKeyInProgress
+		}
+		entry.Replayed = true
+		return entry, nil
+	}
+
+	statusCode, contentType, body, err := handle(ctx)
+	if err != nil || statusCode >= 500 {
+		// Se libera la clave para que la request se pueda reintentar
+		if releaseErr := s.keyRepo.ReleaseClaim(context.WithoutCancel(ctx), key.Scope, key.Key); releaseErr != nil {
+			log.Printf("IdempotencyService: Error releasing key %s: %v", key.Key, releaseErr)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	key.StatusCode = statusCode
+	key.ContentType = contentType
+	key.ResponseBody = body
+	if statusCode >= 500 {
+		return key, nil
+	}
+
+	if err := s.keyRepo.Complete(context.WithoutCancel(ctx), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// PurgeExpired elimina las claves vencidas y retorna cuántas se eliminaron
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	return s.keyRepo.DeleteExpired(ctx, time.Now())
+}
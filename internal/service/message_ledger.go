@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// MessageHandler aplica un mensaje consumido y retorna el resultado a registrar.
+// Debe usar el contexto recibido para que sus transacciones se aniden en la del registro.
+type MessageHandler func(ctx context.Context) (any, error)
+
+// MessageLedger registra los mensajes procesados para que una reentrega no se vuelva a aplicar
+type MessageLedger struct {
+	processedRepo *repository.ProcessedMessageRepository
+	txManager     *repository.TxManager
+}
+
+func NewMessageLedger(processedRepo *repository.ProcessedMessageRepository, txManager *repository.TxManager) *MessageLedger {
+	return &MessageLedger{
+		processedRepo: processedRepo,
+		txManager:     txManager,
+	}
+}
+
+// ProcessOnce ejecuta handle una única vez por consumer y messageID. Un error recuperable hace
+// rollback para que el mensaje pueda reintentarse.
+func (l *MessageLedger) ProcessOnce(ctx context.Context, consumer, messageID string, recoverable func(error) bool, handle MessageHandler) (*models.ProcessedMessage, error) {
+	if messageID == "" {
+		return nil, fmt.Errorf("message id is required for idempotent processing")
+	}
+
+	var processed *models.ProcessedMessage
+	var handleErr error
+
+	err := l.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		processedRepo := l.processedRepo.WithTx(tx)
+
+		claimed, err := processedRepo.Claim(ctx, consumer, messageID)
+		if err != nil {
+			return err
+		}
+
+		if !claimed {
+			processed, err = processedRepo.GetProcessedMessage(ctx, consumer, messageID)
+			if err != nil {
+				return err
+			}
+			if processed == nil {
+				return fmt.Errorf("processed message %s/%s not found after conflict", consumer, messageID)
+			}
+			processed.Duplicate = true
+			return nil
+		}
+
+		result, err := handle(repository.ContextWithTx(ctx, tx))
+		if err != nil && recoverable(err) {
+			return err
+		}
+		handleErr = err
+
+		processed = &models.ProcessedMessage{
+			Consumer:  consumer,
+			MessageID: messageID,
+			Outcome:   models.ProcessedOutcomeSucceeded,
+		}
+
+		if result != nil {
+			body, err := json.Marshal(result)
+			if err != nil {
+				return fmt.Errorf("error encoding processed message result: %w", err)
+			}
+			processed.Result = body
+		}
+
+		if handleErr != nil {
+			errText := handleErr.Error()
+			processed.Outcome = models.ProcessedOutcomeRejected
+			processed.Error = &errText
+		}
+
+		return processedRepo.Complete(ctx, processed)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return processed, handleErr
+}
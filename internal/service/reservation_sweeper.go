@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+)
+
+// reservationSweeperActor identifica al barrido como actor de los eventos EXPIRE_RESERVE
+const reservationSweeperActor = "reservation-sweeper"
+
+// ReservationSweeper libera periódicamente las reservas cuyo TTL venció
+type ReservationSweeper struct {
+	stockService *StockService
+	interval     time.Duration
+	batchSize    int
+}
+
+func NewReservationSweeper(stockService *StockService, interval time.Duration, batchSize int) *ReservationSweeper {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	return &ReservationSweeper{
+		stockService: stockService,
+		interval:     interval,
+		batchSize:    batchSize,
+	}
+}
+
+// Start inicia el barrido periódico en segundo plano hasta que se cancele el contexto
+func (s *ReservationSweeper) Start(ctx context.Context) {
+	log.Printf("ReservationSweeper: Started, sweeping every %s", s.interval)
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("ReservationSweeper: Context cancelled, stopping sweeper")
+				return
+			case <-ticker.C:
+				s.sweep(ctx)
+			}
+		}
+	}()
+}
+
+// sweep procesa lotes de reservas vencidas hasta que no queden lotes completos
+func (s *ReservationSweeper) sweep(ctx context.Context) {
+	ctx = models.ContextWithAttribution(ctx, models.Attribution{
+		ActorType: models.ActorTypeSystem,
+		ActorID:   reservationSweeperActor,
+	})
+
+	for {
+		expired, err := s.stockService.ExpireReservations(ctx, s.batchSize)
+		if err != nil {
+			log.Printf("ReservationSweeper: Error expiring reservations: %v", err)
+			return
+		}
+
+		for _, reservation := range expired {
+			log.Printf("ReservationSweeper: Expired reservation %s (order %s, article %s, quantity %d)",
+				reservation.ID, reservation.OrderID, reservation.ArticleID, reservation.Quantity)
+		}
+
+		if len(expired) < s.batchSize {
+			return
+		}
+	}
+}
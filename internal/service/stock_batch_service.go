@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// StockBatchService ejecuta lotes de movimientos de stock en una sola transacción
+type StockBatchService struct {
+	stockService *StockService
+	stockRepo    *repository.StockRepository
+	txManager    *repository.TxManager
+}
+
+func NewStockBatchService(
+	stockService *StockService,
+	stockRepo *repository.StockRepository,
+	txManager *repository.TxManager,
+) *StockBatchService {
+	return &StockBatchService{
+		stockService: stockService,
+		stockRepo:    stockRepo,
+		txManager:    txManager,
+	}
+}
+
+// Execute ejecuta las operaciones del lote en orden según su modo, all_or_nothing o best_effort
+func (s *StockBatchService) Execute(ctx context.Context, req *models.StockBatchRequest) (*models.StockBatchResult, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = models.BatchModeAllOrNothing
+	}
+	if !mode.IsValid() {
+		return nil, fmt.Errorf("%w: mode must be all_or_nothing or best_effort", models.ErrInvalidBatch)
+	}
+
+	switch {
+	case len(req.Operations) == 0:
+		return nil, fmt.Errorf("%w: operations is required", models.ErrInvalidBatch)
+	case len(req.Operations) > models.MaxBatchOperations:
+		return nil, fmt.Errorf("%w: batch cannot have more than %d operations", models.ErrInvalidBatch, models.MaxBatchOperations)
+	}
+
+	result := &models.StockBatchResult{
+		BatchID:    uuid.New(),
+		Mode:       mode,
+		Operations: make([]models.StockBatchOperationResult, len(req.Operations)),
+	}
+	for i := range req.Operations {
+		op := &req.Operations[i]
+		if err := op.Validate(); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", models.ErrInvalidBatch, i, err)
+		}
+		result.Operations[i] = models.StockBatchOperationResult{
+			Index:     i,
+			Type:      op.Type,
+			ArticleID: op.ArticleID,
+			OrderID:   op.OrderID,
+			Status:    models.BatchStatusSkipped,
+		}
+	}
+
+	attribution := models.AttributionFromContext(ctx)
+	attribution.CausationID = result.BatchID.String()
+	ctx = models.ContextWithAttribution(ctx, attribution)
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		// Las operaciones de StockService reutilizan esta transacción mediante savepoints
+		txCtx := repository.ContextWithTx(ctx, tx)
+
+		return runBatchOperations(result, func(i int) (*models.Stock, error) {
+			return s.apply(txCtx, tx, &req.Operations[i])
+		})
+	})
+	if err != nil {
+		markBatchRolledBack(result, err)
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// runBatchOperations aplica cada operación con apply y registra su resultado. En modo
+// all_or_nothing se detiene en la primera que falla y retorna ErrBatchOperationFailed.
+func runBatchOperations(result *models.StockBatchResult, apply func(i int) (*models.Stock, error)) error {
+	for i := range result.Operations {
+		entry := &result.Operations[i]
+
+		stock, err := apply(i)
+		if err != nil {
+			entry.Status = models.BatchStatusFailed
+			entry.Err = err
+			result.Failed++
+
+			if result.Mode == models.BatchModeAllOrNothing {
+				return &models.ErrBatchOperationFailed{Index: i, Err: err, Result: result}
+			}
+			continue
+		}
+
+		entry.Status = models.BatchStatusApplied
+		entry.Stock = stock
+		result.Applied++
+	}
+	return nil
+}
+
+// markBatchRolledBack marca como deshechas las operaciones aplicadas antes de la que hizo
+// fallar el lote
+func markBatchRolledBack(result *models.StockBatchResult, err error) {
+	var failed *models.ErrBatchOperationFailed
+	if !errors.As(err, &failed) {
+		return
+	}
+	for i := 0; i < failed.Index; i++ {
+		result.Operations[i].Status = models.BatchStatusRolledBack
+		result.Operations[i].Stock = nil
+	}
+	result.Applied = 0
+}
+
+// apply ejecuta una operación del lote y retorna el stock del artículo después de aplicarla
+func (s *StockBatchService) apply(ctx context.Context, tx pgx.Tx, op *models.StockBatchOperation) (*models.Stock, error) {
+	var err error
+	switch op.Type {
+	case models.BatchOperationReplenish:
+		reason := op.Reason
+		if reason == "" {
+			reason = "Stock replenishment"
+		}
+		return s.stockService.ReplenishStock(ctx, op.ArticleID, op.Quantity, reason)
+
+	case models.BatchOperationDeduct:
+		reason := op.Reason
+		if reason == "" {
+			reason = "Manual stock deduction"
+		}
+		return s.stockService.DeductStock(ctx, op.ArticleID, op.Quantity, reason)
+
+	case models.BatchOperationReserve:
+		err = s.stockService.ReserveStock(ctx, &models.ReserveStockRequest{
+			ArticleID: op.ArticleID,
+			Quantity:  op.Quantity,
+			OrderID:   op.OrderID,
+		})
+
+	case models.BatchOperationCancel:
+		err = s.stockService.CancelReservationByOrderID(ctx, op.OrderID, op.ArticleID, op.Reason)
+
+	case models.BatchOperationConfirm:
+		err = s.stockService.ConfirmReservationByOrderID(ctx, op.OrderID, op.ArticleID, op.Reason)
+
+	default:
+		return nil, fmt.Errorf("%w: unknown operation type %q", models.ErrInvalidBatch, op.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Se lee dentro de la transacción: la caché no conoce los cambios aún sin confirmar
+	return s.stockRepo.WithTx(tx).GetStockForUpdate(ctx, op.ArticleID)
+}
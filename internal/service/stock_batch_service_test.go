@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+)
+
+func TestStockBatchServiceExecuteValidation(t *testing.T) {
+	replenish := models.StockBatchOperation{Type: models.BatchOperationReplenish, ArticleID: "A1", Quantity: 1}
+
+	tests := []struct {
+		name string
+		req  models.StockBatchRequest
+	}{
+		{name: "unknown mode", req: models.StockBatchRequest{Mode: "partial", Operations: []models.StockBatchOperation{replenish}}},
+		{name: "no operations", req: models.StockBatchRequest{}},
+		{name: "too many operations", req: models.StockBatchRequest{Operations: make([]models.StockBatchOperation, models.MaxBatchOperations+1)}},
+		{name: "unknown operation type", req: models.StockBatchRequest{Operations: []models.StockBatchOperation{{Type: "move", ArticleID: "A1"}}}},
+		{name: "missing quantity", req: models.StockBatchRequest{Operations: []models.StockBatchOperation{{Type: models.BatchOperationDeduct, ArticleID: "A1"}}}},
+		{name: "missing order_id", req: models.StockBatchRequest{Operations: []models.StockBatchOperation{replenish, {Type: models.BatchOperationConfirm, ArticleID: "A1"}}}},
+	}
+
+	// La validación ocurre antes de abrir la transacción
+	service := NewStockBatchService(nil, nil, nil)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Execute(context.Background(), &tt.req)
+			if !errors.Is(err, models.ErrInvalidBatch) {
+				t.Fatalf("expected ErrInvalidBatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRunBatchOperations(t *testing.T) {
+	errShort := errors.New("insufficient stock")
+
+	tests := []struct {
+		name         string
+		mode         models.StockBatchMode
+		failing      map[int]bool
+		wantStatus   []models.StockBatchOperationStatus
+		wantApplied  int
+		wantFailed   int
+		wantFailedAt int // -1 si el lote no debe fallar
+	}{
+		{
+			name:         "all_or_nothing without failures",
+			mode:         models.BatchModeAllOrNothing,
+			wantStatus:   []models.StockBatchOperationStatus{models.BatchStatusApplied, models.BatchStatusApplied, models.BatchStatusApplied, models.BatchStatusApplied},
+			wantApplied:  4,
+			wantFailedAt: -1,
+		},
+		{
+			name:         "all_or_nothing rolls back the applied operations",
+			mode:         models.BatchModeAllOrNothing,
+			failing:      map[int]bool{2: true, 3: true},
+			wantStatus:   []models.StockBatchOperationStatus{models.BatchStatusRolledBack, models.BatchStatusRolledBack, models.BatchStatusFailed, models.BatchStatusSkipped},
+			wantFailed:   1,
+			wantFailedAt: 2,
+		},
+		{
+			name:         "all_or_nothing failing on the first operation",
+			mode:         models.BatchModeAllOrNothing,
+			failing:      map[int]bool{0: true},
+			wantStatus:   []models.StockBatchOperationStatus{models.BatchStatusFailed, models.BatchStatusSkipped, models.BatchStatusSkipped, models.BatchStatusSkipped},
+			wantFailed:   1,
+			wantFailedAt: 0,
+		},
+		{
+			name:         "best_effort keeps the other operations",
+			mode:         models.BatchModeBestEffort,
+			failing:      map[int]bool{1: true, 3: true},
+			wantStatus:   []models.StockBatchOperationStatus{models.BatchStatusApplied, models.BatchStatusFailed, models.BatchStatusApplied, models.BatchStatusFailed},
+			wantApplied:  2,
+			wantFailed:   2,
+			wantFailedAt: -1,
+		},
+		{
+			name:         "best_effort with every operation failing",
+			mode:         models.BatchModeBestEffort,
+			failing:      map[int]bool{0: true, 1: true, 2: true, 3: true},
+			wantStatus:   []models.StockBatchOperationStatus{models.BatchStatusFailed, models.BatchStatusFailed, models.BatchStatusFailed, models.BatchStatusFailed},
+			wantFailed:   4,
+			wantFailedAt: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &models.StockBatchResult{
+				Mode:       tt.mode,
+				Operations: make([]models.StockBatchOperationResult, len(tt.wantStatus)),
+			}
+			for i := range result.Operations {
+				result.Operations[i] = models.StockBatchOperationResult{Index: i, Status: models.BatchStatusSkipped}
+			}
+
+			err := runBatchOperations(result, func(i int) (*models.Stock, error) {
+				if tt.failing[i] {
+					return nil, errShort
+				}
+				return &models.Stock{ArticleID: "A1", Quantity: i}, nil
+			})
+			if err != nil {
+				markBatchRolledBack(result, err)
+			}
+
+			if tt.wantFailedAt < 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else {
+				var failed *models.ErrBatchOperationFailed
+				if !errors.As(err, &failed) {
+					t.Fatalf("expected ErrBatchOperationFailed, got %v", err)
+				}
+				if failed.Index != tt.wantFailedAt {
+					t.Errorf("expected failure at operation %d, got %d", tt.wantFailedAt, failed.Index)
+				}
+				if !errors.Is(err, errShort) {
+					t.Errorf("expected the operation error to be wrapped, got %v", err)
+				}
+				if failed.Result != result {
+					t.Error("expected the error to carry the batch result")
+				}
+			}
+
+			if result.Applied != tt.wantApplied {
+				t.Errorf("expected %d applied, got %d", tt.wantApplied, result.Applied)
+			}
+			if result.Failed != tt.wantFailed {
+				t.Errorf("expected %d failed, got %d", tt.wantFailed, result.Failed)
+			}
+			for i, entry := range result.Operations {
+				if entry.Status != tt.wantStatus[i] {
+					t.Errorf("operation %d: expected status %s, got %s", i, tt.wantStatus[i], entry.Status)
+				}
+				if applied := entry.Status == models.BatchStatusApplied; applied != (entry.Stock != nil) {
+					t.Errorf("operation %d: status %s with stock %v", i, entry.Status, entry.Stock)
+				}
+				if failed := entry.Status == models.BatchStatusFailed; failed != (entry.Err != nil) {
+					t.Errorf("operation %d: status %s with error %v", i, entry.Status, entry.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestMarkBatchRolledBackIgnoresOtherErrors(t *testing.T) {
+	result := &models.StockBatchResult{
+		Mode:       models.BatchModeAllOrNothing,
+		Applied:    1,
+		Operations: []models.StockBatchOperationResult{{Status: models.BatchStatusApplied, Stock: &models.Stock{}}},
+	}
+
+	markBatchRolledBack(result, errors.New("commit failed"))
+
+	if result.Applied != 1 || result.Operations[0].Status != models.BatchStatusApplied {
+		t.Errorf("expected the result to stay unchanged, got %+v", result)
+	}
+}
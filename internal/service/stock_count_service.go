@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// StockCountService administra los recuentos físicos de stock por ubicación
+type StockCountService struct {
+	stockService *StockService
+	stockRepo    *repository.StockRepository
+	countRepo    *repository.StockCountRepository
+	txManager    *repository.TxManager
+}
+
+func NewStockCountService(
+	stockService *StockService,
+	stockRepo *repository.StockRepository,
+	countRepo *repository.StockCountRepository,
+	txManager *repository.TxManager,
+) *StockCountService {
+	return &StockCountService{
+		stockService: stockService,
+		stockRepo:    stockRepo,
+		countRepo:    countRepo,
+		txManager:    txManager,
+	}
+}
+
+// OpenCount abre una sesión de recuento para la ubicación. Retorna ErrStockCountAlreadyOpen si
+// la ubicación ya tiene una sesión abierta.
+func (s *StockCountService) OpenCount(ctx context.Context, req *models.OpenStockCountRequest) (*models.StockCountSession, error) {
+	location := strings.TrimSpace(req.Location)
+	if location == "" {
+		return nil, fmt.Errorf("%w: location is required", models.ErrInvalidStockCount)
+	}
+
+	session := &models.StockCountSession{
+		Location: location,
+		Notes:    req.Notes,
+		OpenedBy: actorName(ctx),
+	}
+	if err := s.countRepo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// SubmitCounts carga las cantidades contadas en una sesión abierta; volver a cargar un artículo
+// reemplaza su cantidad
+func (s *StockCountService) SubmitCounts(ctx context.Context, sessionID uuid.UUID, items []models.StockCountItem) (*models.StockCountReport, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: at least one item is required", models.ErrInvalidStockCount)
+	}
+
+	articleIDs := make([]string, 0, len(items))
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if item.ArticleID == "" {
+			return nil, fmt.Errorf("%w: article_id is required", models.ErrInvalidStockCount)
+		}
+		if item.CountedQuantity == nil || *item.CountedQuantity < 0 {
+			return nil, fmt.Errorf("%w: counted_quantity must be 0 or greater for article %s", models.ErrInvalidStockCount, item.ArticleID)
+		}
+		if seen[item.ArticleID] {
+			return nil, fmt.Errorf("%w: article %s is repeated", models.ErrInvalidStockCount, item.ArticleID)
+		}
+		seen[item.ArticleID] = true
+		articleIDs = append(articleIDs, item.ArticleID)
+	}
+
+	countedBy := actorName(ctx)
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		countRepo := s.countRepo.WithTx(tx)
+
+		session, err := countRepo.GetSessionForUpdate(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.Status != models.StockCountStatusOpen {
+			return fmt.Errorf("%w: %s", models.ErrStockCountClosed, session.Status)
+		}
+
+		locations, err := countRepo.GetArticleLocations(ctx, articleIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			location, ok := locations[item.ArticleID]
+			if !ok {
+				return fmt.Errorf("%w: %s", models.ErrArticleNotFound, item.ArticleID)
+			}
+			if location != session.Location {
+				return fmt.Errorf("%w: article %s is not in location %s", models.ErrInvalidStockCount, item.ArticleID, session.Location)
+			}
+
+			line := &models.StockCountLine{
+				SessionID:       sessionID,
+				ArticleID:       item.ArticleID,
+				CountedQuantity: *item.CountedQuantity,
+				Reason:          item.Reason,
+				CountedBy:       countedBy,
+			}
+			if err := countRepo.UpsertLine(ctx, line); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetReport(ctx, sessionID)
+}
+
+// GetReport obtiene la sesión con sus líneas y, si está abierta, los artículos aún no contados
+func (s *StockCountService) GetReport(ctx context.Context, sessionID uuid.UUID) (*models.StockCountReport, error) {
+	session, err := s.countRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := s.countRepo.GetLines(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.StockCountReport{Session: session, Lines: lines}
+	if session.Status != models.StockCountStatusOpen {
+		return report, nil
+	}
+
+	for _, line := range lines {
+		line.ReservationConflict = line.CurrentQuantity != nil && line.Reserved != nil &&
+			countTarget(line, *line.CurrentQuantity) < *line.Reserved
+	}
+
+	report.Uncounted, err = s.countRepo.GetUncountedArticleIDs(ctx, sessionID, session.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// PostCount aplica las diferencias del recuento sobre el stock vigente con eventos ADJUST, sin
+// dejar nunca reserved > quantity
+func (s *StockCountService) PostCount(ctx context.Context, sessionID uuid.UUID, policy models.StockCountConflictPolicy) (*models.StockCountReport, error) {
+	if policy == "" {
+		policy = models.StockCountConflictReject
+	}
+	if !policy.IsValid() {
+		return nil, fmt.Errorf("%w: unknown reservation conflict policy %q", models.ErrInvalidStockCount, policy)
+	}
+
+	var report *models.StockCountReport
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		countRepo := s.countRepo.WithTx(tx)
+		stockRepo := s.stockRepo.WithTx(tx)
+
+		session, err := countRepo.GetSessionForUpdate(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.Status != models.StockCountStatusOpen {
+			return fmt.Errorf("%w: %s", models.ErrStockCountClosed, session.Status)
+		}
+
+		lines, err := countRepo.GetLines(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return fmt.Errorf("%w: no counted articles to post", models.ErrInvalidStockCount)
+		}
+
+		articleIDs := make([]string, len(lines))
+		for i, line := range lines {
+			articleIDs[i] = line.ArticleID
+		}
+
+		stocks, err := stockRepo.LockStocksForUpdate(ctx, articleIDs)
+		if err != nil {
+			return err
+		}
+
+		var conflicts []string
+		for _, line := range lines {
+			stock, ok := stocks[line.ArticleID]
+			if !ok {
+				return fmt.Errorf("%w: %s", models.ErrArticleNotFound, line.ArticleID)
+			}
+			if countTarget(line, stock.Quantity) < stock.Reserved {
+				conflicts = append(conflicts, line.ArticleID)
+			}
+		}
+		if len(conflicts) > 0 && policy == models.StockCountConflictReject {
+			return &models.ErrCountReservationConflict{ArticleIDs: conflicts}
+		}
+
+		for _, line := range lines {
+			if err := s.postLine(ctx, tx, session, line, stocks[line.ArticleID]); err != nil {
+				return err
+			}
+		}
+
+		session.ClosedBy = actorName(ctx)
+		if err := countRepo.CloseSession(ctx, session, models.StockCountStatusPosted); err != nil {
+			return err
+		}
+
+		report = &models.StockCountReport{Session: session, Lines: lines}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// postLine aplica la diferencia de la línea sobre el stock vigente y registra el resultado en
+// la línea. El stock debe estar bloqueado por el llamador.
+func (s *StockCountService) postLine(ctx context.Context, tx pgx.Tx, session *models.StockCountSession, line *models.StockCountLine, stock *models.Stock) error {
+	expected := stock.Quantity
+	if line.ExpectedQuantity != nil {
+		expected = *line.ExpectedQuantity
+	}
+	variance := line.CountedQuantity - expected
+	before := stock.Quantity
+	target := before + variance
+	line.ExpectedQuantity = &expected
+	line.Variance = &variance
+	line.CurrentQuantity = nil
+	line.Reserved = nil
+
+	switch {
+	case target < stock.Reserved:
+		line.Status = models.StockCountLineSkipped
+	case variance == 0:
+		line.Status = models.StockCountLineUnchanged
+	default:
+		updated, err := s.stockRepo.WithTx(tx).SetQuantity(ctx, line.ArticleID, target)
+		if err != nil {
+			return err
+		}
+
+		metadata, err := json.Marshal(map[string]interface{}{
+			"stock_count_id":    session.ID.String(),
+			"location":          session.Location,
+			"counted_quantity":  line.CountedQuantity,
+			"expected_quantity": expected,
+			"variance":          variance,
+			"posted_quantity":   target,
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding stock count adjustment: %w", err)
+		}
+
+		reason := line.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("Ajuste por recuento en %s: diferencia %+d", session.Location, variance)
+		}
+
+		attribution := models.AttributionFromContext(ctx)
+		attribution.CausationID = session.ID.String()
+
+		event := &models.StockEvent{
+			ArticleID:      line.ArticleID,
+			EventType:      models.EventTypeAdjust,
+			Quantity:       abs(variance),
+			Reason:         reason,
+			Metadata:       string(metadata),
+			QuantityBefore: &before,
+			QuantityAfter:  &updated.Quantity,
+			ReservedBefore: &stock.Reserved,
+			ReservedAfter:  &updated.Reserved,
+			Attribution:    attribution,
+		}
+		if err := s.stockService.recordEvent(ctx, tx, event); err != nil {
+			return err
+		}
+
+		// Un faltante puede dejar el artículo por debajo del mínimo
+		if variance < 0 {
+			if err := s.stockService.enqueueLowStockAlert(ctx, tx, line.ArticleID); err != nil {
+				return err
+			}
+		}
+
+		line.Status = models.StockCountLineAdjusted
+		line.AdjustEventID = &event.ID
+	}
+
+	return s.countRepo.WithTx(tx).UpdateLineResult(ctx, line)
+}
+
+// countTarget retorna la cantidad que dejaría la línea al aplicar su diferencia sobre quantity
+func countTarget(line *models.StockCountLine, quantity int) int {
+	if line.Variance == nil {
+		return line.CountedQuantity
+	}
+	return quantity + *line.Variance
+}
+
+// CancelCount cancela una sesión abierta sin ajustar el stock
+func (s *StockCountService) CancelCount(ctx context.Context, sessionID uuid.UUID) (*models.StockCountSession, error) {
+	var session *models.StockCountSession
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		countRepo := s.countRepo.WithTx(tx)
+
+		var err error
+		session, err = countRepo.GetSessionForUpdate(ctx, sessionID)
+		if err != nil {
+			return err
+		}
+		if session.Status != models.StockCountStatusOpen {
+			return fmt.Errorf("%w: %s", models.ErrStockCountClosed, session.Status)
+		}
+
+		session.ClosedBy = actorName(ctx)
+		return countRepo.CloseSession(ctx, session, models.StockCountStatusCancelled)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// actorName identifica al actor del contexto para registrarlo en la sesión: su ID o, si no
+// lo tiene, su tipo
+func actorName(ctx context.Context) string {
+	attribution := models.AttributionFromContext(ctx)
+	if attribution.ActorID != "" {
+		return attribution.ActorID
+	}
+	return string(attribution.ActorType)
+}
@@ -0,0 +1,413 @@
+package service
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// archiveFileSuffix es la extensión de los archivos de eventos: NDJSON comprimido con gzip
+const archiveFileSuffix = ".ndjson.gz"
+
+// restoreBatchSize es la cantidad de eventos que se cargan por COPY al restaurar un archivo
+const restoreBatchSize = 1000
+
+// StockEventArchiveService crea y archiva las particiones mensuales de stock_events
+type StockEventArchiveService struct {
+	partitionRepo  *repository.StockEventPartitionRepository
+	historyService *StockHistoryService
+	txManager      *repository.TxManager
+	archiveDir     string
+}
+
+func NewStockEventArchiveService(
+	partitionRepo *repository.StockEventPartitionRepository,
+	historyService *StockHistoryService,
+	txManager *repository.TxManager,
+	archiveDir string,
+) *StockEventArchiveService {
+	return &StockEventArchiveService{
+		partitionRepo:  partitionRepo,
+		historyService: historyService,
+		txManager:      txManager,
+		archiveDir:     archiveDir,
+	}
+}
+
+// EnsurePartitions crea, si faltan, las particiones del mes de now y de los ahead meses siguientes
+func (s *StockEventArchiveService) EnsurePartitions(ctx context.Context, now time.Time, ahead int) error {
+	now = now.UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i <= ahead; i++ {
+		if _, err := s.partitionRepo.EnsurePartition(ctx, month.AddDate(0, i, 0)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ArchiveExpired archiva, de la más antigua a la más reciente, las particiones cuyo rango terminó
+// hasta cutoff
+func (s *StockEventArchiveService) ArchiveExpired(ctx context.Context, cutoff time.Time) ([]*models.StockEventPartition, error) {
+	partitions, err := s.partitionRepo.GetPartitionsToArchive(ctx, cutoff)
+	if err != nil {
+		return nil, err
+	}
+
+	var archived []*models.StockEventPartition
+	for _, partition := range partitions {
+		done, err := s.archivePartition(ctx, partition)
+		if err != nil {
+			return archived, err
+		}
+		if done {
+			archived = append(archived, partition)
+		}
+	}
+
+	return archived, nil
+}
+
+// archivePartition exporta y separa la partición y elimina su tabla una vez verificado el archivo
+func (s *StockEventArchiveService) archivePartition(ctx context.Context, partition *models.StockEventPartition) (bool, error) {
+	if partition.Status == models.PartitionStatusAttached {
+		if err := s.detachPartition(ctx, partition); err != nil {
+			if err == models.ErrArchiveInProgress {
+				return false, err
+			}
+			return false, fmt.Errorf("error archiving partition %s: %w", partition.Name, err)
+		}
+	}
+
+	archived, err := s.dropVerifiedPartition(ctx, partition)
+	if err != nil {
+		if err == models.ErrArchiveInProgress {
+			return false, err
+		}
+		return false, fmt.Errorf("error archiving partition %s: %w", partition.Name, err)
+	}
+
+	return archived, nil
+}
+
+// detachPartition exporta la partición a su archivo y la separa de stock_events
+func (s *StockEventArchiveService) detachPartition(ctx context.Context, partition *models.StockEventPartition) error {
+	return s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		partitionRepo := s.partitionRepo.WithTx(tx)
+
+		locked, err := partitionRepo.TryLockArchiveRun(ctx)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return models.ErrArchiveInProgress
+		}
+
+		current, err := partitionRepo.GetPartition(ctx, partition.Name)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Status != models.PartitionStatusAttached {
+			return nil
+		}
+
+		// Las consultas históricas posteriores al fin del rango parten de esta snapshot
+		if _, err := s.historyService.TakeSnapshots(repository.ContextWithTx(ctx, tx), partition.RangeEnd, 0); err != nil {
+			return err
+		}
+
+		path := filepath.Join(s.archiveDir, partition.Name+archiveFileSuffix)
+		rows, checksum, err := writeArchive(ctx, partitionRepo, partition.Name, path)
+		if err != nil {
+			return err
+		}
+
+		count, err := partitionRepo.CountPartitionRows(ctx, partition.Name)
+		if err != nil {
+			return err
+		}
+		if count != rows {
+			return fmt.Errorf("partition %s changed while archiving: exported %d rows, found %d", partition.Name, rows, count)
+		}
+
+		if err := partitionRepo.AccumulateArchivedBalances(ctx, partition.Name); err != nil {
+			return err
+		}
+		if err := partitionRepo.DetachPartition(ctx, partition.Name); err != nil {
+			return err
+		}
+
+		partition.ArchivePath = &path
+		partition.ArchivedRows = &rows
+		partition.ArchiveSHA256 = &checksum
+		return partitionRepo.MarkDetached(ctx, partition)
+	})
+}
+
+// dropVerifiedPartition elimina la tabla de una partición separada si su archivo coincide con lo
+// registrado
+func (s *StockEventArchiveService) dropVerifiedPartition(ctx context.Context, partition *models.StockEventPartition) (bool, error) {
+	archived := false
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		partitionRepo := s.partitionRepo.WithTx(tx)
+
+		locked, err := partitionRepo.TryLockArchiveRun(ctx)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return models.ErrArchiveInProgress
+		}
+
+		current, err := partitionRepo.GetPartition(ctx, partition.Name)
+		if err != nil {
+			return err
+		}
+		if current == nil || current.Status != models.PartitionStatusDetached {
+			return nil
+		}
+
+		path := filepath.Join(s.archiveDir, current.Name+archiveFileSuffix)
+		if current.ArchivePath != nil {
+			path = *current.ArchivePath
+		}
+
+		if err := verifyArchive(path, current); err != nil {
+			rows, checksum, err := writeArchive(ctx, partitionRepo, current.Name, path)
+			if err != nil {
+				return err
+			}
+
+			count, err := partitionRepo.CountPartitionRows(ctx, current.Name)
+			if err != nil {
+				return err
+			}
+			if count != rows {
+				return fmt.Errorf("detached partition %s has %d rows, exported %d", current.Name, count, rows)
+			}
+
+			current.ArchivePath = &path
+			current.ArchivedRows = &rows
+			current.ArchiveSHA256 = &checksum
+			if err := verifyArchive(path, current); err != nil {
+				return err
+			}
+		}
+
+		if err := partitionRepo.DropDetachedPartition(ctx, current.Name); err != nil {
+			return err
+		}
+		if err := partitionRepo.MarkArchived(ctx, current); err != nil {
+			return err
+		}
+
+		*partition = *current
+		archived = true
+		return nil
+	})
+
+	return archived, err
+}
+
+// RestoreArchive carga un archivo de eventos en una tabla independiente y retorna su nombre y la
+// cantidad de eventos
+func (s *StockEventArchiveService) RestoreArchive(ctx context.Context, path, table string) (string, int64, error) {
+	name := strings.TrimSuffix(filepath.Base(path), archiveFileSuffix)
+	if table == "" {
+		table = strings.Replace(name, "stock_events_", "stock_events_restored_", 1)
+		if table == name {
+			table = name + "_restored"
+		}
+	}
+
+	partition, err := s.partitionRepo.GetPartition(ctx, name)
+	if err != nil {
+		return "", 0, err
+	}
+	if partition != nil && partition.ArchiveSHA256 != nil {
+		checksum, err := fileChecksum(path)
+		if err != nil {
+			return "", 0, err
+		}
+		if checksum != *partition.ArchiveSHA256 {
+			return "", 0, fmt.Errorf("archive %s does not match the checksum recorded for partition %s", path, name)
+		}
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("error opening archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return "", 0, fmt.Errorf("error reading archive %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	var restored int64
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		partitionRepo := s.partitionRepo.WithTx(tx)
+
+		if err := partitionRepo.CreateRestoreTable(ctx, table); err != nil {
+			return err
+		}
+
+		decoder := json.NewDecoder(reader)
+		batch := make([]*models.StockEvent, 0, restoreBatchSize)
+		flush := func() error {
+			copied, err := partitionRepo.CopyEvents(ctx, table, batch)
+			restored += copied
+			batch = batch[:0]
+			return err
+		}
+
+		for {
+			var event models.StockEvent
+			if err := decoder.Decode(&event); err != nil {
+				if err == io.EOF {
+					break
+				}
+				return fmt.Errorf("error decoding archived event %d: %w", restored+int64(len(batch))+1, err)
+			}
+
+			batch = append(batch, &event)
+			if len(batch) == restoreBatchSize {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+
+		return flush()
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("error restoring archive %s: %w", path, err)
+	}
+
+	return table, restored, nil
+}
+
+// writeArchive exporta la partición a path como NDJSON comprimido y retorna la cantidad de eventos
+// y el SHA-256
+func writeArchive(ctx context.Context, partitionRepo *repository.StockEventPartitionRepository, name, path string) (int, string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return 0, "", fmt.Errorf("error creating archive directory: %w", err)
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, "", fmt.Errorf("error creating archive file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	hash := sha256.New()
+	writer := gzip.NewWriter(io.MultiWriter(file, hash))
+	encoder := json.NewEncoder(writer)
+
+	rows, err := partitionRepo.ExportPartition(ctx, name, func(event *models.StockEvent) error {
+		return encoder.Encode(event)
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return 0, "", fmt.Errorf("error writing archive file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return 0, "", fmt.Errorf("error writing archive file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return 0, "", fmt.Errorf("error writing archive file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, "", fmt.Errorf("error moving archive file into place: %w", err)
+	}
+
+	return rows, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyArchive lee el archivo completo y verifica que tenga la cantidad de eventos y el
+// SHA-256 registrados para la partición
+func verifyArchive(path string, partition *models.StockEventPartition) error {
+	if partition.ArchivedRows == nil || partition.ArchiveSHA256 == nil {
+		return fmt.Errorf("partition %s has no archive recorded", partition.Name)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening archive: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	tee := io.TeeReader(file, hash)
+
+	reader, err := gzip.NewReader(tee)
+	if err != nil {
+		return fmt.Errorf("error reading archive %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	rows := 0
+	decoder := json.NewDecoder(reader)
+	for {
+		var event models.StockEvent
+		if err := decoder.Decode(&event); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("error decoding archived event %d of %s: %w", rows+1, path, err)
+		}
+		rows++
+	}
+
+	// El SHA-256 es del archivo comprimido completo
+	if _, err := io.Copy(io.Discard, tee); err != nil {
+		return fmt.Errorf("error reading archive %s: %w", path, err)
+	}
+
+	if rows != *partition.ArchivedRows {
+		return fmt.Errorf("archive %s has %d events, expected %d", path, rows, *partition.ArchivedRows)
+	}
+	if checksum := hex.EncodeToString(hash.Sum(nil)); checksum != *partition.ArchiveSHA256 {
+		return fmt.Errorf("archive %s does not match the checksum recorded for partition %s", path, partition.Name)
+	}
+
+	return nil
+}
+
+// fileChecksum calcula el SHA-256 de un archivo
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening archive: %w", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("error reading archive: %w", err)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
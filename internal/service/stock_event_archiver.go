@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+)
+
+// StockEventArchiver crea y archiva periódicamente las particiones de stock_events
+type StockEventArchiver struct {
+	archiveService  *StockEventArchiveService
+	interval        time.Duration
+	partitionsAhead int
+	retentionMonths int
+}
+
+func NewStockEventArchiver(archiveService *StockEventArchiveService, interval time.Duration, partitionsAhead, retentionMonths int) *StockEventArchiver {
+	if interval <= 0 {
+		interval = 6 * time.Hour
+	}
+	if partitionsAhead < 1 {
+		partitionsAhead = 1
+	}
+	if retentionMonths < 0 {
+		retentionMonths = 0
+	}
+
+	return &StockEventArchiver{
+		archiveService:  archiveService,
+		interval:        interval,
+		partitionsAhead: partitionsAhead,
+		retentionMonths: retentionMonths,
+	}
+}
+
+// Start ejecuta un mantenimiento inmediato, para que existan las particiones del mes en
+// curso, y luego uno por intervalo hasta que se cancele el contexto
+func (a *StockEventArchiver) Start(ctx context.Context) {
+	if a.retentionMonths > 0 {
+		log.Printf("StockEventArchiver: Started, every %s (partitions ahead: %d, retention: %d months)",
+			a.interval, a.partitionsAhead, a.retentionMonths)
+	} else {
+		log.Printf("StockEventArchiver: Started, every %s (partitions ahead: %d, retention disabled)",
+			a.interval, a.partitionsAhead)
+	}
+
+	go func() {
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+
+		a.maintain(ctx)
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("StockEventArchiver: Context cancelled, stopping archiver")
+				return
+			case <-ticker.C:
+				a.maintain(ctx)
+			}
+		}
+	}()
+}
+
+// maintain crea las particiones faltantes y archiva las que superaron la retención
+func (a *StockEventArchiver) maintain(ctx context.Context) {
+	now := time.Now().UTC()
+
+	if err := a.archiveService.EnsurePartitions(ctx, now, a.partitionsAhead); err != nil {
+		log.Printf("StockEventArchiver: Error creating partitions: %v", err)
+	}
+
+	if a.retentionMonths == 0 {
+		return
+	}
+
+	// Se conservan los retentionMonths meses completos anteriores al mes en curso
+	cutoff := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -a.retentionMonths, 0)
+
+	archived, err := a.archiveService.ArchiveExpired(ctx, cutoff)
+	for _, partition := range archived {
+		log.Printf("StockEventArchiver: Archived partition %s (%d events) to %s",
+			partition.Name, *partition.ArchivedRows, *partition.ArchivePath)
+	}
+	switch {
+	case errors.Is(err, models.ErrArchiveInProgress):
+	case errors.Is(err, models.ErrSnapshotInProgress):
+		log.Println("StockEventArchiver: Snapshot run in progress, archiving on the next run")
+	case err != nil:
+		log.Printf("StockEventArchiver: Error archiving partitions: %v", err)
+	}
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// StockHistoryService reconstruye el stock de los artículos en momentos pasados a partir de
+// stock_events, partiendo de la snapshot más cercana para no recorrer todo el historial
+type StockHistoryService struct {
+	stockRepo     *repository.StockRepository
+	eventRepo     *repository.StockEventRepository
+	snapshotRepo  *repository.StockSnapshotRepository
+	partitionRepo *repository.StockEventPartitionRepository
+	txManager     *repository.TxManager
+}
+
+func NewStockHistoryService(
+	stockRepo *repository.StockRepository,
+	eventRepo *repository.StockEventRepository,
+	snapshotRepo *repository.StockSnapshotRepository,
+	partitionRepo *repository.StockEventPartitionRepository,
+	txManager *repository.TxManager,
+) *StockHistoryService {
+	return &StockHistoryService{
+		stockRepo:     stockRepo,
+		eventRepo:     eventRepo,
+		snapshotRepo:  snapshotRepo,
+		partitionRepo: partitionRepo,
+		txManager:     txManager,
+	}
+}
+
+// GetStockAsOf retorna la cantidad y el stock reservado del artículo en el momento ts
+func (s *StockHistoryService) GetStockAsOf(ctx context.Context, articleID string, ts time.Time) (*models.StockAsOf, error) {
+	if err := s.checkNotArchived(ctx, ts); err != nil {
+		return nil, err
+	}
+
+	state, err := s.rebuild(ctx, s.eventRepo, s.snapshotRepo, articleID, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	if !state.HasHistory() {
+		return nil, fmt.Errorf("%w: %s has no history up to %s", models.ErrArticleNotFound, articleID, ts.Format(time.RFC3339))
+	}
+
+	return state, nil
+}
+
+// GetStocksAsOf retorna el stock de varios artículos en el momento ts. Los artículos sin
+// historial hasta ese momento se retornan aparte.
+func (s *StockHistoryService) GetStocksAsOf(ctx context.Context, articleIDs []string, ts time.Time) ([]*models.StockAsOf, []string, error) {
+	if err := s.checkNotArchived(ctx, ts); err != nil {
+		return nil, nil, err
+	}
+
+	stocks := make([]*models.StockAsOf, 0, len(articleIDs))
+	var missing []string
+
+	for _, articleID := range articleIDs {
+		state, err := s.rebuild(ctx, s.eventRepo, s.snapshotRepo, articleID, ts)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !state.HasHistory() {
+			missing = append(missing, articleID)
+			continue
+		}
+		stocks = append(stocks, state)
+	}
+
+	return stocks, missing, nil
+}
+
+// TakeSnapshots registra una snapshot de todos los artículos al cierre de cut, salvo que la última
+// sea más reciente que minAge
+func (s *StockHistoryService) TakeSnapshots(ctx context.Context, cut time.Time, minAge time.Duration) (int, error) {
+	taken := 0
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		snapshotRepo := s.snapshotRepo.WithTx(tx)
+		eventRepo := s.eventRepo.WithTx(tx)
+
+		locked, err := snapshotRepo.TryLockSnapshotRun(ctx)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return models.ErrSnapshotInProgress
+		}
+
+		if minAge > 0 {
+			last, err := snapshotRepo.GetLastSnapshotTime(ctx)
+			if err != nil {
+				return err
+			}
+			if last != nil && cut.Sub(*last) < minAge {
+				return nil
+			}
+		}
+
+		// Incluye los artículos archivados: su historial se sigue consultando
+		articleIDs, err := s.stockRepo.WithTx(tx).GetAllArticleIDs(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, articleID := range articleIDs {
+			state, err := s.rebuild(ctx, eventRepo, snapshotRepo, articleID, cut)
+			if err != nil {
+				return err
+			}
+			if !state.HasHistory() {
+				continue
+			}
+
+			snapshot := &models.StockSnapshot{
+				ArticleID: articleID,
+				TakenAt:   cut,
+				Quantity:  state.Quantity,
+				Reserved:  state.Reserved,
+			}
+			if err := snapshotRepo.CreateSnapshot(ctx, snapshot); err != nil {
+				return err
+			}
+			taken++
+		}
+
+		return nil
+	})
+	if err != nil {
+		if err == models.ErrSnapshotInProgress {
+			return 0, err
+		}
+		return 0, fmt.Errorf("error taking stock snapshots: %w", err)
+	}
+
+	return taken, nil
+}
+
+// checkNotArchived retorna ErrHistoryArchived si los eventos anteriores a ts ya se archivaron
+func (s *StockHistoryService) checkNotArchived(ctx context.Context, ts time.Time) error {
+	horizon, err := s.partitionRepo.GetArchivedHorizon(ctx)
+	if err != nil {
+		return err
+	}
+	if horizon != nil && ts.Before(*horizon) {
+		return fmt.Errorf("%w: events before %s are archived", models.ErrHistoryArchived, horizon.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// rebuild reconstruye el estado del artículo en ts desde la snapshot más reciente y los eventos
+// posteriores
+func (s *StockHistoryService) rebuild(ctx context.Context, eventRepo *repository.StockEventRepository, snapshotRepo *repository.StockSnapshotRepository, articleID string, ts time.Time) (*models.StockAsOf, error) {
+	state := &models.StockAsOf{
+		ArticleID: articleID,
+		AsOf:      ts,
+	}
+
+	snapshot, err := snapshotRepo.GetLatestSnapshot(ctx, articleID, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	var since *time.Time
+	if snapshot != nil {
+		state.ApplySnapshot(snapshot)
+		since = &snapshot.TakenAt
+	}
+
+	anchor, err := eventRepo.GetLastBalancedEvent(ctx, articleID, since, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *models.EventCursor
+	if anchor != nil {
+		state.ApplyEvent(anchor)
+		after = &models.EventCursor{CreatedAt: anchor.CreatedAt, ID: anchor.ID}
+	}
+
+	events, err := eventRepo.ListEventsToReplay(ctx, articleID, since, after, ts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, event := range events {
+		state.ApplyEvent(event)
+	}
+
+	return state, nil
+}
@@ -0,0 +1,427 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
+)
+
+// stockExportColumns son las columnas del CSV exportado. La importación acepta el mismo archivo:
+// ignora las columnas que no se pueden importar (reserved, status, version y las fechas).
+var stockExportColumns = []string{
+	"article_id", "quantity", "reserved", "min_stock", "max_stock", "location", "status", "version", "created_at", "updated_at",
+}
+
+// stockImportIgnoredColumns son las columnas del CSV exportado que la importación ignora
+var stockImportIgnoredColumns = map[string]bool{
+	"id": true, "reserved": true, "status": true, "version": true, "created_at": true, "updated_at": true,
+}
+
+// maxNDJSONLineSize es el largo máximo de una línea NDJSON importada
+const maxNDJSONLineSize = 64 * 1024
+
+// StockImportService importa y exporta artículos en CSV o NDJSON
+type StockImportService struct {
+	stockService *StockService
+	stockRepo    *repository.StockRepository
+	txManager    *repository.TxManager
+}
+
+func NewStockImportService(
+	stockService *StockService,
+	stockRepo *repository.StockRepository,
+	txManager *repository.TxManager,
+) *StockImportService {
+	return &StockImportService{
+		stockService: stockService,
+		stockRepo:    stockRepo,
+		txManager:    txManager,
+	}
+}
+
+// stockImportOp es lo que la importación hará con una fila válida
+type stockImportOp struct {
+	row     *models.StockImportRow
+	current *models.Stock // nil si el artículo es nuevo
+	create  *models.Stock
+	changed bool
+}
+
+// Import valida todas las filas del archivo y, si ninguna tiene errores y no es dry-run, las aplica
+// en una sola transacción
+func (s *StockImportService) Import(ctx context.Context, r io.Reader, format models.StockFileFormat, mode models.StockImportMode, dryRun bool) (*models.StockImportResult, error) {
+	if !mode.IsValid() {
+		return nil, fmt.Errorf("%w: unknown mode %q", models.ErrInvalidImport, mode)
+	}
+
+	result := &models.StockImportResult{Mode: mode, DryRun: dryRun, Errors: []models.StockImportRowError{}}
+
+	var rows []*models.StockImportRow
+	var err error
+	switch format {
+	case models.StockFileFormatCSV:
+		rows, err = parseStockImportCSV(r, result)
+	case models.StockFileFormatNDJSON:
+		rows, err = parseStockImportNDJSON(r, result)
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", models.ErrInvalidImport, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.Rows == 0 {
+		return nil, fmt.Errorf("%w: file has no rows", models.ErrInvalidImport)
+	}
+
+	// Validar cada fila por sí sola y detectar artículos repetidos en el archivo
+	valid := make([]*models.StockImportRow, 0, len(rows))
+	seen := make(map[string]int, len(rows))
+	for _, row := range rows {
+		if err := row.Validate(); err != nil {
+			result.AddError(row, err)
+			continue
+		}
+		if line, ok := seen[row.ArticleID]; ok {
+			result.AddError(row, fmt.Errorf("duplicate article_id, already imported on line %d", line))
+			continue
+		}
+		seen[row.ArticleID] = row.Line
+		valid = append(valid, row)
+	}
+
+	articleIDs := make([]string, 0, len(valid))
+	for _, row := range valid {
+		articleIDs = append(articleIDs, row.ArticleID)
+	}
+
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		existing, err := s.stockRepo.WithTx(tx).LockStocksForUpdate(ctx, articleIDs)
+		if err != nil {
+			return err
+		}
+
+		// Validar contra el estado actual de cada artículo antes de aplicar nada
+		ops := make([]*stockImportOp, 0, len(valid))
+		for _, row := range valid {
+			op, err := planStockImportRow(row, existing[row.ArticleID], mode)
+			if err != nil {
+				result.AddError(row, err)
+				continue
+			}
+			ops = append(ops, op)
+
+			switch {
+			case op.create != nil:
+				result.Created++
+			case op.changed:
+				result.Updated++
+			default:
+				result.Unchanged++
+			}
+		}
+
+		if dryRun || result.HasErrors() {
+			return nil
+		}
+
+		for _, op := range ops {
+			if err := s.applyStockImportOp(ctx, tx, op); err != nil {
+				return fmt.Errorf("line %d: %w", op.row.Line, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(result.Errors, func(i, j int) bool {
+		return result.Errors[i].Line < result.Errors[j].Line
+	})
+	return result, nil
+}
+
+// planStockImportRow decide qué hacer con la fila según el artículo actual y valida el resultado
+func planStockImportRow(row *models.StockImportRow, current *models.Stock, mode models.StockImportMode) (*stockImportOp, error) {
+	op := &stockImportOp{row: row, current: current}
+
+	if current == nil {
+		stock := &models.Stock{ArticleID: row.ArticleID}
+		if row.Quantity != nil {
+			stock.Quantity = *row.Quantity
+		}
+		if _, err := stock.ApplySettings(row.SettingsRequest()); err != nil {
+			return nil, err
+		}
+		op.create = stock
+		return op, nil
+	}
+
+	if mode == models.StockImportModeCreate {
+		return nil, models.ErrArticleAlreadyExists
+	}
+
+	updated := *current
+	changes, err := updated.ApplySettings(row.SettingsRequest())
+	if err != nil {
+		return nil, err
+	}
+	if row.Quantity != nil && *row.Quantity > current.Quantity && !current.Status.AllowsReplenishment() {
+		return nil, &models.ErrArticleUnavailable{ArticleID: current.ArticleID, Status: current.Status, Operation: "replenishment"}
+	}
+	if row.Quantity != nil && *row.Quantity < current.Reserved {
+		return nil, fmt.Errorf("quantity (%d) cannot be less than reserved stock (%d)", *row.Quantity, current.Reserved)
+	}
+
+	op.changed = len(changes) > 0 || (row.Quantity != nil && *row.Quantity != current.Quantity)
+	return op, nil
+}
+
+// applyStockImportOp aplica una fila ya validada dentro de la transacción de la importación
+func (s *StockImportService) applyStockImportOp(ctx context.Context, tx pgx.Tx, op *stockImportOp) error {
+	if op.create != nil {
+		return s.stockService.createStock(ctx, tx, op.create, "Artículo creado por importación")
+	}
+	if !op.changed {
+		return nil
+	}
+
+	stock := op.current
+	if op.row.Quantity != nil && *op.row.Quantity != stock.Quantity {
+		// La cantidad se ajusta antes que la configuración para no encolar dos alertas de stock
+		// bajo por la misma fila
+		updated, err := s.stockRepo.WithTx(tx).SetQuantity(ctx, stock.ArticleID, *op.row.Quantity)
+		if err != nil {
+			return err
+		}
+
+		variance := updated.Quantity - stock.Quantity
+		metadata, err := json.Marshal(map[string]interface{}{
+			"source":   "import",
+			"line":     op.row.Line,
+			"variance": variance,
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding import adjustment: %w", err)
+		}
+
+		event := &models.StockEvent{
+			ArticleID:      stock.ArticleID,
+			EventType:      models.EventTypeAdjust,
+			Quantity:       abs(variance),
+			Reason:         fmt.Sprintf("Ajuste por importación: cantidad de %d a %d", stock.Quantity, updated.Quantity),
+			Metadata:       string(metadata),
+			QuantityBefore: &stock.Quantity,
+			QuantityAfter:  &updated.Quantity,
+			ReservedBefore: &stock.Reserved,
+			ReservedAfter:  &updated.Reserved,
+		}
+		if err := s.stockService.recordEvent(ctx, tx, event); err != nil {
+			return err
+		}
+
+		if variance < 0 {
+			if err := s.stockService.enqueueLowStockAlert(ctx, tx, stock.ArticleID); err != nil {
+				return err
+			}
+		}
+		stock = updated
+	}
+
+	_, err := s.stockService.updateSettings(ctx, tx, stock, op.row.SettingsRequest())
+	return err
+}
+
+// parseStockImportCSV lee un CSV con encabezado. Las celdas vacías se consideran omitidas.
+func parseStockImportCSV(r io.Reader, result *models.StockImportResult) ([]*models.StockImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: file is empty", models.ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", models.ErrInvalidImport, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "article_id", "quantity", "min_stock", "max_stock", "location":
+		default:
+			if !stockImportIgnoredColumns[name] {
+				return nil, fmt.Errorf("%w: unknown column %q", models.ErrInvalidImport, name)
+			}
+		}
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("%w: duplicate column %q", models.ErrInvalidImport, name)
+		}
+		columns[name] = i
+	}
+	if _, ok := columns["article_id"]; !ok {
+		return nil, fmt.Errorf("%w: missing article_id column", models.ErrInvalidImport)
+	}
+
+	var rows []*models.StockImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		result.Rows++
+		if result.Rows > models.MaxImportRows {
+			return nil, fmt.Errorf("%w: file has more than %d rows", models.ErrInvalidImport, models.MaxImportRows)
+		}
+
+		row := &models.StockImportRow{}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("%w: %v", models.ErrInvalidImport, err)
+			}
+			row.Line = parseErr.StartLine
+			result.AddError(row, parseErr.Err)
+			if !errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				// Un error de comillas deja el lector en un estado indefinido
+				return rows, nil
+			}
+			continue
+		}
+
+		row.Line, _ = reader.FieldPos(0)
+		if err := fillStockImportRow(row, record, columns); err != nil {
+			result.AddError(row, err)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// fillStockImportRow completa la fila con las celdas del registro CSV
+func fillStockImportRow(row *models.StockImportRow, record []string, columns map[string]int) error {
+	cell := func(name string) (string, bool) {
+		i, ok := columns[name]
+		if !ok {
+			return "", false
+		}
+		value := strings.TrimSpace(record[i])
+		return value, value != ""
+	}
+
+	row.ArticleID, _ = cell("article_id")
+
+	for name, field := range map[string]**int{
+		"quantity":  &row.Quantity,
+		"min_stock": &row.MinStock,
+		"max_stock": &row.MaxStock,
+	} {
+		value, ok := cell(name)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s must be an integer", name)
+		}
+		*field = &n
+	}
+
+	if value, ok := cell("location"); ok {
+		row.Location = &value
+	}
+	return nil
+}
+
+// parseStockImportNDJSON lee un objeto JSON por línea. Las líneas vacías se ignoran.
+func parseStockImportNDJSON(r io.Reader, result *models.StockImportResult) ([]*models.StockImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), maxNDJSONLineSize)
+
+	var rows []*models.StockImportRow
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := strings.TrimSpace(scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		result.Rows++
+		if result.Rows > models.MaxImportRows {
+			return nil, fmt.Errorf("%w: file has more than %d rows", models.ErrInvalidImport, models.MaxImportRows)
+		}
+
+		row := &models.StockImportRow{}
+		if err := json.Unmarshal([]byte(data), row); err != nil {
+			row.Line = line
+			result.AddError(row, fmt.Errorf("invalid JSON: %v", err))
+			continue
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: line %d: %v", models.ErrInvalidImport, line+1, err)
+	}
+
+	return rows, nil
+}
+
+// Export escribe todos los artículos, incluidos los archivados, en el formato pedido a medida
+// que se leen de la base. Retorna la cantidad de artículos exportados.
+func (s *StockImportService) Export(ctx context.Context, format models.StockFileFormat, w io.Writer) (int, error) {
+	switch format {
+	case models.StockFileFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(stockExportColumns); err != nil {
+			return 0, err
+		}
+
+		exported, err := s.stockRepo.ExportStocks(ctx, func(stock *models.Stock) error {
+			return writer.Write([]string{
+				stock.ArticleID,
+				strconv.Itoa(stock.Quantity),
+				strconv.Itoa(stock.Reserved),
+				strconv.Itoa(stock.MinStock),
+				strconv.Itoa(stock.MaxStock),
+				stock.Location,
+				string(stock.Status),
+				strconv.FormatInt(stock.Version, 10),
+				stock.CreatedAt.UTC().Format(time.RFC3339Nano),
+				stock.UpdatedAt.UTC().Format(time.RFC3339Nano),
+			})
+		})
+		if err != nil {
+			return exported, err
+		}
+
+		writer.Flush()
+		return exported, writer.Error()
+
+	case models.StockFileFormatNDJSON:
+		encoder := json.NewEncoder(w)
+		return s.stockRepo.ExportStocks(ctx, func(stock *models.Stock) error {
+			return encoder.Encode(stock)
+		})
+
+	default:
+		return 0, fmt.Errorf("unknown export format %q", format)
+	}
+}
@@ -0,0 +1,359 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+)
+
+func intPtr(n int) *int { return &n }
+
+func TestParseStockImportCSV(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantErr    error
+		wantRows   int
+		wantValid  []string
+		wantErrors []int
+	}{
+		{
+			name:      "valid rows",
+			input:     "article_id,quantity,min_stock,max_stock,location\nA1,10,2,20,DEP-1\nA2,,,,\n",
+			wantRows:  2,
+			wantValid: []string{"A1", "A2"},
+		},
+		{
+			name:      "exported columns are ignored",
+			input:     "\ufeffid,Article_ID,quantity,reserved,status,version\n1,A1,5,1,ACTIVE,3\n",
+			wantRows:  1,
+			wantValid: []string{"A1"},
+		},
+		{
+			name:    "empty file",
+			input:   "",
+			wantErr: models.ErrInvalidImport,
+		},
+		{
+			name:    "unknown column",
+			input:   "article_id,price\nA1,10\n",
+			wantErr: models.ErrInvalidImport,
+		},
+		{
+			name:    "duplicate column",
+			input:   "article_id,quantity,quantity\nA1,1,2\n",
+			wantErr: models.ErrInvalidImport,
+		},
+		{
+			name:    "missing article_id column",
+			input:   "quantity\n10\n",
+			wantErr: models.ErrInvalidImport,
+		},
+		{
+			name:       "non integer quantity",
+			input:      "article_id,quantity\nA1,diez\nA2,3\n",
+			wantRows:   2,
+			wantValid:  []string{"A2"},
+			wantErrors: []int{2},
+		},
+		{
+			name:       "wrong field count keeps reading",
+			input:      "article_id,quantity\nA1,1,extra\nA2,3\n",
+			wantRows:   2,
+			wantValid:  []string{"A2"},
+			wantErrors: []int{2},
+		},
+		{
+			name:       "quote error stops reading",
+			input:      "article_id,quantity\nA1,1\n\"A2,3\nA3,4\n",
+			wantRows:   2,
+			wantValid:  []string{"A1"},
+			wantErrors: []int{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &models.StockImportResult{}
+			rows, err := parseStockImportCSV(strings.NewReader(tt.input), result)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.Rows != tt.wantRows {
+				t.Errorf("expected %d rows, got %d", tt.wantRows, result.Rows)
+			}
+			assertImportRows(t, rows, tt.wantValid)
+			assertImportErrorLines(t, result, tt.wantErrors)
+		})
+	}
+}
+
+func TestParseStockImportCSVFillsRow(t *testing.T) {
+	input := "article_id,quantity,min_stock,max_stock,location\n A1 , 10 ,2,,DEP-1\n"
+
+	rows, err := parseStockImportCSV(strings.NewReader(input), &models.StockImportResult{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+
+	row := rows[0]
+	switch {
+	case row.Line != 2:
+		t.Errorf("expected line 2, got %d", row.Line)
+	case row.ArticleID != "A1":
+		t.Errorf("expected article A1, got %q", row.ArticleID)
+	case row.Quantity == nil || *row.Quantity != 10:
+		t.Errorf("expected quantity 10, got %v", row.Quantity)
+	case row.MinStock == nil || *row.MinStock != 2:
+		t.Errorf("expected min_stock 2, got %v", row.MinStock)
+	case row.MaxStock != nil:
+		t.Errorf("expected an omitted max_stock, got %d", *row.MaxStock)
+	case row.Location == nil || *row.Location != "DEP-1":
+		t.Errorf("expected location DEP-1, got %v", row.Location)
+	}
+}
+
+func TestParseStockImportNDJSON(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantRows   int
+		wantValid  []string
+		wantErrors []int
+	}{
+		{
+			name:      "valid rows",
+			input:     "{\"article_id\":\"A1\",\"quantity\":10}\n{\"article_id\":\"A2\",\"location\":\"DEP-1\"}\n",
+			wantRows:  2,
+			wantValid: []string{"A1", "A2"},
+		},
+		{
+			name:      "blank lines are skipped",
+			input:     "\n{\"article_id\":\"A1\"}\n\n   \n{\"article_id\":\"A2\"}",
+			wantRows:  2,
+			wantValid: []string{"A1", "A2"},
+		},
+		{
+			name:       "invalid JSON",
+			input:      "{\"article_id\":\"A1\"}\n{article_id:A2}\n{\"article_id\":\"A3\",\"quantity\":\"5\"}\n",
+			wantRows:   3,
+			wantValid:  []string{"A1"},
+			wantErrors: []int{2, 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &models.StockImportResult{}
+			rows, err := parseStockImportNDJSON(strings.NewReader(tt.input), result)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.Rows != tt.wantRows {
+				t.Errorf("expected %d rows, got %d", tt.wantRows, result.Rows)
+			}
+			assertImportRows(t, rows, tt.wantValid)
+			assertImportErrorLines(t, result, tt.wantErrors)
+		})
+	}
+}
+
+func TestParseStockImportNDJSONLineTooLong(t *testing.T) {
+	input := "{\"article_id\":\"" + strings.Repeat("A", maxNDJSONLineSize) + "\"}\n"
+
+	_, err := parseStockImportNDJSON(strings.NewReader(input), &models.StockImportResult{})
+	if !errors.Is(err, models.ErrInvalidImport) {
+		t.Fatalf("expected ErrInvalidImport, got %v", err)
+	}
+}
+
+func TestStockImportRowValidate(t *testing.T) {
+	location := strings.Repeat("L", models.MaxLocationLength+1)
+
+	tests := []struct {
+		name    string
+		row     models.StockImportRow
+		wantErr bool
+	}{
+		{name: "valid", row: models.StockImportRow{ArticleID: "A1", Quantity: intPtr(0)}},
+		{name: "missing article_id", row: models.StockImportRow{}, wantErr: true},
+		{name: "article_id too long", row: models.StockImportRow{ArticleID: strings.Repeat("A", models.MaxArticleIDLength+1)}, wantErr: true},
+		{name: "negative quantity", row: models.StockImportRow{ArticleID: "A1", Quantity: intPtr(-1)}, wantErr: true},
+		{name: "negative min_stock", row: models.StockImportRow{ArticleID: "A1", MinStock: intPtr(-1)}, wantErr: true},
+		{name: "negative max_stock", row: models.StockImportRow{ArticleID: "A1", MaxStock: intPtr(-1)}, wantErr: true},
+		{name: "location too long", row: models.StockImportRow{ArticleID: "A1", Location: &location}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.row.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPlanStockImportRow(t *testing.T) {
+	current := func(status models.StockStatus) *models.Stock {
+		return &models.Stock{ArticleID: "A1", Quantity: 10, Reserved: 4, MinStock: 2, MaxStock: 50, Status: status}
+	}
+
+	tests := []struct {
+		name        string
+		row         models.StockImportRow
+		current     *models.Stock
+		mode        models.StockImportMode
+		wantErr     error
+		wantAnyErr  bool
+		wantCreate  bool
+		wantChanged bool
+	}{
+		{
+			name:       "new article",
+			row:        models.StockImportRow{ArticleID: "A1", Quantity: intPtr(5), MinStock: intPtr(1)},
+			mode:       models.StockImportModeCreate,
+			wantCreate: true,
+		},
+		{
+			name:    "new article with invalid settings",
+			row:     models.StockImportRow{ArticleID: "A1", MinStock: intPtr(10), MaxStock: intPtr(5)},
+			mode:    models.StockImportModeUpsert,
+			wantErr: models.ErrInvalidStockSettings,
+		},
+		{
+			name:    "existing article in create mode",
+			row:     models.StockImportRow{ArticleID: "A1"},
+			current: current(models.StockStatusActive),
+			mode:    models.StockImportModeCreate,
+			wantErr: models.ErrArticleAlreadyExists,
+		},
+		{
+			name:    "existing article without changes",
+			row:     models.StockImportRow{ArticleID: "A1", Quantity: intPtr(10), MinStock: intPtr(2)},
+			current: current(models.StockStatusActive),
+			mode:    models.StockImportModeUpsert,
+		},
+		{
+			name:        "existing article with new quantity",
+			row:         models.StockImportRow{ArticleID: "A1", Quantity: intPtr(12)},
+			current:     current(models.StockStatusActive),
+			mode:        models.StockImportModeUpsert,
+			wantChanged: true,
+		},
+		{
+			name:        "existing article with new settings",
+			row:         models.StockImportRow{ArticleID: "A1", MaxStock: intPtr(80)},
+			current:     current(models.StockStatusActive),
+			mode:        models.StockImportModeUpsert,
+			wantChanged: true,
+		},
+		{
+			name:    "existing article with invalid settings",
+			row:     models.StockImportRow{ArticleID: "A1", MaxStock: intPtr(1)},
+			current: current(models.StockStatusActive),
+			mode:    models.StockImportModeUpsert,
+			wantErr: models.ErrInvalidStockSettings,
+		},
+		{
+			name:       "quantity below reserved",
+			row:        models.StockImportRow{ArticleID: "A1", Quantity: intPtr(3)},
+			current:    current(models.StockStatusActive),
+			mode:       models.StockImportModeUpsert,
+			wantAnyErr: true,
+		},
+		{
+			name:       "increase on a discontinued article",
+			row:        models.StockImportRow{ArticleID: "A1", Quantity: intPtr(11)},
+			current:    current(models.StockStatusDiscontinued),
+			mode:       models.StockImportModeUpsert,
+			wantAnyErr: true,
+		},
+		{
+			name:        "decrease on a discontinued article",
+			row:         models.StockImportRow{ArticleID: "A1", Quantity: intPtr(8)},
+			current:     current(models.StockStatusDiscontinued),
+			mode:        models.StockImportModeUpsert,
+			wantChanged: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := tt.row
+			op, err := planStockImportRow(&row, tt.current, tt.mode)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			case tt.wantAnyErr:
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			case err != nil:
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if (op.create != nil) != tt.wantCreate {
+				t.Errorf("expected create %v, got %v", tt.wantCreate, op.create != nil)
+			}
+			if op.changed != tt.wantChanged {
+				t.Errorf("expected changed %v, got %v", tt.wantChanged, op.changed)
+			}
+		})
+	}
+}
+
+func TestPlanStockImportRowDoesNotModifyCurrent(t *testing.T) {
+	current := &models.Stock{ArticleID: "A1", Quantity: 10, MinStock: 2, MaxStock: 50, Status: models.StockStatusActive}
+	row := &models.StockImportRow{ArticleID: "A1", MinStock: intPtr(5)}
+
+	if _, err := planStockImportRow(row, current, models.StockImportModeUpsert); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if current.MinStock != 2 {
+		t.Errorf("expected current min_stock to stay 2, got %d", current.MinStock)
+	}
+}
+
+func assertImportRows(t *testing.T, rows []*models.StockImportRow, want []string) {
+	t.Helper()
+
+	if len(rows) != len(want) {
+		t.Fatalf("expected %d valid rows, got %d", len(want), len(rows))
+	}
+	for i, row := range rows {
+		if row.ArticleID != want[i] {
+			t.Errorf("row %d: expected article %s, got %s", i, want[i], row.ArticleID)
+		}
+	}
+}
+
+func assertImportErrorLines(t *testing.T, result *models.StockImportResult, want []int) {
+	t.Helper()
+
+	if len(result.Errors) != len(want) {
+		t.Fatalf("expected %d row errors, got %d: %+v", len(want), len(result.Errors), result.Errors)
+	}
+	for i, rowErr := range result.Errors {
+		if rowErr.Line != want[i] {
+			t.Errorf("error %d: expected line %d, got %d", i, want[i], rowErr.Line)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+)
+
+// stockReconcilerActor identifica a la conciliación programada como actor de los eventos ADJUST
+const stockReconcilerActor = "stock-reconciler"
+
+// StockReconciler ejecuta periódicamente la conciliación entre stocks y el historial de eventos
+type StockReconciler struct {
+	reconciliationService *StockReconciliationService
+	interval              time.Duration
+	repair                bool
+}
+
+func NewStockReconciler(reconciliationService *StockReconciliationService, interval time.Duration, repair bool) *StockReconciler {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	return &StockReconciler{
+		reconciliationService: reconciliationService,
+		interval:              interval,
+		repair:                repair,
+	}
+}
+
+// Start inicia la conciliación periódica en segundo plano hasta que se cancele el contexto.
+// Verifica con más frecuencia que el intervalo para no depender de cuándo arrancó la réplica.
+func (r *StockReconciler) Start(ctx context.Context) {
+	checkInterval := r.interval
+	if checkInterval > time.Hour {
+		checkInterval = time.Hour
+	}
+
+	log.Printf("StockReconciler: Started, reconciling every %s (repair: %t)", r.interval, r.repair)
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("StockReconciler: Context cancelled, stopping reconciler")
+				return
+			case <-ticker.C:
+				r.reconcile(ctx)
+			}
+		}
+	}()
+}
+
+// reconcile ejecuta una corrida de conciliación si corresponde
+func (r *StockReconciler) reconcile(ctx context.Context) {
+	ctx = models.ContextWithAttribution(ctx, models.Attribution{
+		ActorType: models.ActorTypeSystem,
+		ActorID:   stockReconcilerActor,
+	})
+
+	report, err := r.reconciliationService.ReconcileIfDue(ctx, r.repair, r.interval)
+	if err != nil {
+		log.Printf("StockReconciler: Error reconciling stock: %v", err)
+		return
+	}
+	if report == nil {
+		return
+	}
+
+	for _, discrepancy := range report.Discrepancies {
+		log.Printf("StockReconciler: Article %s drifted from its events (quantity %+d, reserved %+d, repaired: %t)",
+			discrepancy.ArticleID, discrepancy.QuantityDrift, discrepancy.ReservedDrift, discrepancy.Repaired)
+	}
+	log.Printf("StockReconciler: Run %s checked %d article(s), %d discrepancy(ies), %d repaired",
+		report.Run.ID, report.Run.ArticlesChecked, report.Run.Discrepancies, report.Run.Repaired)
+}
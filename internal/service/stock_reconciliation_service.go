@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// StockReconciliationService compara la tabla stocks con los saldos que resultan de sumar
+// los eventos de cada artículo, registra las discrepancias y, opcionalmente, las repara.
+type StockReconciliationService struct {
+	stockService       *StockService
+	stockRepo          *repository.StockRepository
+	reconciliationRepo *repository.StockReconciliationRepository
+	txManager          *repository.TxManager
+}
+
+func NewStockReconciliationService(
+	stockService *StockService,
+	stockRepo *repository.StockRepository,
+	reconciliationRepo *repository.StockReconciliationRepository,
+	txManager *repository.TxManager,
+) *StockReconciliationService {
+	return &StockReconciliationService{
+		stockService:       stockService,
+		stockRepo:          stockRepo,
+		reconciliationRepo: reconciliationRepo,
+		txManager:          txManager,
+	}
+}
+
+// Reconcile ejecuta una corrida de conciliación y registra su reporte. Con repair salda cada
+// discrepancia con un evento ADJUST.
+func (s *StockReconciliationService) Reconcile(ctx context.Context, repair bool) (*models.ReconciliationReport, error) {
+	return s.reconcile(ctx, repair, 0)
+}
+
+// ReconcileIfDue ejecuta una corrida solo si la anterior empezó hace más de minAge y no hay
+// otra en curso; en caso contrario retorna un reporte nil
+func (s *StockReconciliationService) ReconcileIfDue(ctx context.Context, repair bool, minAge time.Duration) (*models.ReconciliationReport, error) {
+	report, err := s.reconcile(ctx, repair, minAge)
+	if err == models.ErrReconciliationInProgress {
+		return nil, nil
+	}
+	return report, err
+}
+
+// GetReport obtiene el reporte de una corrida; con runID nil, el de la más reciente
+func (s *StockReconciliationService) GetReport(ctx context.Context, runID *uuid.UUID) (*models.ReconciliationReport, error) {
+	run, err := s.reconciliationRepo.GetRun(ctx, runID)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies, err := s.reconciliationRepo.GetDiscrepanciesByRunID(ctx, run.ID)
+	if err != nil {
+		return nil, err
+	}
+	if discrepancies == nil {
+		discrepancies = []*models.StockDiscrepancy{}
+	}
+
+	return &models.ReconciliationReport{Run: run, Discrepancies: discrepancies}, nil
+}
+
+// reconcile ejecuta la corrida; cada artículo se revisa en su propia transacción
+func (s *StockReconciliationService) reconcile(ctx context.Context, repair bool, minAge time.Duration) (*models.ReconciliationReport, error) {
+	var report *models.ReconciliationReport
+
+	err := s.txManager.WithTx(ctx, func(lockTx pgx.Tx) error {
+		lockRepo := s.reconciliationRepo.WithTx(lockTx)
+
+		locked, err := lockRepo.TryLockRun(ctx)
+		if err != nil {
+			return err
+		}
+		if !locked {
+			return models.ErrReconciliationInProgress
+		}
+
+		if minAge > 0 {
+			last, err := lockRepo.GetLastRunTime(ctx)
+			if err != nil {
+				return err
+			}
+			if last != nil && time.Since(*last) < minAge {
+				return nil
+			}
+		}
+
+		report, err = s.run(ctx, repair)
+		return err
+	})
+	if err != nil {
+		if err == models.ErrReconciliationInProgress {
+			return nil, err
+		}
+		return nil, fmt.Errorf("error reconciling stock: %w", err)
+	}
+
+	return report, nil
+}
+
+// run registra el encabezado de la corrida, verifica cada artículo con diferencias en su propia
+// transacción y registra el resumen. Si falla a mitad, la corrida queda sin finished_at.
+func (s *StockReconciliationService) run(ctx context.Context, repair bool) (*models.ReconciliationReport, error) {
+	attribution := models.AttributionFromContext(ctx)
+	run := &models.ReconciliationRun{
+		ID:          uuid.New(),
+		StartedAt:   time.Now(),
+		Repair:      repair,
+		TriggeredBy: attribution.ActorID,
+	}
+	if run.TriggeredBy == "" {
+		run.TriggeredBy = string(attribution.ActorType)
+	}
+
+	var err error
+	run.ArticlesChecked, err = s.reconciliationRepo.CountStocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		return s.reconciliationRepo.WithTx(tx).CreateRun(ctx, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	candidates, err := s.reconciliationRepo.FindDiscrepancies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	discrepancies := make([]*models.StockDiscrepancy, 0, len(candidates))
+	for _, candidate := range candidates {
+		var discrepancy *models.StockDiscrepancy
+		err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+			var err error
+			discrepancy, err = s.checkArticle(ctx, tx, run, candidate, repair)
+			if err != nil || discrepancy == nil {
+				return err
+			}
+
+			discrepancy.RunID = run.ID
+			return s.reconciliationRepo.WithTx(tx).CreateDiscrepancy(ctx, discrepancy)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("article %s: %w", candidate.ArticleID, err)
+		}
+		if discrepancy == nil {
+			continue
+		}
+
+		discrepancies = append(discrepancies, discrepancy)
+		if discrepancy.Repaired {
+			run.Repaired++
+		}
+	}
+
+	finishedAt := time.Now()
+	run.FinishedAt = &finishedAt
+	run.Discrepancies = len(discrepancies)
+
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		return s.reconciliationRepo.WithTx(tx).FinishRun(ctx, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ReconciliationReport{Run: run, Discrepancies: discrepancies}, nil
+}
+
+// checkArticle vuelve a comparar el artículo con su fila bloqueada y retorna nil si ya no hay
+// diferencia
+func (s *StockReconciliationService) checkArticle(ctx context.Context, tx pgx.Tx, run *models.ReconciliationRun, candidate *models.StockDiscrepancy, repair bool) (*models.StockDiscrepancy, error) {
+	if candidate.StockQuantity == nil {
+		return candidate, nil
+	}
+
+	if _, err := s.stockRepo.WithTx(tx).GetStockForUpdate(ctx, candidate.ArticleID); err != nil {
+		return nil, err
+	}
+
+	discrepancy, err := s.reconciliationRepo.WithTx(tx).GetArticleDiscrepancy(ctx, candidate.ArticleID)
+	if err != nil {
+		return nil, err
+	}
+	if !discrepancy.HasDrift() {
+		return nil, nil
+	}
+	if !repair {
+		return discrepancy, nil
+	}
+
+	metadata, err := json.Marshal(map[string]interface{}{
+		"reconciliation_run_id": run.ID.String(),
+		"quantity_drift":        discrepancy.QuantityDrift,
+		"reserved_drift":        discrepancy.ReservedDrift,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding reconciliation adjustment: %w", err)
+	}
+
+	attribution := models.AttributionFromContext(ctx)
+	if attribution.ActorType == "" {
+		attribution.ActorType = models.ActorTypeSystem
+	}
+	attribution.CausationID = run.ID.String()
+
+	event := &models.StockEvent{
+		ArticleID:      discrepancy.ArticleID,
+		EventType:      models.EventTypeAdjust,
+		Quantity:       abs(discrepancy.QuantityDrift),
+		Reason:         "Conciliación: historial ajustado al stock registrado",
+		Metadata:       string(metadata),
+		QuantityBefore: &discrepancy.LedgerQuantity,
+		QuantityAfter:  discrepancy.StockQuantity,
+		ReservedBefore: &discrepancy.LedgerReserved,
+		ReservedAfter:  discrepancy.StockReserved,
+		Attribution:    attribution,
+	}
+	if err := s.stockService.recordEvent(ctx, tx, event); err != nil {
+		return nil, err
+	}
+
+	discrepancy.Repaired = true
+	discrepancy.RepairEventID = &event.ID
+	return discrepancy, nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -2,31 +2,65 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"time"
 
 	"github.com/MatiasTelo/stockgo/internal/models"
 	"github.com/MatiasTelo/stockgo/internal/repository"
+	"github.com/jackc/pgx/v5"
 )
 
 type StockService struct {
-	stockRepo        *repository.StockRepository
-	eventRepo        *repository.StockEventRepository
-	messagingService MessagePublisher
+	stockRepo       *repository.StockRepository
+	eventRepo       *repository.StockEventRepository
+	reservationRepo *repository.ReservationRepository
+	txManager       *repository.TxManager
+	publishers      Publishers
+	reservationTTL  time.Duration
 }
 
+// Los publishers escriben en el outbox dentro de la transacción del cambio de stock;
+// el OutboxRelay se encarga de entregarlos a RabbitMQ.
 type MessagePublisher interface {
-	PublishLowStockAlert(ctx context.Context, articleID string, currentQuantity, minStock int) error
+	PublishLowStockAlert(ctx context.Context, tx pgx.Tx, articleID string, currentQuantity, minStock int) error
+}
+
+// OrderShortagePublisher publica el detalle de las órdenes con faltante de stock
+type OrderShortagePublisher interface {
+	PublishOrderShortage(ctx context.Context, tx pgx.Tx, result *models.OrderReservationResult) error
+}
+
+// ReservationExpiredPublisher publica las reservas liberadas por vencimiento
+type ReservationExpiredPublisher interface {
+	PublishReservationExpired(ctx context.Context, tx pgx.Tx, reservation *models.StockReservation) error
+}
+
+// Publishers agrupa los publishers de mensajes usados por StockService
+type Publishers struct {
+	LowStock           MessagePublisher
+	OrderShortage      OrderShortagePublisher
+	ReservationExpired ReservationExpiredPublisher
 }
 
 func NewStockService(
 	stockRepo *repository.StockRepository,
 	eventRepo *repository.StockEventRepository,
-	messagingService MessagePublisher,
+	reservationRepo *repository.ReservationRepository,
+	txManager *repository.TxManager,
+	publishers Publishers,
+	reservationTTL time.Duration,
 ) *StockService {
 	return &StockService{
-		stockRepo:        stockRepo,
-		eventRepo:        eventRepo,
-		messagingService: messagingService,
+		stockRepo:       stockRepo,
+		eventRepo:       eventRepo,
+		reservationRepo: reservationRepo,
+		txManager:       txManager,
+		publishers:      publishers,
+		reservationTTL:  reservationTTL,
 	}
 }
 
@@ -35,7 +69,7 @@ func (s *StockService) CreateStock(ctx context.Context, req *models.CreateStockR
 	// Validar que el artículo no exista
 	existingStock, _ := s.stockRepo.GetStockByArticleID(ctx, req.ArticleID)
 	if existingStock != nil {
-		return nil, fmt.Errorf("article with ID %s already exists", req.ArticleID)
+		return nil, fmt.Errorf("%w: %s", models.ErrArticleAlreadyExists, req.ArticleID)
 	}
 
 	stock := &models.Stock{
@@ -47,140 +81,598 @@ func (s *StockService) CreateStock(ctx context.Context, req *models.CreateStockR
 		Location:  req.Location,
 	}
 
-	if err := s.stockRepo.CreateStock(ctx, stock); err != nil {
-		return nil, fmt.Errorf("error creating stock: %w", err)
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		return s.createStock(ctx, tx, stock, "Nuevo artículo agregado al inventario")
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Crear evento de stock
+	return stock, nil
+}
+
+// createStock inserta el artículo y registra su evento ADD dentro de la transacción
+func (s *StockService) createStock(ctx context.Context, tx pgx.Tx, stock *models.Stock, reason string) error {
+	if err := s.stockRepo.WithTx(tx).CreateStock(ctx, stock); err != nil {
+		return err
+	}
+
+	// Crear evento de stock en la misma transacción
 	event := &models.StockEvent{
-		ArticleID: req.ArticleID,
+		ArticleID: stock.ArticleID,
 		EventType: models.EventTypeAdd,
-		Quantity:  req.Quantity,
-		Reason:    "Nuevo artículo agregado al inventario",
+		Quantity:  stock.Quantity,
+		Reason:    reason,
 	}
+	event.SetBalances(stock)
+
+	return s.recordEvent(ctx, tx, event)
+}
 
-	if err := s.eventRepo.CreateStockEvent(ctx, event); err != nil {
-		// Log error but don't fail the operation
-		fmt.Printf("Warning: Could not create stock event: %v\n", err)
+// ReplenishStock repone stock de un artículo existente. Falla si el estado del artículo no
+// admite reposiciones (discontinuado o archivado).
+func (s *StockService) ReplenishStock(ctx context.Context, articleID string, quantity int, reason string) (*models.Stock, error) {
+	var stock *models.Stock
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		stockRepo := s.stockRepo.WithTx(tx)
+
+		current, err := s.lockConditionalStock(ctx, tx, articleID)
+		if err != nil {
+			return err
+		}
+		if !current.Status.AllowsReplenishment() {
+			return &models.ErrArticleUnavailable{ArticleID: articleID, Status: current.Status, Operation: "replenishment"}
+		}
+
+		stock, err = stockRepo.IncrementQuantity(ctx, articleID, quantity)
+		if err != nil {
+			return err
+		}
+
+		// Crear evento de stock en la misma transacción
+		event := &models.StockEvent{
+			ArticleID: articleID,
+			EventType: models.EventTypeReplenish,
+			Quantity:  quantity,
+			Reason:    reason,
+		}
+		event.SetBalances(stock)
+
+		return s.recordEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return stock, nil
 }
 
-// ReplenishStock repone stock de un artículo existente
-func (s *StockService) ReplenishStock(ctx context.Context, articleID string, quantity int, reason string) (*models.Stock, error) {
-	stock, err := s.stockRepo.GetStockByArticleID(ctx, articleID)
+// DeductStock descuenta stock directamente. La validación de stock disponible la hace el
+// UPDATE relativo, no una lectura previa que podría estar desactualizada.
+func (s *StockService) DeductStock(ctx context.Context, articleID string, quantity int, reason string) (*models.Stock, error) {
+	var stock *models.Stock
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := s.lockExpectedVersion(ctx, tx, articleID); err != nil {
+			return err
+		}
+
+		var err error
+		stock, err = s.stockRepo.WithTx(tx).DecrementQuantity(ctx, articleID, quantity)
+		if err != nil {
+			return err
+		}
+
+		// Crear evento de stock en la misma transacción
+		event := &models.StockEvent{
+			ArticleID: articleID,
+			EventType: models.EventTypeDeduct,
+			Quantity:  quantity,
+			Reason:    reason,
+		}
+		event.SetBalances(stock)
+
+		if err := s.recordEvent(ctx, tx, event); err != nil {
+			return err
+		}
+
+		// Verificar si está bajo y encolar la alerta en la misma transacción
+		return s.enqueueLowStockAlert(ctx, tx, articleID)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("article not found: %w", err)
+		return nil, err
 	}
 
-	newQuantity := stock.Quantity + quantity
-	if err := s.stockRepo.UpdateStockQuantity(ctx, articleID, newQuantity); err != nil {
-		return nil, fmt.Errorf("error updating stock: %w", err)
-	}
+	return stock, nil
+}
 
-	// Crear evento de stock
-	event := &models.StockEvent{
-		ArticleID: articleID,
-		EventType: models.EventTypeReplenish,
-		Quantity:  quantity,
-		Reason:    reason,
-	}
+// UpdateStockSettings actualiza el mínimo, el máximo y/o la ubicación del artículo y registra el
+// cambio como evento SETTINGS_CHANGE
+func (s *StockService) UpdateStockSettings(ctx context.Context, articleID string, req *models.UpdateStockRequest) (*models.Stock, error) {
+	var stock *models.Stock
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		current, err := s.lockConditionalStock(ctx, tx, articleID)
+		if err != nil {
+			return err
+		}
 
-	if err := s.eventRepo.CreateStockEvent(ctx, event); err != nil {
-		fmt.Printf("Warning: Could not create stock event: %v\n", err)
+		stock, err = s.updateSettings(ctx, tx, current, req)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Obtener el stock actualizado
-	return s.stockRepo.GetStockByArticleID(ctx, articleID)
+	return stock, nil
 }
 
-// DeductStock descuenta stock directamente
-func (s *StockService) DeductStock(ctx context.Context, articleID string, quantity int, reason string) (*models.Stock, error) {
-	stock, err := s.stockRepo.GetStockByArticleID(ctx, articleID)
+// updateSettings aplica el request sobre el stock ya bloqueado y registra el evento
+// SETTINGS_CHANGE. Si no hay cambios efectivos retorna el stock sin tocarlo.
+func (s *StockService) updateSettings(ctx context.Context, tx pgx.Tx, current *models.Stock, req *models.UpdateStockRequest) (*models.Stock, error) {
+	wasLowStock := current.IsLowStock()
+
+	updated := *current
+	changes, err := updated.ApplySettings(req)
 	if err != nil {
-		return nil, fmt.Errorf("article not found: %w", err)
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return current, nil
 	}
 
-	if stock.Quantity < quantity {
-		return nil, fmt.Errorf("insufficient stock: available %d, requested %d", stock.Quantity, quantity)
+	stock, err := s.stockRepo.WithTx(tx).UpdateSettings(ctx, current.ArticleID, updated.MinStock, updated.MaxStock, updated.Location)
+	if err != nil {
+		return nil, err
 	}
 
-	newQuantity := stock.Quantity - quantity
-	if err := s.stockRepo.UpdateStockQuantity(ctx, articleID, newQuantity); err != nil {
-		return nil, fmt.Errorf("error updating stock: %w", err)
+	metadata, err := json.Marshal(map[string]interface{}{"changes": changes})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding settings change: %w", err)
 	}
 
-	// Crear evento de stock
 	event := &models.StockEvent{
-		ArticleID: articleID,
-		EventType: models.EventTypeDeduct,
-		Quantity:  quantity,
-		Reason:    reason,
+		ArticleID: current.ArticleID,
+		EventType: models.EventTypeSettingsChange,
+		Quantity:  0,
+		Reason:    "Configuración del artículo actualizada",
+		Metadata:  string(metadata),
 	}
+	event.SetBalances(stock)
 
-	if err := s.eventRepo.CreateStockEvent(ctx, event); err != nil {
-		fmt.Printf("Warning: Could not create stock event: %v\n", err)
+	if err := s.recordEvent(ctx, tx, event); err != nil {
+		return nil, err
 	}
 
-	// Obtener el stock actualizado y verificar si está bajo
-	updatedStock, _ := s.stockRepo.GetStockByArticleID(ctx, articleID)
-	if updatedStock != nil && updatedStock.IsLowStock() {
-		s.messagingService.PublishLowStockAlert(ctx, articleID, updatedStock.Quantity, updatedStock.MinStock)
+	if _, ok := changes["min_stock"]; !ok || wasLowStock || !stock.IsLowStock() {
+		return stock, nil
+	}
+	if err := s.enqueueLowStockAlert(ctx, tx, current.ArticleID); err != nil {
+		return nil, err
+	}
+	return stock, nil
+}
+
+// UpdateStockStatus cambia el estado del ciclo de vida del artículo y registra la transición como
+// evento STATUS_CHANGE
+func (s *StockService) UpdateStockStatus(ctx context.Context, articleID string, status models.StockStatus, reason string) (*models.Stock, error) {
+	if !status.IsValid() {
+		return nil, fmt.Errorf("%w: %q", models.ErrInvalidStockStatus, status)
+	}
+
+	var stock *models.Stock
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		stockRepo := s.stockRepo.WithTx(tx)
+
+		current, err := s.lockConditionalStock(ctx, tx, articleID)
+		if err != nil {
+			return err
+		}
+		if current.Status == status {
+			stock = current
+			return nil
+		}
+
+		stock, err = stockRepo.UpdateStatus(ctx, articleID, status)
+		if err != nil {
+			return err
+		}
+
+		metadata, err := json.Marshal(map[string]interface{}{
+			"changes": map[string]models.StockSettingChange{
+				"status": {From: current.Status, To: status},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("error encoding status change: %w", err)
+		}
+
+		if reason == "" {
+			reason = fmt.Sprintf("Estado del artículo cambiado a %s", status)
+		}
+
+		event := &models.StockEvent{
+			ArticleID: articleID,
+			EventType: models.EventTypeStatusChange,
+			Quantity:  0,
+			Reason:    reason,
+			Metadata:  string(metadata),
+		}
+		event.SetBalances(stock)
+
+		return s.recordEvent(ctx, tx, event)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	return updatedStock, nil
+	return stock, nil
 }
 
 // ReserveStock reserva una cantidad de stock para una orden
 func (s *StockService) ReserveStock(ctx context.Context, req *models.ReserveStockRequest) error {
-	// Verificar si ya existe una reserva activa para este order_id y article_id específicos
-	hasReservation, err := s.eventRepo.HasActiveReservation(ctx, req.OrderID, req.ArticleID)
+	return s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		if err := s.lockExpectedVersion(ctx, tx, req.ArticleID); err != nil {
+			return err
+		}
+
+		reservationRepo := s.reservationRepo.WithTx(tx)
+
+		// Verificar si ya existe una reserva activa para este order_id y article_id específicos
+		existing, err := reservationRepo.GetActiveReservation(ctx, req.OrderID, req.ArticleID)
+		if err != nil {
+			return fmt.Errorf("error checking existing reservations: %w", err)
+		}
+
+		if existing != nil {
+			return fmt.Errorf("%w: order %s, article %s", models.ErrDuplicateReservation, req.OrderID, req.ArticleID)
+		}
+
+		// Verificar que hay stock suficiente y reservarlo
+		if err := s.stockRepo.WithTx(tx).ReserveStock(ctx, req.ArticleID, req.Quantity); err != nil {
+			return err
+		}
+
+		reservation := &models.StockReservation{
+			ArticleID: req.ArticleID,
+			OrderID:   req.OrderID,
+			Quantity:  req.Quantity,
+			Status:    models.ReservationStatusActive,
+			ExpiresAt: s.reservationExpiry(),
+		}
+
+		if err := reservationRepo.CreateReservation(ctx, reservation); err != nil {
+			return err
+		}
+
+		// Crear evento de stock en la misma transacción
+		event := &models.StockEvent{
+			ArticleID: req.ArticleID,
+			EventType: models.EventTypeReserve,
+			Quantity:  req.Quantity,
+			OrderID:   &req.OrderID,
+			Reason:    fmt.Sprintf("Stock reservado para orden %s", req.OrderID),
+		}
+
+		return s.recordEvent(ctx, tx, event)
+	})
+}
+
+// ReserveOrder reserva todas las líneas de una orden o ninguna
+func (s *StockService) ReserveOrder(ctx context.Context, orderID string, items []models.OrderReservationItem) (*models.OrderReservationResult, error) {
+	return s.ReserveOrderWithPolicy(ctx, orderID, items, models.ReservationPolicyAllOrNothing)
+}
+
+// ReserveOrderWithPolicy reserva las líneas de una orden aplicando la política indicada cuando
+// falta stock
+func (s *StockService) ReserveOrderWithPolicy(ctx context.Context, orderID string, items []models.OrderReservationItem, policy models.ReservationPolicy) (*models.OrderReservationResult, error) {
+	return s.reserveOrder(ctx, orderID, items, policy, false)
+}
+
+// ProcessPlacedOrder reserva una orden recibida por mensajería y encola la respuesta
+// insufficient_stock si alguna línea queda con faltante
+func (s *StockService) ProcessPlacedOrder(ctx context.Context, orderID string, items []models.OrderReservationItem, policy models.ReservationPolicy) (*models.OrderReservationResult, error) {
+	return s.reserveOrder(ctx, orderID, items, policy, true)
+}
+
+func (s *StockService) reserveOrder(ctx context.Context, orderID string, items []models.OrderReservationItem, policy models.ReservationPolicy, notifyShortage bool) (*models.OrderReservationResult, error) {
+	notifyShortage = notifyShortage && s.publishers.OrderShortage != nil
+
+	if orderID == "" {
+		return nil, fmt.Errorf("%w: order_id is required", models.ErrInvalidOrder)
+	}
+
+	if policy == "" {
+		policy = models.ReservationPolicyAllOrNothing
+	}
+	if !policy.IsValid() {
+		return nil, fmt.Errorf("%w: unknown reservation policy %q", models.ErrInvalidOrder, policy)
+	}
+
+	lines, err := mergeOrderItems(items)
 	if err != nil {
-		return fmt.Errorf("error checking existing reservations: %w", err)
+		return nil, err
 	}
 
-	if hasReservation {
-		return fmt.Errorf("order %s already has an active reservation for article %s", req.OrderID, req.ArticleID)
+	result := &models.OrderReservationResult{OrderID: orderID, Policy: policy}
+
+	err = s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		stockRepo := s.stockRepo.WithTx(tx)
+		reservationRepo := s.reservationRepo.WithTx(tx)
+
+		var shortages []error
+		articleIDs := make([]string, len(lines))
+		for i, line := range lines {
+			articleIDs[i] = line.ArticleID
+		}
+
+		stocks, err := stockRepo.LockStocksForUpdate(ctx, articleIDs)
+		if err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			available := 0
+			stock, ok := stocks[line.ArticleID]
+			if ok {
+				existing, err := reservationRepo.GetActiveReservation(ctx, orderID, line.ArticleID)
+				if err != nil {
+					return fmt.Errorf("error checking existing reservations: %w", err)
+				}
+				if existing != nil {
+					return fmt.Errorf("%w: order %s, article %s", models.ErrDuplicateReservation, orderID, line.ArticleID)
+				}
+				if stock.Status.AllowsReservations() {
+					available = stock.AvailableQuantity()
+				} else if policy == models.ReservationPolicyAllOrNothing {
+					return &models.ErrArticleUnavailable{ArticleID: line.ArticleID, Status: stock.Status, Operation: "reservations"}
+				}
+			} else if policy == models.ReservationPolicyAllOrNothing {
+				return fmt.Errorf("%w: %s", models.ErrArticleNotFound, line.ArticleID)
+			}
+
+			reserved := reservableQuantity(policy, line.Quantity, available)
+			result.Lines = append(result.Lines, models.OrderReservationLine{
+				ArticleID: line.ArticleID,
+				Requested: line.Quantity,
+				Reserved:  reserved,
+				Short:     line.Quantity - reserved,
+			})
+			if reserved < line.Quantity {
+				result.InsufficientArticles = append(result.InsufficientArticles, line.ArticleID)
+				shortages = append(shortages, &models.ErrInsufficientStock{
+					ArticleID: line.ArticleID,
+					Available: available,
+					Requested: line.Quantity,
+				})
+			}
+		}
+
+		if policy == models.ReservationPolicyAllOrNothing && result.IsPartial() {
+			return fmt.Errorf("insufficient stock for order %s: %w", orderID, errors.Join(shortages...))
+		}
+
+		for _, line := range result.Lines {
+			if line.Reserved == 0 {
+				continue
+			}
+
+			if err := stockRepo.IncrementReserved(ctx, line.ArticleID, line.Reserved); err != nil {
+				return err
+			}
+
+			reservation := &models.StockReservation{
+				ArticleID: line.ArticleID,
+				OrderID:   orderID,
+				Quantity:  line.Reserved,
+				Status:    models.ReservationStatusActive,
+				ExpiresAt: s.reservationExpiry(),
+			}
+			if err := reservationRepo.CreateReservation(ctx, reservation); err != nil {
+				return err
+			}
+
+			reason := fmt.Sprintf("Stock reservado para orden %s", orderID)
+			if line.Short > 0 {
+				reason = fmt.Sprintf("Stock reservado parcialmente para orden %s (faltan %d)", orderID, line.Short)
+			}
+
+			event := &models.StockEvent{
+				ArticleID: line.ArticleID,
+				EventType: models.EventTypeReserve,
+				Quantity:  line.Reserved,
+				OrderID:   &orderID,
+				Reason:    reason,
+			}
+			if err := s.recordEvent(ctx, tx, event); err != nil {
+				return err
+			}
+
+			result.Reservations = append(result.Reservations, reservation)
+		}
+
+		if notifyShortage && result.IsPartial() {
+			if err := s.publishers.OrderShortage.PublishOrderShortage(ctx, tx, result); err != nil {
+				return fmt.Errorf("error enqueuing insufficient stock message: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		if !isShortageError(err) {
+			return nil, err
+		}
+
+		// Nada quedó reservado: todas las líneas pasan a faltante
+		result.Reservations = nil
+		result.Lines = make([]models.OrderReservationLine, 0, len(lines))
+		result.InsufficientArticles = make([]string, 0, len(lines))
+		for _, line := range lines {
+			result.Lines = append(result.Lines, models.OrderReservationLine{
+				ArticleID: line.ArticleID,
+				Requested: line.Quantity,
+				Short:     line.Quantity,
+			})
+			result.InsufficientArticles = append(result.InsufficientArticles, line.ArticleID)
+		}
+
+		if notifyShortage {
+			notifyErr := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+				return s.publishers.OrderShortage.PublishOrderShortage(ctx, tx, result)
+			})
+			if notifyErr != nil {
+				return result, fmt.Errorf("%w (error enqueuing insufficient stock message: %v)", err, notifyErr)
+			}
+		}
+
+		return result, err
 	}
 
-	// Verificar que hay stock suficiente y reservarlo
-	if err := s.stockRepo.ReserveStock(ctx, req.ArticleID, req.Quantity); err != nil {
-		return fmt.Errorf("error reserving stock: %w", err)
+	return result, nil
+}
+
+// isShortageError indica si la reserva falló por falta de stock, por un artículo inexistente o
+// por un estado que no admite reservas
+func isShortageError(err error) bool {
+	var insufficientStock *models.ErrInsufficientStock
+	var articleUnavailable *models.ErrArticleUnavailable
+	return errors.As(err, &insufficientStock) || errors.As(err, &articleUnavailable) ||
+		errors.Is(err, models.ErrArticleNotFound)
+}
+
+// reservableQuantity calcula cuánto reservar de una línea según la política
+func reservableQuantity(policy models.ReservationPolicy, requested, available int) int {
+	if available >= requested {
+		return requested
 	}
 
-	// Crear evento de stock
-	event := &models.StockEvent{
-		ArticleID: req.ArticleID,
-		EventType: models.EventTypeReserve,
-		Quantity:  req.Quantity,
-		OrderID:   &req.OrderID,
-		Reason:    fmt.Sprintf("Stock reservado para orden %s", req.OrderID),
+	if policy == models.ReservationPolicyReserveAvailable && available > 0 {
+		return available
+	}
+
+	return 0
+}
+
+// mergeOrderItems valida las líneas de una orden, agrupa los artículos repetidos
+// y las ordena por article_id
+func mergeOrderItems(items []models.OrderReservationItem) ([]models.OrderReservationItem, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("%w: at least one item is required", models.ErrInvalidOrder)
 	}
 
-	if err := s.eventRepo.CreateStockEvent(ctx, event); err != nil {
-		fmt.Printf("Warning: Could not create stock event: %v\n", err)
+	quantities := make(map[string]int, len(items))
+	for _, item := range items {
+		if item.ArticleID == "" {
+			return nil, fmt.Errorf("%w: article_id is required", models.ErrInvalidOrder)
+		}
+		if item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: quantity must be greater than 0 for article %s", models.ErrInvalidOrder, item.ArticleID)
+		}
+		quantities[item.ArticleID] += item.Quantity
 	}
 
-	return nil
+	lines := make([]models.OrderReservationItem, 0, len(quantities))
+	for articleID, quantity := range quantities {
+		lines = append(lines, models.OrderReservationItem{ArticleID: articleID, Quantity: quantity})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].ArticleID < lines[j].ArticleID
+	})
+
+	return lines, nil
 }
 
-// GetStock obtiene información de stock por artículo
+// GetStock obtiene información de stock por artículo. Dentro de una transacción lee sin caché
+// y sin bloquear la fila, para ver los cambios aún sin confirmar.
 func (s *StockService) GetStock(ctx context.Context, articleID string) (*models.Stock, error) {
+	if tx, ok := repository.TxFromContext(ctx); ok {
+		return s.stockRepo.WithTx(tx).GetStockUncached(ctx, articleID)
+	}
 	return s.stockRepo.GetStockByArticleID(ctx, articleID)
 }
 
-// GetAllStocks obtiene todos los stocks
-func (s *StockService) GetAllStocks(ctx context.Context) ([]*models.Stock, error) {
-	return s.stockRepo.GetAllStocks(ctx)
+// GetCurrentStock obtiene el stock sin caché, para que el ETag sea el de la versión vigente
+func (s *StockService) GetCurrentStock(ctx context.Context, articleID string) (*models.Stock, error) {
+	if tx, ok := repository.TxFromContext(ctx); ok {
+		return s.stockRepo.WithTx(tx).GetStockUncached(ctx, articleID)
+	}
+	return s.stockRepo.GetStockUncached(ctx, articleID)
+}
+
+// ListStocks busca artículos según el filtro y retorna una página con el total y el cursor de la
+// siguiente
+func (s *StockService) ListStocks(ctx context.Context, filter models.StockFilter) (*models.StockPage, error) {
+	if filter.Sort == "" {
+		filter.Sort = models.StockSortCreatedAt
+		filter.Desc = true
+	}
+	if filter.After != nil && (filter.After.Sort != filter.Sort || filter.After.Desc != filter.Desc) {
+		return nil, fmt.Errorf("%w: cursor was issued for a different sort order", models.ErrInvalidCursor)
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = models.DefaultStockPageSize
+	}
+	if filter.Limit > models.MaxStockPageSize {
+		filter.Limit = models.MaxStockPageSize
+	}
+
+	total, err := s.stockRepo.CountStocks(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pedir un artículo de más para saber si existe una página siguiente
+	pageSize := filter.Limit
+	filter.Limit++
+
+	stocks, err := s.stockRepo.ListStocks(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.StockPage{Stocks: stocks, Total: total}
+	if len(stocks) > pageSize {
+		page.Stocks = stocks[:pageSize]
+		next := models.NewStockCursor(page.Stocks[pageSize-1], filter.Sort, filter.Desc).Encode()
+		page.NextCursor = &next
+	}
+	page.Count = len(page.Stocks)
+
+	return page, nil
 }
 
-// GetStockEvents obtiene eventos de stock por artículo
-func (s *StockService) GetStockEvents(ctx context.Context, articleID string, limit int) ([]*models.StockEvent, error) {
-	if limit <= 0 {
-		limit = 50 // límite por defecto
+// ListStockEvents busca eventos de stock según el filtro y retorna una página con el cursor
+// de la siguiente, si la hay
+func (s *StockService) ListStockEvents(ctx context.Context, filter models.StockEventFilter) (*models.StockEventPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = models.DefaultEventPageSize
 	}
-	return s.eventRepo.GetStockEventsByArticleID(ctx, articleID, limit)
+	if filter.Limit > models.MaxEventPageSize {
+		filter.Limit = models.MaxEventPageSize
+	}
+
+	// Pedir un evento de más para saber si existe una página siguiente
+	pageSize := filter.Limit
+	filter.Limit++
+
+	events, err := s.eventRepo.ListStockEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.StockEventPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		last := page.Events[pageSize-1]
+		next := models.EventCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+		page.NextCursor = &next
+	}
+	page.Count = len(page.Events)
+
+	return page, nil
 }
 
 // GetLowStocks obtiene artículos con stock bajo
@@ -188,134 +680,292 @@ func (s *StockService) GetLowStocks(ctx context.Context) ([]*models.Stock, error
 	return s.stockRepo.GetLowStocks(ctx)
 }
 
+// GetReservationsByOrderID obtiene las reservas de una orden
+func (s *StockService) GetReservationsByOrderID(ctx context.Context, orderID string) ([]*models.StockReservation, error) {
+	return s.reservationRepo.GetReservationsByOrderID(ctx, orderID)
+}
+
 // CancelReservationByOrderID cancela una reserva usando order_id y article_id
 func (s *StockService) CancelReservationByOrderID(ctx context.Context, orderID, articleID, reason string) error {
-	// Buscar eventos de reserva para este order_id y article_id
-	events, err := s.eventRepo.GetStockEventsByOrderID(ctx, orderID)
+	return s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		// El artículo se bloquea antes que la reserva, el mismo orden que usa el sweeper
+		if err := s.lockStock(ctx, tx, articleID); err != nil {
+			return err
+		}
+
+		reservation, err := s.transitionReservation(ctx, tx, orderID, articleID, models.ReservationStatusCancelled)
+		if err != nil {
+			return err
+		}
+
+		// Liberar el stock reservado
+		if err := s.stockRepo.WithTx(tx).CancelReservation(ctx, articleID, reservation.Quantity); err != nil {
+			return fmt.Errorf("error canceling stock reservation: %w", err)
+		}
+
+		// Crear evento de cancelación
+		cancelReason := reason
+		if cancelReason == "" {
+			cancelReason = fmt.Sprintf("Reserva cancelada para orden %s", orderID)
+		}
+
+		event := &models.StockEvent{
+			ArticleID: articleID,
+			EventType: models.EventTypeCancelReserve,
+			Quantity:  reservation.Quantity,
+			OrderID:   &orderID,
+			Reason:    cancelReason,
+		}
+
+		return s.recordEvent(ctx, tx, event)
+	})
+}
+
+// ConfirmReservationByOrderID confirma una reserva usando order_id y article_id
+func (s *StockService) ConfirmReservationByOrderID(ctx context.Context, orderID, articleID, reason string) error {
+	return s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		// El artículo se bloquea antes que la reserva, el mismo orden que usa el sweeper
+		if err := s.lockStock(ctx, tx, articleID); err != nil {
+			return err
+		}
+
+		reservation, err := s.transitionReservation(ctx, tx, orderID, articleID, models.ReservationStatusConfirmed)
+		if err != nil {
+			return err
+		}
+
+		// Confirmar la reserva (descontar stock y liberar reserved)
+		if err := s.stockRepo.WithTx(tx).ConfirmReservation(ctx, articleID, reservation.Quantity); err != nil {
+			return fmt.Errorf("error confirming reservation: %w", err)
+		}
+
+		// Crear evento de confirmación
+		confirmReason := reason
+		if confirmReason == "" {
+			confirmReason = fmt.Sprintf("Stock descontado por confirmación de orden %s", orderID)
+		}
+
+		event := &models.StockEvent{
+			ArticleID: articleID,
+			EventType: models.EventTypeConfirmReserve,
+			Quantity:  reservation.Quantity,
+			OrderID:   &orderID,
+			Reason:    confirmReason,
+		}
+
+		if err := s.recordEvent(ctx, tx, event); err != nil {
+			return err
+		}
+
+		// Verificar si el stock está bajo después de la confirmación
+		return s.enqueueLowStockAlert(ctx, tx, articleID)
+	})
+}
+
+// checkExpectedVersion verifica que el artículo, ya bloqueado, tenga una de las versiones
+// pedidas con If-Match (AnyVersion acepta cualquiera). Sin condición en el contexto no hace nada.
+func checkExpectedVersion(ctx context.Context, stock *models.Stock) error {
+	versions, ok := models.ExpectedVersionsFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	for _, version := range versions {
+		if version == models.AnyVersion || version == stock.Version {
+			return nil
+		}
+	}
+	return &models.ErrVersionMismatch{ArticleID: stock.ArticleID, CurrentVersion: stock.Version}
+}
+
+// lockConditionalStock bloquea el artículo y verifica la versión pedida con If-Match, si la hay.
+// Con una condición, un artículo inexistente falla con ErrVersionMismatch.
+func (s *StockService) lockConditionalStock(ctx context.Context, tx pgx.Tx, articleID string) (*models.Stock, error) {
+	stock, err := s.stockRepo.WithTx(tx).GetStockForUpdate(ctx, articleID)
 	if err != nil {
-		return fmt.Errorf("error getting events for order: %w", err)
-	}
-
-	var reserveEvent *models.StockEvent
-	var hasCancel, hasConfirm bool
-
-	// Buscar el evento de reserva y verificar si ya fue cancelada o confirmada
-	for _, event := range events {
-		if event.ArticleID == articleID {
-			switch event.EventType {
-			case models.EventTypeReserve:
-				reserveEvent = event
-			case models.EventTypeCancelReserve:
-				hasCancel = true
-			case models.EventTypeDeduct: // DEDUCT representa confirmación
-				hasConfirm = true
-			}
+		if _, ok := models.ExpectedVersionsFromContext(ctx); ok && errors.Is(err, models.ErrArticleNotFound) {
+			return nil, &models.ErrVersionMismatch{ArticleID: articleID}
 		}
+		return nil, err
 	}
+	if err := checkExpectedVersion(ctx, stock); err != nil {
+		return nil, err
+	}
+	return stock, nil
+}
 
-	if reserveEvent == nil {
-		return fmt.Errorf("no active reservation found for this order and article")
+// lockExpectedVersion bloquea el artículo y verifica la versión pedida con If-Match, en las
+// operaciones que no leen el artículo antes de modificarlo
+func (s *StockService) lockExpectedVersion(ctx context.Context, tx pgx.Tx, articleID string) error {
+	if _, ok := models.ExpectedVersionsFromContext(ctx); !ok {
+		return nil
 	}
 
-	if hasCancel {
-		return fmt.Errorf("reservation has already been cancelled")
+	_, err := s.lockConditionalStock(ctx, tx, articleID)
+	return err
+}
+
+// lockStock es como lockConditionalStock pero no retorna el stock
+func (s *StockService) lockStock(ctx context.Context, tx pgx.Tx, articleID string) error {
+	_, err := s.lockConditionalStock(ctx, tx, articleID)
+	return err
+}
+
+// recordEvent registra el evento con los saldos del artículo y la atribución del contexto. Debe
+// llamarse después de actualizar el stock.
+func (s *StockService) recordEvent(ctx context.Context, tx pgx.Tx, event *models.StockEvent) error {
+	if event.ActorType == "" {
+		event.Attribution = models.AttributionFromContext(ctx)
 	}
 
-	if hasConfirm {
-		return fmt.Errorf("reservation has already been confirmed")
+	if event.QuantityAfter == nil {
+		stock, err := s.stockRepo.WithTx(tx).GetStockForUpdate(ctx, event.ArticleID)
+		if err != nil {
+			return err
+		}
+		event.SetBalances(stock)
 	}
 
-	// Liberar el stock reservado
-	if err := s.stockRepo.CancelReservation(ctx, articleID, reserveEvent.Quantity); err != nil {
-		return fmt.Errorf("error canceling stock reservation: %w", err)
+	return s.eventRepo.WithTx(tx).CreateStockEvent(ctx, event)
+}
+
+// enqueueLowStockAlert relee el stock dentro de la transacción y, si quedó por debajo
+// del mínimo, encola la alerta en el outbox. Los artículos archivados no generan alertas.
+func (s *StockService) enqueueLowStockAlert(ctx context.Context, tx pgx.Tx, articleID string) error {
+	if s.publishers.LowStock == nil {
+		return nil
 	}
 
-	// Crear evento de cancelación
-	cancelReason := reason
-	if cancelReason == "" {
-		cancelReason = fmt.Sprintf("Reserva cancelada para orden %s", orderID)
+	stock, err := s.stockRepo.WithTx(tx).GetStockForUpdate(ctx, articleID)
+	if err != nil {
+		return err
 	}
 
-	event := &models.StockEvent{
-		ArticleID: articleID,
-		EventType: models.EventTypeCancelReserve,
-		Quantity:  reserveEvent.Quantity,
-		OrderID:   &orderID,
-		Reason:    cancelReason,
+	if !stock.IsLowStock() || stock.Status == models.StockStatusArchived {
+		return nil
 	}
 
-	if err := s.eventRepo.CreateStockEvent(ctx, event); err != nil {
-		fmt.Printf("Warning: Could not create stock event: %v\n", err)
+	if err := s.publishers.LowStock.PublishLowStockAlert(ctx, tx, articleID, stock.Quantity, stock.MinStock); err != nil {
+		return fmt.Errorf("error enqueuing low stock alert: %w", err)
 	}
 
 	return nil
 }
 
-// ConfirmReservationByOrderID confirma una reserva usando order_id y article_id
-func (s *StockService) ConfirmReservationByOrderID(ctx context.Context, orderID, articleID, reason string) error {
-	// Buscar eventos de reserva para este order_id y article_id
-	events, err := s.eventRepo.GetStockEventsByOrderID(ctx, orderID)
+// ExpireReservations libera un lote de reservas vencidas, cada una en su propia transacción, y
+// retorna las que se expiraron
+func (s *StockService) ExpireReservations(ctx context.Context, limit int) ([]*models.StockReservation, error) {
+	now := time.Now()
+
+	candidates, err := s.reservationRepo.ListExpiredReservations(ctx, now, limit)
 	if err != nil {
-		return fmt.Errorf("error getting events for order: %w", err)
-	}
-
-	var reserveEvent *models.StockEvent
-	var hasCancel, hasConfirm bool
-
-	// Buscar el evento de reserva y verificar si ya fue cancelada o confirmada
-	for _, event := range events {
-		if event.ArticleID == articleID {
-			switch event.EventType {
-			case models.EventTypeReserve:
-				reserveEvent = event
-			case models.EventTypeCancelReserve:
-				hasCancel = true
-			case models.EventTypeDeduct: // DEDUCT representa confirmación
-				hasConfirm = true
-			}
-		}
+		return nil, fmt.Errorf("error expiring reservations: %w", err)
 	}
 
-	if reserveEvent == nil {
-		return fmt.Errorf("no active reservation found for this order and article")
+	var expired []*models.StockReservation
+	for _, candidate := range candidates {
+		reservation, err := s.expireReservation(ctx, candidate, now)
+		if err != nil {
+			log.Printf("StockService: Failed to expire reservation %s (order %s, article %s): %v",
+				candidate.ID, candidate.OrderID, candidate.ArticleID, err)
+			continue
+		}
+		if reservation != nil {
+			expired = append(expired, reservation)
+		}
 	}
 
-	if hasCancel {
-		return fmt.Errorf("reservation has already been cancelled")
-	}
+	return expired, nil
+}
 
-	if hasConfirm {
-		return fmt.Errorf("reservation has already been confirmed")
+// expireReservation expira la reserva si sigue activa; retorna nil si otra transacción ya la cerró
+func (s *StockService) expireReservation(ctx context.Context, candidate *models.StockReservation, now time.Time) (*models.StockReservation, error) {
+	var expired *models.StockReservation
+
+	err := s.txManager.WithTx(ctx, func(tx pgx.Tx) error {
+		if _, err := s.stockRepo.WithTx(tx).GetStockForUpdate(ctx, candidate.ArticleID); err != nil {
+			return err
+		}
+
+		reservation, err := s.reservationRepo.WithTx(tx).LockExpiredReservation(ctx, candidate.ID, now)
+		if err != nil || reservation == nil {
+			return err
+		}
+
+		if err := s.stockRepo.WithTx(tx).CancelReservation(ctx, reservation.ArticleID, reservation.Quantity); err != nil {
+			return fmt.Errorf("error releasing reserved stock: %w", err)
+		}
+
+		if err := s.reservationRepo.WithTx(tx).UpdateReservationStatus(ctx, reservation.ID, reservation.Status, models.ReservationStatusExpired); err != nil {
+			return err
+		}
+
+		orderID := reservation.OrderID
+		event := &models.StockEvent{
+			ArticleID: reservation.ArticleID,
+			EventType: models.EventTypeExpireReserve,
+			Quantity:  reservation.Quantity,
+			OrderID:   &orderID,
+			Reason:    fmt.Sprintf("Reserva vencida para orden %s", orderID),
+		}
+
+		// La causa de la expiración es la propia reserva
+		event.Attribution = models.AttributionFromContext(ctx)
+		event.CausationID = reservation.ID.String()
+
+		if err := s.recordEvent(ctx, tx, event); err != nil {
+			return err
+		}
+
+		reservation.Status = models.ReservationStatusExpired
+
+		if s.publishers.ReservationExpired != nil {
+			if err := s.publishers.ReservationExpired.PublishReservationExpired(ctx, tx, reservation); err != nil {
+				return fmt.Errorf("error enqueuing reservation expired message: %w", err)
+			}
+		}
+
+		expired = reservation
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Confirmar la reserva (descontar stock y liberar reserved)
-	if err := s.stockRepo.ConfirmReservation(ctx, articleID, reserveEvent.Quantity); err != nil {
-		return fmt.Errorf("error confirming reservation: %w", err)
+	return expired, nil
+}
+
+// reservationExpiry calcula el vencimiento de una nueva reserva; nil si no hay TTL configurado
+func (s *StockService) reservationExpiry() *time.Time {
+	if s.reservationTTL <= 0 {
+		return nil
 	}
+	expiresAt := time.Now().Add(s.reservationTTL)
+	return &expiresAt
+}
 
-	// Crear evento de confirmación
-	confirmReason := reason
-	if confirmReason == "" {
-		confirmReason = fmt.Sprintf("Stock descontado por confirmación de orden %s", orderID)
+// transitionReservation mueve la reserva más reciente de la orden al estado indicado. Debe
+// llamarse con el artículo ya bloqueado.
+func (s *StockService) transitionReservation(ctx context.Context, tx pgx.Tx, orderID, articleID string, next models.ReservationStatus) (*models.StockReservation, error) {
+	reservationRepo := s.reservationRepo.WithTx(tx)
+
+	reservation, err := reservationRepo.GetLatestReservationForUpdate(ctx, orderID, articleID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting reservation for order: %w", err)
 	}
 
-	event := &models.StockEvent{
-		ArticleID: articleID,
-		EventType: models.EventTypeDeduct,
-		Quantity:  reserveEvent.Quantity,
-		OrderID:   &orderID,
-		Reason:    confirmReason,
+	if reservation == nil {
+		return nil, models.ErrReservationNotFound
 	}
 
-	if err := s.eventRepo.CreateStockEvent(ctx, event); err != nil {
-		fmt.Printf("Warning: Could not create stock event: %v\n", err)
+	if !reservation.Status.CanTransitionTo(next) {
+		return nil, &models.ErrReservationClosed{Status: reservation.Status}
 	}
 
-	// Verificar si el stock está bajo después de la confirmación
-	stock, _ := s.stockRepo.GetStockByArticleID(ctx, articleID)
-	if stock != nil && stock.IsLowStock() {
-		s.messagingService.PublishLowStockAlert(ctx, articleID, stock.Quantity, stock.MinStock)
+	if err := reservationRepo.UpdateReservationStatus(ctx, reservation.ID, reservation.Status, next); err != nil {
+		return nil, err
 	}
+	reservation.Status = next
 
-	return nil
+	return reservation, nil
 }
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/MatiasTelo/stockgo/internal/models"
+)
+
+// StockSnapshotter registra periódicamente snapshots del stock de todos los artículos
+type StockSnapshotter struct {
+	historyService *StockHistoryService
+	interval       time.Duration
+	settleDelay    time.Duration
+}
+
+func NewStockSnapshotter(historyService *StockHistoryService, interval, settleDelay time.Duration) *StockSnapshotter {
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+	if settleDelay < 0 {
+		settleDelay = 0
+	}
+
+	return &StockSnapshotter{
+		historyService: historyService,
+		interval:       interval,
+		settleDelay:    settleDelay,
+	}
+}
+
+// Start inicia las snapshots periódicas en segundo plano hasta que se cancele el contexto.
+// Verifica con más frecuencia que el intervalo para no depender de cuándo arrancó la réplica.
+func (s *StockSnapshotter) Start(ctx context.Context) {
+	checkInterval := s.interval
+	if checkInterval > time.Hour {
+		checkInterval = time.Hour
+	}
+
+	log.Printf("StockSnapshotter: Started, taking snapshots every %s", s.interval)
+
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+
+		s.snapshot(ctx)
+
+		for {
+			select {
+			case <-ctx.Done():
+				log.Println("StockSnapshotter: Context cancelled, stopping snapshotter")
+				return
+			case <-ticker.C:
+				s.snapshot(ctx)
+			}
+		}
+	}()
+}
+
+// snapshot registra una corrida de snapshots si corresponde
+func (s *StockSnapshotter) snapshot(ctx context.Context) {
+	cut := time.Now().Add(-s.settleDelay).Truncate(time.Second)
+
+	taken, err := s.historyService.TakeSnapshots(ctx, cut, s.interval)
+	if err == models.ErrSnapshotInProgress {
+		return
+	}
+	if err != nil {
+		log.Printf("StockSnapshotter: Error taking snapshots: %v", err)
+		return
+	}
+
+	if taken > 0 {
+		log.Printf("StockSnapshotter: Took %d snapshot(s) as of %s", taken, cut.Format(time.RFC3339))
+	}
+}
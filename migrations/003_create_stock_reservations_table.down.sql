@@ -0,0 +1,6 @@
+-- Drop stock_reservations table
+DROP INDEX IF EXISTS idx_stock_reservations_status;
+DROP INDEX IF EXISTS idx_stock_reservations_article_id;
+DROP INDEX IF EXISTS idx_stock_reservations_order_article;
+DROP INDEX IF EXISTS idx_stock_reservations_active_order_article;
+DROP TABLE IF EXISTS stock_reservations;
@@ -0,0 +1,48 @@
+-- Create stock_reservations table
+CREATE TABLE IF NOT EXISTS stock_reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    article_id VARCHAR(100) NOT NULL,
+    order_id VARCHAR(100) NOT NULL,
+    quantity INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE',
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    -- Constraints
+    CONSTRAINT chk_reservation_status CHECK (status IN ('ACTIVE', 'CONFIRMED', 'CANCELLED', 'EXPIRED')),
+    CONSTRAINT chk_reservation_quantity_positive CHECK (quantity > 0)
+);
+
+-- Only one active reservation per order and article
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_reservations_active_order_article
+    ON stock_reservations(order_id, article_id) WHERE status = 'ACTIVE';
+
+-- Create indexes for better query performance
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_order_article ON stock_reservations(order_id, article_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_article_id ON stock_reservations(article_id);
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_status ON stock_reservations(status);
+
+-- Backfill reservations from the existing event history (latest RESERVE per order and article)
+INSERT INTO stock_reservations (article_id, order_id, quantity, status, created_at, updated_at)
+SELECT r.article_id, r.order_id, r.quantity,
+    CASE
+        WHEN EXISTS (
+            SELECT 1 FROM stock_events c
+            WHERE c.order_id = r.order_id AND c.article_id = r.article_id
+            AND c.event_type = 'CANCEL_RESERVE' AND c.created_at > r.created_at
+        ) THEN 'CANCELLED'
+        WHEN EXISTS (
+            SELECT 1 FROM stock_events d
+            WHERE d.order_id = r.order_id AND d.article_id = r.article_id
+            AND d.event_type = 'DEDUCT' AND d.created_at > r.created_at
+        ) THEN 'CONFIRMED'
+        ELSE 'ACTIVE'
+    END,
+    r.created_at, NOW()
+FROM (
+    SELECT DISTINCT ON (order_id, article_id) article_id, order_id, quantity, created_at
+    FROM stock_events
+    WHERE event_type = 'RESERVE' AND order_id IS NOT NULL AND quantity > 0
+    ORDER BY order_id, article_id, created_at DESC
+) r;
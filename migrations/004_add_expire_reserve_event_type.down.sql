@@ -0,0 +1,6 @@
+-- Remove EXPIRE_RESERVE event type
+DROP INDEX IF EXISTS idx_stock_reservations_active_expires_at;
+DELETE FROM stock_events WHERE event_type = 'EXPIRE_RESERVE';
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'LOW_STOCK'));
@@ -0,0 +1,8 @@
+-- Allow EXPIRE_RESERVE events for reservations released by the expiry sweeper
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'LOW_STOCK', 'EXPIRE_RESERVE'));
+
+-- Index for the expiry sweeper
+CREATE INDEX IF NOT EXISTS idx_stock_reservations_active_expires_at
+    ON stock_reservations(expires_at) WHERE status = 'ACTIVE' AND expires_at IS NOT NULL;
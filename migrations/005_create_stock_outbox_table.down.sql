@@ -0,0 +1,5 @@
+-- Drop stock_outbox table
+DROP INDEX IF EXISTS idx_stock_outbox_published_at;
+DROP INDEX IF EXISTS idx_stock_outbox_pending_aggregate;
+DROP INDEX IF EXISTS idx_stock_outbox_pending;
+DROP TABLE IF EXISTS stock_outbox;
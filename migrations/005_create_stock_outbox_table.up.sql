@@ -0,0 +1,27 @@
+-- Create stock_outbox table
+CREATE TABLE IF NOT EXISTS stock_outbox (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    sequence BIGSERIAL NOT NULL UNIQUE,
+    aggregate_type VARCHAR(50) NOT NULL,
+    aggregate_id VARCHAR(100) NOT NULL,
+    exchange VARCHAR(255) NOT NULL,
+    routing_key VARCHAR(255) NOT NULL,
+    payload JSONB NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    published_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Pending messages in publication order
+CREATE INDEX IF NOT EXISTS idx_stock_outbox_pending
+    ON stock_outbox(sequence) WHERE published_at IS NULL;
+
+-- Pending messages per aggregate (ordering guarantee)
+CREATE INDEX IF NOT EXISTS idx_stock_outbox_pending_aggregate
+    ON stock_outbox(aggregate_type, aggregate_id, sequence) WHERE published_at IS NULL;
+
+-- Cleanup of published messages
+CREATE INDEX IF NOT EXISTS idx_stock_outbox_published_at
+    ON stock_outbox(published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Drop processed_messages table
+DROP INDEX IF EXISTS idx_processed_messages_processed_at;
+DROP TABLE IF EXISTS processed_messages;
@@ -0,0 +1,14 @@
+-- Create processed_messages table (idempotency ledger for consumers)
+CREATE TABLE IF NOT EXISTS processed_messages (
+    consumer VARCHAR(100) NOT NULL,
+    message_id VARCHAR(255) NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    result JSONB,
+    error TEXT,
+    processed_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (consumer, message_id),
+    CONSTRAINT chk_processed_outcome CHECK (outcome IN ('PROCESSING', 'SUCCEEDED', 'REJECTED'))
+);
+
+-- Cleanup of old ledger entries
+CREATE INDEX IF NOT EXISTS idx_processed_messages_processed_at ON processed_messages(processed_at);
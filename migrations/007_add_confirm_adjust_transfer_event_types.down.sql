@@ -0,0 +1,12 @@
+-- Restore backfilled confirmations and remove the new event types
+UPDATE stock_events
+SET event_type = 'DEDUCT',
+    metadata = metadata - 'backfilled_from'
+WHERE event_type = 'CONFIRM_RESERVE' AND metadata ? 'backfilled_from';
+
+UPDATE stock_events SET event_type = 'DEDUCT' WHERE event_type = 'CONFIRM_RESERVE';
+DELETE FROM stock_events WHERE event_type IN ('ADJUST', 'TRANSFER_OUT', 'TRANSFER_IN');
+
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'LOW_STOCK', 'EXPIRE_RESERVE'));
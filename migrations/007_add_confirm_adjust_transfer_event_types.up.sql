@@ -0,0 +1,22 @@
+-- Allow CONFIRM_RESERVE, ADJUST, TRANSFER_OUT and TRANSFER_IN events
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'CONFIRM_RESERVE',
+                          'EXPIRE_RESERVE', 'ADJUST', 'TRANSFER_OUT', 'TRANSFER_IN', 'LOW_STOCK'));
+
+-- Reclassify historical confirmations: until now a confirmed reservation was stored as an
+-- order-linked DEDUCT following the RESERVE of the same order and article. Manual deductions
+-- never carry an order_id. Reclassified rows are tagged in metadata so the down migration
+-- can restore them exactly.
+UPDATE stock_events d
+SET event_type = 'CONFIRM_RESERVE',
+    metadata = COALESCE(d.metadata, '{}'::jsonb) || '{"backfilled_from": "DEDUCT"}'::jsonb
+WHERE d.event_type = 'DEDUCT'
+  AND d.order_id IS NOT NULL
+  AND EXISTS (
+      SELECT 1 FROM stock_events r
+      WHERE r.event_type = 'RESERVE'
+        AND r.order_id = d.order_id
+        AND r.article_id = d.article_id
+        AND r.created_at <= d.created_at
+  );
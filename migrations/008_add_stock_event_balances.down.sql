@@ -0,0 +1,6 @@
+-- Remove stock event balances
+ALTER TABLE stock_events
+    DROP COLUMN IF EXISTS quantity_before,
+    DROP COLUMN IF EXISTS quantity_after,
+    DROP COLUMN IF EXISTS reserved_before,
+    DROP COLUMN IF EXISTS reserved_after;
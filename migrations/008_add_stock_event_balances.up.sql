@@ -0,0 +1,7 @@
+-- Stock and reservation balances before and after each event. Events recorded before this
+-- migration keep NULL balances.
+ALTER TABLE stock_events
+    ADD COLUMN IF NOT EXISTS quantity_before INTEGER,
+    ADD COLUMN IF NOT EXISTS quantity_after INTEGER,
+    ADD COLUMN IF NOT EXISTS reserved_before INTEGER,
+    ADD COLUMN IF NOT EXISTS reserved_after INTEGER;
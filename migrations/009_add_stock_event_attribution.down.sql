@@ -0,0 +1,16 @@
+-- Remove stock event attribution
+DROP INDEX IF EXISTS idx_stock_events_message_id;
+DROP INDEX IF EXISTS idx_stock_events_request_id;
+DROP INDEX IF EXISTS idx_stock_events_correlation_id;
+DROP INDEX IF EXISTS idx_stock_events_actor;
+
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_actor_type;
+
+ALTER TABLE stock_events
+    DROP COLUMN IF EXISTS actor_type,
+    DROP COLUMN IF EXISTS actor_id,
+    DROP COLUMN IF EXISTS actor_name,
+    DROP COLUMN IF EXISTS request_id,
+    DROP COLUMN IF EXISTS message_id,
+    DROP COLUMN IF EXISTS correlation_id,
+    DROP COLUMN IF EXISTS causation_id;
@@ -0,0 +1,18 @@
+-- Who or what caused each stock event, and the request or message it belongs to
+ALTER TABLE stock_events
+    ADD COLUMN IF NOT EXISTS actor_type VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS actor_id VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS actor_name VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS request_id VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS message_id VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS causation_id VARCHAR(255);
+
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_actor_type
+    CHECK (actor_type IS NULL OR actor_type IN ('USER', 'SERVICE', 'CONSUMER', 'SYSTEM', 'ANONYMOUS'));
+
+-- Indexes for audit lookups
+CREATE INDEX IF NOT EXISTS idx_stock_events_actor ON stock_events(actor_type, actor_id) WHERE actor_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_correlation_id ON stock_events(correlation_id) WHERE correlation_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_request_id ON stock_events(request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_message_id ON stock_events(message_id) WHERE message_id IS NOT NULL;
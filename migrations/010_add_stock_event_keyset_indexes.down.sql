@@ -0,0 +1,8 @@
+-- Restore the original stock_events indexes
+CREATE INDEX IF NOT EXISTS idx_stock_events_order_id ON stock_events(order_id) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_article_date ON stock_events(article_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_events_created_at ON stock_events(created_at DESC);
+
+DROP INDEX IF EXISTS idx_stock_events_order_created_at_id;
+DROP INDEX IF EXISTS idx_stock_events_article_created_at_id;
+DROP INDEX IF EXISTS idx_stock_events_created_at_id;
@@ -0,0 +1,9 @@
+-- Keyset pagination on (created_at, id): replace the created_at-only indexes with indexes
+-- that include id as tie-breaker
+CREATE INDEX IF NOT EXISTS idx_stock_events_created_at_id ON stock_events(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_events_article_created_at_id ON stock_events(article_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_events_order_created_at_id ON stock_events(order_id, created_at DESC, id DESC) WHERE order_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_stock_events_created_at;
+DROP INDEX IF EXISTS idx_stock_events_article_date;
+DROP INDEX IF EXISTS idx_stock_events_order_id;
@@ -0,0 +1,13 @@
+-- Periodic per-article stock snapshots used to rebuild point-in-time stock levels
+CREATE TABLE IF NOT EXISTS stock_snapshots (
+    article_id VARCHAR(100) NOT NULL,
+    taken_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    quantity INTEGER NOT NULL,
+    reserved INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    PRIMARY KEY (article_id, taken_at)
+);
+
+-- Index for snapshot retention and run bookkeeping
+CREATE INDEX IF NOT EXISTS idx_stock_snapshots_taken_at ON stock_snapshots(taken_at);
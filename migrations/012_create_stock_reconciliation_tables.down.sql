@@ -0,0 +1,3 @@
+-- Drop stock reconciliation tables
+DROP TABLE IF EXISTS stock_reconciliation_discrepancies;
+DROP TABLE IF EXISTS stock_reconciliation_runs;
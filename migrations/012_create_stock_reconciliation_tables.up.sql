@@ -0,0 +1,32 @@
+-- Reconciliation runs comparing the stocks table with the balances implied by stock_events
+CREATE TABLE IF NOT EXISTS stock_reconciliation_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    repair BOOLEAN NOT NULL DEFAULT FALSE,
+    articles_checked INTEGER NOT NULL DEFAULT 0,
+    discrepancies INTEGER NOT NULL DEFAULT 0,
+    repaired INTEGER NOT NULL DEFAULT 0,
+    triggered_by VARCHAR(255)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reconciliation_runs_started_at ON stock_reconciliation_runs(started_at DESC);
+
+-- Articles whose stock row does not match their event ledger in a given run
+CREATE TABLE IF NOT EXISTS stock_reconciliation_discrepancies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    run_id UUID NOT NULL REFERENCES stock_reconciliation_runs(id) ON DELETE CASCADE,
+    article_id VARCHAR(100) NOT NULL,
+    stock_quantity INTEGER,
+    stock_reserved INTEGER,
+    ledger_quantity INTEGER NOT NULL,
+    ledger_reserved INTEGER NOT NULL,
+    quantity_drift INTEGER NOT NULL,
+    reserved_drift INTEGER NOT NULL,
+    repaired BOOLEAN NOT NULL DEFAULT FALSE,
+    repair_event_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_reconciliation_discrepancies_run_id ON stock_reconciliation_discrepancies(run_id);
+CREATE INDEX IF NOT EXISTS idx_stock_reconciliation_discrepancies_article_id ON stock_reconciliation_discrepancies(article_id);
@@ -0,0 +1,122 @@
+-- Monthly range partitioning of stock_events by created_at. The table is rebuilt as a
+-- partitioned table and its rows copied over; the primary key must include the partition key.
+ALTER TABLE stock_events RENAME TO stock_events_unpartitioned;
+ALTER INDEX stock_events_pkey RENAME TO stock_events_unpartitioned_pkey;
+
+CREATE TABLE stock_events (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    article_id VARCHAR(100) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    quantity INTEGER NOT NULL,
+    order_id VARCHAR(100),
+    reason TEXT,
+    metadata JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    quantity_before INTEGER,
+    quantity_after INTEGER,
+    reserved_before INTEGER,
+    reserved_after INTEGER,
+    actor_type VARCHAR(20),
+    actor_id VARCHAR(255),
+    actor_name VARCHAR(255),
+    request_id VARCHAR(255),
+    message_id VARCHAR(255),
+    correlation_id VARCHAR(255),
+    causation_id VARCHAR(255),
+
+    CONSTRAINT stock_events_pkey PRIMARY KEY (id, created_at),
+    CONSTRAINT chk_event_type CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'CONFIRM_RESERVE',
+                                                    'EXPIRE_RESERVE', 'ADJUST', 'TRANSFER_OUT', 'TRANSFER_IN', 'LOW_STOCK')),
+    CONSTRAINT chk_event_quantity_positive CHECK (quantity >= 0),
+    CONSTRAINT chk_event_actor_type CHECK (actor_type IS NULL OR actor_type IN ('USER', 'SERVICE', 'CONSUMER', 'SYSTEM', 'ANONYMOUS'))
+) PARTITION BY RANGE (created_at);
+
+-- Registry of monthly partitions and their archives
+CREATE TABLE IF NOT EXISTS stock_event_partitions (
+    name VARCHAR(63) PRIMARY KEY,
+    range_start TIMESTAMP WITH TIME ZONE NOT NULL UNIQUE,
+    range_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'ATTACHED',
+    archive_path TEXT,
+    archived_rows INTEGER,
+    archive_sha256 VARCHAR(64),
+    archived_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+
+    CONSTRAINT chk_stock_event_partition_status CHECK (status IN ('ATTACHED', 'ARCHIVED'))
+);
+
+-- Net quantity and reserved movements of archived events per article, so that the ledger
+-- can still be reconciled after old partitions are dropped
+CREATE TABLE IF NOT EXISTS stock_event_archived_balances (
+    article_id VARCHAR(100) PRIMARY KEY,
+    quantity INTEGER NOT NULL DEFAULT 0,
+    reserved INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+-- Creates the partition for the month containing the given date (UTC boundaries) and
+-- registers it. Months already registered, including archived ones, are not recreated.
+CREATE OR REPLACE FUNCTION create_stock_events_partition(for_month DATE) RETURNS TEXT AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', for_month::TIMESTAMP);
+    partition_start TIMESTAMP WITH TIME ZONE := month_start AT TIME ZONE 'UTC';
+    partition_end TIMESTAMP WITH TIME ZONE := (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC';
+    partition_name TEXT := 'stock_events_' || to_char(month_start, 'YYYY_MM');
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('stock_event_partitions'));
+
+    IF EXISTS (SELECT 1 FROM stock_event_partitions p WHERE p.name = partition_name) THEN
+        RETURN partition_name;
+    END IF;
+
+    EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF stock_events FOR VALUES FROM (%L) TO (%L)',
+                   partition_name, partition_start, partition_end);
+
+    INSERT INTO stock_event_partitions (name, range_start, range_end)
+    VALUES (partition_name, partition_start, partition_end);
+
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Partitions for every month with events, up to three months ahead
+DO $$
+DECLARE
+    next_month DATE;
+BEGIN
+    SELECT date_trunc('month', COALESCE(MIN(created_at), NOW()) AT TIME ZONE 'UTC')::DATE
+    INTO next_month
+    FROM stock_events_unpartitioned;
+
+    WHILE next_month <= (date_trunc('month', NOW() AT TIME ZONE 'UTC') + INTERVAL '3 months')::DATE LOOP
+        PERFORM create_stock_events_partition(next_month);
+        next_month := (next_month + INTERVAL '1 month')::DATE;
+    END LOOP;
+END $$;
+
+-- Catches events outside every monthly partition (e.g. a month not created in time)
+CREATE TABLE IF NOT EXISTS stock_events_default PARTITION OF stock_events DEFAULT;
+
+INSERT INTO stock_events (id, article_id, event_type, quantity, order_id, reason, metadata, created_at,
+                          quantity_before, quantity_after, reserved_before, reserved_after,
+                          actor_type, actor_id, actor_name, request_id, message_id,
+                          correlation_id, causation_id)
+SELECT id, article_id, event_type, quantity, order_id, reason, metadata, COALESCE(created_at, NOW()),
+       quantity_before, quantity_after, reserved_before, reserved_after,
+       actor_type, actor_id, actor_name, request_id, message_id,
+       correlation_id, causation_id
+FROM stock_events_unpartitioned;
+
+DROP TABLE stock_events_unpartitioned;
+
+-- Indexes are created on every partition. idx_stock_events_article_id is not recreated:
+-- idx_stock_events_article_created_at_id covers the same lookups.
+CREATE INDEX IF NOT EXISTS idx_stock_events_event_type ON stock_events(event_type);
+CREATE INDEX IF NOT EXISTS idx_stock_events_created_at_id ON stock_events(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_events_article_created_at_id ON stock_events(article_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_events_order_created_at_id ON stock_events(order_id, created_at DESC, id DESC) WHERE order_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_actor ON stock_events(actor_type, actor_id) WHERE actor_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_correlation_id ON stock_events(correlation_id) WHERE correlation_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_request_id ON stock_events(request_id) WHERE request_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_stock_events_message_id ON stock_events(message_id) WHERE message_id IS NOT NULL;
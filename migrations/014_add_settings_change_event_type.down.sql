@@ -0,0 +1,7 @@
+-- Remove SETTINGS_CHANGE events and restore the previous event types
+DELETE FROM stock_events WHERE event_type = 'SETTINGS_CHANGE';
+
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'CONFIRM_RESERVE',
+                          'EXPIRE_RESERVE', 'ADJUST', 'TRANSFER_OUT', 'TRANSFER_IN', 'LOW_STOCK'));
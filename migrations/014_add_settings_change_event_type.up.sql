@@ -0,0 +1,6 @@
+-- Allow SETTINGS_CHANGE events for changes to an article's min_stock, max_stock or location
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'CONFIRM_RESERVE',
+                          'EXPIRE_RESERVE', 'ADJUST', 'TRANSFER_OUT', 'TRANSFER_IN', 'LOW_STOCK',
+                          'SETTINGS_CHANGE'));
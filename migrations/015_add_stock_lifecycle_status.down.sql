@@ -0,0 +1,14 @@
+-- Remove STATUS_CHANGE events and restore the previous event types
+DELETE FROM stock_events WHERE event_type = 'STATUS_CHANGE';
+
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'CONFIRM_RESERVE',
+                          'EXPIRE_RESERVE', 'ADJUST', 'TRANSFER_OUT', 'TRANSFER_IN', 'LOW_STOCK',
+                          'SETTINGS_CHANGE'));
+
+DROP INDEX IF EXISTS idx_stocks_low_stock;
+CREATE INDEX IF NOT EXISTS idx_stocks_low_stock ON stocks(quantity, min_stock) WHERE quantity <= min_stock;
+
+ALTER TABLE stocks DROP CONSTRAINT IF EXISTS chk_stock_status;
+ALTER TABLE stocks DROP COLUMN IF EXISTS status;
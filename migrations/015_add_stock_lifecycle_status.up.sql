@@ -0,0 +1,17 @@
+-- Lifecycle status of an article: ACTIVE, DISCONTINUED (no replenishment), BLOCKED (no new
+-- reservations) or ARCHIVED (retired, hidden from listings)
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'ACTIVE';
+ALTER TABLE stocks ADD CONSTRAINT chk_stock_status
+    CHECK (status IN ('ACTIVE', 'DISCONTINUED', 'BLOCKED', 'ARCHIVED'));
+
+-- Archived articles are excluded from the low stock list
+DROP INDEX IF EXISTS idx_stocks_low_stock;
+CREATE INDEX IF NOT EXISTS idx_stocks_low_stock ON stocks(quantity, min_stock)
+    WHERE quantity <= min_stock AND status <> 'ARCHIVED';
+
+-- Allow STATUS_CHANGE events for lifecycle transitions
+ALTER TABLE stock_events DROP CONSTRAINT IF EXISTS chk_event_type;
+ALTER TABLE stock_events ADD CONSTRAINT chk_event_type
+    CHECK (event_type IN ('ADD', 'REPLENISH', 'DEDUCT', 'RESERVE', 'CANCEL_RESERVE', 'CONFIRM_RESERVE',
+                          'EXPIRE_RESERVE', 'ADJUST', 'TRANSFER_OUT', 'TRANSFER_IN', 'LOW_STOCK',
+                          'SETTINGS_CHANGE', 'STATUS_CHANGE'));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_stocks_location;
+DROP INDEX IF EXISTS idx_stocks_available_article_id;
+DROP INDEX IF EXISTS idx_stocks_quantity_article_id;
+DROP INDEX IF EXISTS idx_stocks_updated_at_article_id;
+DROP INDEX IF EXISTS idx_stocks_created_at_article_id;
@@ -0,0 +1,8 @@
+-- Keyset pagination of the article listing on (sort column, article_id)
+CREATE INDEX IF NOT EXISTS idx_stocks_created_at_article_id ON stocks(created_at, article_id);
+CREATE INDEX IF NOT EXISTS idx_stocks_updated_at_article_id ON stocks(updated_at, article_id);
+CREATE INDEX IF NOT EXISTS idx_stocks_quantity_article_id ON stocks(quantity, article_id);
+CREATE INDEX IF NOT EXISTS idx_stocks_available_article_id ON stocks((quantity - reserved), article_id);
+
+-- Location filter
+CREATE INDEX IF NOT EXISTS idx_stocks_location ON stocks(location);
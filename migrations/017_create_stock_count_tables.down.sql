@@ -0,0 +1,3 @@
+-- Drop stock count tables
+DROP TABLE IF EXISTS stock_count_lines;
+DROP TABLE IF EXISTS stock_count_sessions;
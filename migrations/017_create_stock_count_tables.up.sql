@@ -0,0 +1,41 @@
+-- Cycle count sessions: physical counts of the articles of a location
+CREATE TABLE IF NOT EXISTS stock_count_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    location VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'OPEN',
+    notes TEXT,
+    opened_by VARCHAR(255),
+    closed_by VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    closed_at TIMESTAMP WITH TIME ZONE,
+
+    CONSTRAINT chk_stock_count_session_status CHECK (status IN ('OPEN', 'POSTED', 'CANCELLED'))
+);
+
+-- At most one open count per location
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_count_sessions_open_location
+    ON stock_count_sessions(location) WHERE status = 'OPEN';
+CREATE INDEX IF NOT EXISTS idx_stock_count_sessions_created_at ON stock_count_sessions(created_at DESC);
+
+-- Counted quantity per article; expected_quantity and variance are fixed when the count is posted
+CREATE TABLE IF NOT EXISTS stock_count_lines (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES stock_count_sessions(id) ON DELETE CASCADE,
+    article_id VARCHAR(100) NOT NULL,
+    counted_quantity INTEGER NOT NULL,
+    reason VARCHAR(255),
+    status VARCHAR(20) NOT NULL DEFAULT 'PENDING',
+    expected_quantity INTEGER,
+    variance INTEGER,
+    adjust_event_id UUID,
+    counted_by VARCHAR(255),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT chk_stock_count_line_quantity_positive CHECK (counted_quantity >= 0),
+    CONSTRAINT chk_stock_count_line_status CHECK (status IN ('PENDING', 'ADJUSTED', 'UNCHANGED', 'SKIPPED')),
+    CONSTRAINT uq_stock_count_lines_session_article UNIQUE (session_id, article_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_count_lines_article_id ON stock_count_lines(article_id);
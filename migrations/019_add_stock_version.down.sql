@@ -0,0 +1,2 @@
+-- Remove version column from stocks
+ALTER TABLE stocks DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+-- Add version column to stocks for optimistic concurrency (ETag / If-Match)
+ALTER TABLE stocks ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+-- Remove unfinished reconciliation runs and restore the NOT NULL constraint
+DELETE FROM stock_reconciliation_runs WHERE finished_at IS NULL;
+ALTER TABLE stock_reconciliation_runs ALTER COLUMN finished_at SET NOT NULL;
@@ -0,0 +1,3 @@
+-- Reconciliation runs are recorded when they start and finished when they end; a run without
+-- finished_at is in progress or was interrupted
+ALTER TABLE stock_reconciliation_runs ALTER COLUMN finished_at DROP NOT NULL;
@@ -0,0 +1,30 @@
+-- Restore the original partition function and statuses. Detached partitions are recorded as
+-- archived; their tables are kept and must be dropped by hand after checking the archive.
+UPDATE stock_event_partitions SET status = 'ARCHIVED' WHERE status = 'DETACHED';
+
+ALTER TABLE stock_event_partitions DROP CONSTRAINT IF EXISTS chk_stock_event_partition_status;
+ALTER TABLE stock_event_partitions ADD CONSTRAINT chk_stock_event_partition_status
+    CHECK (status IN ('ATTACHED', 'ARCHIVED'));
+
+CREATE OR REPLACE FUNCTION create_stock_events_partition(for_month DATE) RETURNS TEXT AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', for_month::TIMESTAMP);
+    partition_start TIMESTAMP WITH TIME ZONE := month_start AT TIME ZONE 'UTC';
+    partition_end TIMESTAMP WITH TIME ZONE := (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC';
+    partition_name TEXT := 'stock_events_' || to_char(month_start, 'YYYY_MM');
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('stock_event_partitions'));
+
+    IF EXISTS (SELECT 1 FROM stock_event_partitions p WHERE p.name = partition_name) THEN
+        RETURN partition_name;
+    END IF;
+
+    EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF stock_events FOR VALUES FROM (%L) TO (%L)',
+                   partition_name, partition_start, partition_end);
+
+    INSERT INTO stock_event_partitions (name, range_start, range_end)
+    VALUES (partition_name, partition_start, partition_end);
+
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,55 @@
+-- Archived partitions are first detached (DETACHED) and only dropped (ARCHIVED) once their
+-- archive file has been read back and matches the recorded row count and checksum
+ALTER TABLE stock_event_partitions DROP CONSTRAINT IF EXISTS chk_stock_event_partition_status;
+ALTER TABLE stock_event_partitions ADD CONSTRAINT chk_stock_event_partition_status
+    CHECK (status IN ('ATTACHED', 'DETACHED', 'ARCHIVED'));
+
+-- Creates the partition for the month containing the given date (UTC boundaries) and
+-- registers it. Months already registered, including archived ones, are not recreated.
+-- Events of that month that landed in stock_events_default are moved into the new
+-- partition; otherwise CREATE TABLE ... PARTITION OF fails validating the default partition.
+CREATE OR REPLACE FUNCTION create_stock_events_partition(for_month DATE) RETURNS TEXT AS $$
+DECLARE
+    month_start TIMESTAMP := date_trunc('month', for_month::TIMESTAMP);
+    partition_start TIMESTAMP WITH TIME ZONE := month_start AT TIME ZONE 'UTC';
+    partition_end TIMESTAMP WITH TIME ZONE := (month_start + INTERVAL '1 month') AT TIME ZONE 'UTC';
+    partition_name TEXT := 'stock_events_' || to_char(month_start, 'YYYY_MM');
+    moved INTEGER := 0;
+BEGIN
+    PERFORM pg_advisory_xact_lock(hashtext('stock_event_partitions'));
+
+    IF EXISTS (SELECT 1 FROM stock_event_partitions p WHERE p.name = partition_name) THEN
+        RETURN partition_name;
+    END IF;
+
+    IF to_regclass('stock_events_default') IS NOT NULL THEN
+        CREATE TEMP TABLE stock_events_default_moved (LIKE stock_events) ON COMMIT DROP;
+
+        WITH deleted AS (
+            DELETE FROM stock_events_default
+            WHERE created_at >= partition_start AND created_at < partition_end
+            RETURNING *
+        )
+        INSERT INTO stock_events_default_moved SELECT * FROM deleted;
+        GET DIAGNOSTICS moved = ROW_COUNT;
+
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF stock_events FOR VALUES FROM (%L) TO (%L)',
+                       partition_name, partition_start, partition_end);
+
+        INSERT INTO stock_events SELECT * FROM stock_events_default_moved;
+        DROP TABLE stock_events_default_moved;
+    ELSE
+        EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF stock_events FOR VALUES FROM (%L) TO (%L)',
+                       partition_name, partition_start, partition_end);
+    END IF;
+
+    IF moved > 0 THEN
+        RAISE WARNING 'moved % event(s) from stock_events_default into %', moved, partition_name;
+    END IF;
+
+    INSERT INTO stock_event_partitions (name, range_start, range_end)
+    VALUES (partition_name, partition_start, partition_end);
+
+    RETURN partition_name;
+END;
+$$ LANGUAGE plpgsql;
@@ -0,0 +1,2 @@
+-- Remove the quantity snapshot of the count lines
+ALTER TABLE stock_count_lines DROP COLUMN IF EXISTS submitted_quantity;
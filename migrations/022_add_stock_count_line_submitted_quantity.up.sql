@@ -0,0 +1,8 @@
+-- Quantity of the article when the line was submitted; posting applies counted - submitted as
+-- a relative adjustment so movements between the count and the post are kept
+ALTER TABLE stock_count_lines ADD COLUMN IF NOT EXISTS submitted_quantity INTEGER;
+
+UPDATE stock_count_lines l
+SET submitted_quantity = s.quantity
+FROM stocks s
+WHERE s.article_id = l.article_id AND l.status = 'PENDING';